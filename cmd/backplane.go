@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	pkgInt "hc/internal"
+	pkgIntHelper "hc/internal/helpers"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	backplaneConfigCmdArgs struct {
+		environment string
+		url         string
+		proxyURLs   []string
+		sessionDir  string
+		force       bool
+		noProxy     bool
+	}
+)
+
+var backplaneCmd = &cobra.Command{
+	Use:   "backplane",
+	Short: "Manages the backplane CLI setup",
+	// A missing backplane config fails the config validation
+	Annotations: map[string]string{
+		configOptionalAnnotation: "true",
+	},
+}
+
+var backplaneConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manages the backplane configs mounted in the workspace",
+}
+
+var backplaneConfigInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Writes the backplane config of an OCM environment",
+	Long: `Writes the backplane config of an OCM environment to the file named by
+backplaneConfigProd or backplaneConfigStage in the hc config, under
+~/.config/backplane.`,
+	Args:   cobra.NoArgs,
+	PreRun: pkgInt.ToggleDebug,
+	Run:    backplaneConfigInit,
+}
+
+var backplaneConfigValidateCmd = &cobra.Command{
+	Use:    "validate",
+	Short:  "Validates the backplane config of an OCM environment and its proxy",
+	Args:   cobra.NoArgs,
+	PreRun: pkgInt.ToggleDebug,
+	Run:    backplaneConfigValidate,
+}
+
+// Gets the hc config even if it is invalid, e.g. because the backplane
+// configs are missing.
+func getBackplaneHcConfig() *pkgInt.HcConfig {
+	config, err := pkgInt.GetHcConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(config.UserHome) == 0 {
+		if config.UserHome, err = os.UserHomeDir(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	return config
+}
+
+// Gets the config key naming the backplane config file of an OCM environment.
+func getBackplaneConfigKey(environment string) string {
+	if environment == "production" {
+		return "backplaneConfigProd"
+	}
+	return "backplaneConfigStage"
+}
+
+func backplaneConfigInit(cmd *cobra.Command, args []string) {
+	environment := backplaneConfigCmdArgs.environment
+	backplaneConfig, err := pkgInt.NewBackplaneConfig(environment)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(backplaneConfigCmdArgs.url) > 0 {
+		backplaneConfig.URL = backplaneConfigCmdArgs.url
+	}
+	backplaneConfig.ProxyURLs = backplaneConfigCmdArgs.proxyURLs
+	if cmd.Flags().Changed("session-dir") {
+		backplaneConfig.SessionDir = backplaneConfigCmdArgs.sessionDir
+	}
+	if err := backplaneConfig.Validate(); err != nil {
+		log.Fatal("Invalid backplane config: ", err)
+	}
+
+	config := getBackplaneHcConfig()
+	file := config.GetBackplaneConfigFile(environment)
+	if _, err := os.Stat(file); err == nil && !backplaneConfigCmdArgs.force {
+		log.Fatalf("%s already exists, pass --force to overwrite it", file)
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatal(err)
+	}
+
+	content, err := json.MarshalIndent(backplaneConfig, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		log.Fatal(err)
+	}
+	if err := pkgIntHelper.WriteFileAtomic(file, append(content, '\n'), 0600); err != nil {
+		log.Fatalf("Failed to write %s: %v", file, err)
+	}
+	fmt.Printf("Wrote %s\n", file)
+
+	key := getBackplaneConfigKey(environment)
+	if pkgInt.GetConfigOrigin(key) == pkgInt.ConfigOriginDefault {
+		fmt.Printf("Set it in the hc config with: hc config set %s %s\n", key, filepath.Base(file))
+	}
+	if err := backplaneConfig.CheckProxies(); err != nil {
+		log.Warn(err)
+	}
+}
+
+func backplaneConfigValidate(cmd *cobra.Command, args []string) {
+	config := getBackplaneHcConfig()
+	file := config.GetBackplaneConfigFile(backplaneConfigCmdArgs.environment)
+	if _, err := pkgInt.ValidateBackplaneConfigFile(file, !backplaneConfigCmdArgs.noProxy); err != nil {
+		log.Fatal(err)
+	}
+	warnings, err := pkgInt.CheckBackplaneConfigKeys(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	fmt.Printf("%s is valid\n", file)
+}
+
+func init() {
+	rootCmd.AddCommand(backplaneCmd)
+	backplaneCmd.AddCommand(backplaneConfigCmd)
+	backplaneConfigCmd.AddCommand(
+		backplaneConfigInitCmd,
+		backplaneConfigValidateCmd,
+	)
+
+	for _, cmd := range []*cobra.Command{backplaneConfigInitCmd, backplaneConfigValidateCmd} {
+		cmd.Flags().StringVarP(
+			&backplaneConfigCmdArgs.environment,
+			"env",
+			"e",
+			"production",
+			"OCM environment (production, staging)",
+		)
+		cmd.RegisterFlagCompletionFunc("env", completeOcmEnvironments)
+	}
+
+	flags := backplaneConfigInitCmd.Flags()
+	flags.StringVar(
+		&backplaneConfigCmdArgs.url,
+		"url",
+		"",
+		"Backplane API URL, by default the URL of the environment",
+	)
+	flags.StringSliceVar(
+		&backplaneConfigCmdArgs.proxyURLs,
+		"proxy-url",
+		nil,
+		"Proxy the backplane API is reached through, may be repeated",
+	)
+	flags.StringVar(
+		&backplaneConfigCmdArgs.sessionDir,
+		"session-dir",
+		"",
+		"Directory where backplane keeps its sessions",
+	)
+	flags.BoolVarP(
+		&backplaneConfigCmdArgs.force,
+		"force",
+		"f",
+		false,
+		"Overwrite an existing backplane config",
+	)
+
+	backplaneConfigValidateCmd.Flags().BoolVar(
+		&backplaneConfigCmdArgs.noProxy,
+		"no-proxy-check",
+		false,
+		"Do not check that the proxy is reachable",
+	)
+}
@@ -1,6 +1,9 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
+
 	pkgInt "hc/internal"
 	pkgIntHelper "hc/internal/helpers"
 
@@ -13,37 +16,30 @@ var buildCmd = &cobra.Command{
 	Use:   "build",
 	Short: "Builds the hc image",
 	Run: func(cmd *cobra.Command, args []string) {
-		ceFactory := pkgInt.NewCeFactory(map[string]interface{}{
-			"ceName": "podman",
-		})
-
-		ce, err := ceFactory.Create()
-		if err != nil {
-			log.Fatal("Failed to create container engine: ", err)
-		}
-
-		config := pkgInt.GetHcConfig()
-
-		ce.AppendBuildArg("BASE_IMAGE_VERSION", config.BaseImageVersion)
-		ce.AppendBuildArg("OCM_CLI_VERSION", config.OCMCLIVersion)
-		ce.AppendBuildArg("BACKPLANE_CLI_VERSION", config.BackplaneCLIVersion)
-
-		out, err := pkgIntHelper.RunCommandOutput(
-			"git",
-			"rev-parse",
-			"HEAD",
-		)
-		if err != nil {
+		if err := buildImage(cmd.Context(), newContainerEngine(), getHcConfig()); err != nil {
 			log.Fatal(err)
 		}
-		headSha := string(out)
-		ce.AppendBuildArg("BUILD_SHA", headSha)
-
-		ceBuildCmd := ce.GetBuildCmd()
-		pkgIntHelper.RunCommandStreamOutput(ce.GetExecName(), ceBuildCmd...)
 	},
 }
 
+// Builds the hc image tagged with both hc:latest and the versioned local image name.
+func buildImage(ctx context.Context, ce pkgInt.ContainerEngine, config *pkgInt.HcConfig) error {
+	ce.AppendBuildArg("BASE_IMAGE_VERSION", config.GetBaseImageVersion())
+	ce.AppendBuildArg("OCM_CLI_VERSION", config.OCMCLIVersion)
+	ce.AppendBuildArg("BACKPLANE_CLI_VERSION", config.BackplaneCLIVersion)
+
+	// The image runs the hc binary being run, so it is labelled with its build
+	ce.AppendBuildArg("HC_VERSION", pkgInt.Version)
+	ce.AppendBuildArg("BUILD_SHA", pkgInt.GetBuildSHA())
+	ce.AppendImageTag(config.GetLocalImage())
+
+	ceBuildCmd := ce.GetBuildCmd()
+	if err := pkgIntHelper.RunStreamed(ctx, ce.GetExecName(), ceBuildCmd...); err != nil {
+		return fmt.Errorf("image build failed: %w", err)
+	}
+	return nil
+}
+
 func init() {
 	rootCmd.AddCommand(buildCmd)
 }
@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	pkgInt "hc/internal"
+	pkgIntHelper "hc/internal/helpers"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var clusterCmd = &cobra.Command{
+	Use:   "cluster",
+	Short: "Manages the clusters logged in to in the workspace",
+	Long: `Manages the clusters logged in to in the workspace. Each cluster is logged in
+to through backplane as a separate kubeconfig context.`,
+}
+
+var clusterAddCmd = &cobra.Command{
+	Use:               "add <cluster>",
+	Short:             "Logs in to another cluster through backplane",
+	Args:              cobra.ExactArgs(1),
+	PreRun:            pkgInt.ToggleDebug,
+	Run:               clusterAdd,
+	ValidArgsFunction: completeFirstArg(getContainerOcmClusterNames),
+}
+
+var clusterSwitchCmd = &cobra.Command{
+	Use:   "switch [name|-]",
+	Short: "Switches to a cluster of the workspace",
+	Long: `Switches to a cluster of the workspace. The name may be a fuzzy match of a
+cluster name, "-" switches back to the previous cluster. Without a name, a
+cluster is picked interactively.`,
+	Args:              cobra.MaximumNArgs(1),
+	PreRun:            pkgInt.ToggleDebug,
+	Run:               clusterSwitch,
+	ValidArgsFunction: completeFirstArg(getWorkspaceClusterNames),
+}
+
+var clusterListCmd = &cobra.Command{
+	Use:    "list",
+	Short:  "Lists the clusters of the workspace",
+	Args:   cobra.NoArgs,
+	PreRun: pkgInt.ToggleDebug,
+	Run:    clusterList,
+}
+
+func loadWorkspaceClusters() []pkgIntHelper.WorkspaceCluster {
+	clusters, err := pkgIntHelper.LoadWorkspaceClusters()
+	if err != nil {
+		log.Fatal("Failed to load the workspace clusters: ", err)
+	}
+	return clusters
+}
+
+func clusterAdd(cmd *cobra.Command, args []string) {
+	if err := checkContainerCommand(); err != nil {
+		log.Fatal(err)
+	}
+
+	previousContext := ""
+	if kubeconfig, err := pkgIntHelper.LoadKubeconfig(); err == nil {
+		previousContext = kubeconfig.CurrentContext
+	}
+
+	if err := pkgIntHelper.RunStreamed(cmd.Context(), "ocm", "backplane", "login", args[0]); err != nil {
+		log.Fatalf("OCM backplane login failed: %v", err)
+	}
+
+	// Backplane switches to the context of the new login
+	kubeconfig := loadKubeconfig()
+	cluster, err := kubeconfig.GetCurrentCluster()
+	if err != nil {
+		log.Fatal(err)
+	}
+	id := pkgIntHelper.GetBackplaneClusterID(cluster.Cluster.Server)
+	if len(id) == 0 {
+		log.Fatalf("The current context %s is not a backplane login", kubeconfig.CurrentContext)
+	}
+
+	// Looked up before the cluster is added so that the prompt shows its name
+	name := args[0]
+	if ocmCluster, err := pkgInt.GetContainerOcmCli().GetCluster(id); err == nil {
+		name = ocmCluster.Name
+	} else {
+		log.Debugf("Failed to look up cluster %s: %v", id, err)
+	}
+
+	err = pkgIntHelper.AddWorkspaceCluster(pkgIntHelper.WorkspaceCluster{
+		Name:    name,
+		ID:      id,
+		Context: kubeconfig.CurrentContext,
+	})
+	if err != nil {
+		log.Fatal("Failed to add the cluster to the workspace: ", err)
+	}
+
+	if len(previousContext) > 0 && previousContext != kubeconfig.CurrentContext {
+		state := loadKubeconfigState()
+		state.PreviousContext = previousContext
+		saveKubeconfigState(state)
+	}
+	log.Infof("Logged in to cluster %s (%s)", name, id)
+}
+
+func clusterSwitch(cmd *cobra.Command, args []string) {
+	if err := checkContainerCommand(); err != nil {
+		log.Fatal(err)
+	}
+
+	clusters := loadWorkspaceClusters()
+	names := []string{}
+	for _, cluster := range clusters {
+		names = append(names, cluster.Name)
+	}
+
+	kubeconfig := loadKubeconfig()
+	state := loadKubeconfigState()
+
+	var targetContext string
+	switch {
+	case len(args) > 0 && args[0] == "-":
+		targetContext = state.PreviousContext
+		if len(targetContext) == 0 {
+			log.Fatal("No previous cluster")
+		}
+	default:
+		var name string
+		var err error
+		if len(args) == 0 {
+			name, err = pickItem(cmd.Context(), "cluster", names, "")
+		} else {
+			name, err = resolveWorkspaceClusterName(cmd.Context(), clusters, names, args[0])
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, cluster := range clusters {
+			if cluster.Name == name {
+				targetContext = cluster.Context
+			}
+		}
+	}
+
+	if kubeconfig.GetContext(targetContext) == nil {
+		log.Fatalf("Context %s not found, please log in again with \"hc cluster add\"", targetContext)
+	}
+	if targetContext == kubeconfig.CurrentContext {
+		fmt.Printf("Already on context %q\n", targetContext)
+		return
+	}
+
+	previous := kubeconfig.CurrentContext
+	if err := kubeconfig.SetCurrentContext(targetContext); err != nil {
+		log.Fatal("Failed to switch cluster: ", err)
+	}
+	state.PreviousContext = previous
+	saveKubeconfigState(state)
+	fmt.Printf("Switched to context %q\n", targetContext)
+}
+
+// Resolves a cluster by its id or by its name.
+func resolveWorkspaceClusterName(ctx context.Context, clusters []pkgIntHelper.WorkspaceCluster, names []string, query string) (string, error) {
+	for _, cluster := range clusters {
+		if cluster.ID == query {
+			return cluster.Name, nil
+		}
+	}
+	return resolveItem(ctx, "cluster", names, query)
+}
+
+func clusterList(cmd *cobra.Command, args []string) {
+	if err := checkContainerCommand(); err != nil {
+		log.Fatal(err)
+	}
+
+	clusters := loadWorkspaceClusters()
+	currentContext := ""
+	if kubeconfig, err := pkgIntHelper.LoadKubeconfig(); err == nil {
+		currentContext = kubeconfig.CurrentContext
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CURRENT\tNAME\tID\tCONTEXT")
+	for _, cluster := range clusters {
+		current := ""
+		if cluster.Context == currentContext {
+			current = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", current, cluster.Name, cluster.ID, cluster.Context)
+	}
+	w.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(clusterCmd)
+	clusterCmd.AddCommand(
+		clusterAddCmd,
+		clusterSwitchCmd,
+		clusterListCmd,
+	)
+}
@@ -1,13 +1,17 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	pkgInt "hc/internal"
 	pkgIntHelper "hc/internal/helpers"
+	pkgIntOcm "hc/internal/ocm"
 
 	"github.com/spf13/cobra"
 
@@ -16,6 +20,12 @@ import (
 
 var hcCon *hcContainer
 
+var (
+	clusterLoginCmdArgs struct {
+		script string
+	}
+)
+
 var clusterLoginCmd = &cobra.Command{
 	Use:    "clusterLogin",
 	Short:  "Logs in to an hybrid-cloud OpenShift cluster.",
@@ -24,15 +34,25 @@ var clusterLoginCmd = &cobra.Command{
 }
 
 func clusterLogin(cmd *cobra.Command, args []string) {
-	hcCon = NewHcContainer(pkgInt.GetHcConfig())
+	hcCon = NewHcContainer(getHcConfig())
 	if err := checkContainerCommand(); err != nil {
 		logger.Fatal(err)
 	}
+	ctx := cmd.Context()
 
-	configureOCMUser()
-	configureWorkspaceDirs()
-	OCMLogin()
-	OCMBackplaneLogin()
+	// In hardened mode the container already runs as the host user
+	if !hcCon.Hardened {
+		configureOCMUser(ctx)
+	}
+	linkPersistentState(ctx)
+	configureWorkspaceDirs(ctx)
+	if isOcmSessionPersisted() {
+		logger.Info("Using the persisted OCM session for ", hcCon.OcmEnvironment)
+	} else {
+		OCMLogin(ctx)
+	}
+	dropPersistedElevation(ctx)
+	OCMBackplaneLogin(ctx)
 
 	customPortMapsStr := strings.Trim(getEnvVar("CUSTOM_PORT_MAPS"), ",")
 	var allocatedContainerPorts []string
@@ -44,38 +64,149 @@ func clusterLogin(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	runTerminal()
+	// hc run passes a script or a command to run instead of a terminal
+	if len(clusterLoginCmdArgs.script) > 0 {
+		runPayload(ctx, []string{"/bin/bash", clusterLoginCmdArgs.script})
+	}
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 && len(args) > dash {
+		runPayload(ctx, args[dash:])
+	}
+	runTerminal(ctx)
 }
 
-func configureOCMUser() {
-	// Configure ocm user
-	commands := [][]string{
-		{
-			"useradd",
-			"-m",
-			hcCon.HostUser,
-			"-d",
-			hcCon.UserHome,
-		},
-		{
-			"usermod",
-			"-aG",
-			"wheel",
-			hcCon.HostUser,
-		},
+// Runs the payload of hc run as the workspace user in the artifacts
+// directory and exits with its exit code.
+func runPayload(ctx context.Context, payload []string) {
+	if dir := getEnvVar("ARTIFACTS_DIR"); len(dir) > 0 {
+		if err := os.Chdir(dir); err != nil {
+			logger.Fatalf("Failed to change to the artifacts directory %s: %v", dir, err)
+		}
 	}
-	errors := pkgIntHelper.RunCommandListStreamOutput(commands)
 
-	if len(errors) > 0 {
-		logger.Fatalf("Encountered errors while configuring OCM user: %v", errors)
+	logger.Infof("Running %v", payload)
+	payloadCmd := hcCon.userCommand(payload...)
+	// Without a terminal, SIGINT comes from podman only
+	command := pkgIntHelper.NewCommand(payloadCmd[0], payloadCmd[1:]...)
+	command.Interactive = true
+	command.ForwardSignals = true
+	_, err := pkgIntHelper.Run(ctx, command)
+	exitCode := pkgIntHelper.GetExitCode(err)
+	if exitCode < 0 {
+		logger.Fatalf("Failed to run %v: %v", payload, err)
 	}
+	pkgIntHelper.Exit(exitCode)
+}
+
+const sudoersDropInPath = "/etc/sudoers.d/hc"
 
-	line := []byte("\n%wheel         ALL = (ALL) NOPASSWD: ALL\n")
-	os.WriteFile("/etc/sudoer", line, 0644)
+// Provisions the workspace user with the host user's uid and gid so that
+// files written to host-mounted directories are owned by the host user.
+func configureOCMUser(ctx context.Context) {
+	if len(hcCon.HostUid) == 0 || len(hcCon.HostGid) == 0 {
+		logger.Fatal("HOST_UID and HOST_GID must be set by hc login")
+	}
 
+	group, err := pkgIntHelper.EnsureGroup(ctx, hcCon.HostUser, hcCon.HostGid)
+	if err != nil {
+		logger.Fatalf("Failed to configure group %s: %v", hcCon.HostUser, err)
+	}
+	logger.Debugf("Using group %s (%s)", group, hcCon.HostGid)
+
+	err = pkgIntHelper.EnsureUser(ctx, hcCon.HostUser, hcCon.HostUid, hcCon.HostGid, hcCon.UserHome)
+	if err != nil {
+		logger.Fatalf("Failed to configure user %s: %v", hcCon.HostUser, err)
+	}
+
+	sudoRule := fmt.Sprintf("%s ALL=(ALL) NOPASSWD: ALL\n", hcCon.HostUser)
+	if err := pkgIntHelper.InstallSudoersDropIn(ctx, sudoersDropInPath, sudoRule); err != nil {
+		logger.Fatalf("Failed to install %s: %v", sudoersDropInPath, err)
+	}
+}
+
+// Links the directories of the workspace state in the user home to the
+// persistent state mounted by hc login, if any.
+func linkPersistentState(ctx context.Context) {
+	stateDir := getEnvVar("PERSISTENT_STATE_DIR")
+	if len(stateDir) == 0 {
+		return
+	}
+
+	commands := [][]string{}
+	for _, dir := range pkgInt.StateDirs {
+		target := filepath.Join(stateDir, dir.Name)
+		link := filepath.Join(hcCon.UserHome, dir.HomePath)
+		if err := os.MkdirAll(target, 0700); err != nil {
+			logger.Fatalf("Failed to create %s: %v", target, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+			logger.Fatalf("Failed to create %s: %v", filepath.Dir(link), err)
+		}
+		// Only empty directories are replaced, e.g. not a directory mapped
+		// from the host
+		if info, err := os.Lstat(link); err == nil {
+			if info.Mode()&os.ModeSymlink == 0 && os.Remove(link) != nil {
+				logger.Warnf("Not persisting %s, it already exists", link)
+				continue
+			}
+			os.Remove(link)
+		}
+		if err := os.Symlink(target, link); err != nil {
+			logger.Fatalf("Failed to link %s: %v", link, err)
+		}
+		logger.Debugf("Linked %s to %s", link, target)
+
+		// The directories are created by root unless in hardened mode
+		if !hcCon.Hardened {
+			owner := fmt.Sprintf("%s:%s", hcCon.HostUid, hcCon.HostGid)
+			commands = append(
+				commands,
+				[]string{"chown", "-R", owner, target},
+				[]string{"chown", "-h", owner, link, filepath.Dir(link)},
+			)
+		}
+	}
+
+	if errors := pkgIntHelper.RunStreamedList(ctx, commands); len(errors) > 0 {
+		logger.Fatalf("Encountered errors while linking the persistent state: %v", errors)
+	}
 }
 
-func configureWorkspaceDirs() {
+// Checks whether the persisted OCM config is logged in to the workspace
+// environment with a refresh token that has not expired.
+func isOcmSessionPersisted() bool {
+	if len(getEnvVar("PERSISTENT_STATE_DIR")) == 0 {
+		return false
+	}
+	url, err := pkgIntOcm.GetEnvironmentURL(hcCon.OcmEnvironment)
+	if err != nil {
+		return false
+	}
+	config, err := pkgIntOcm.LoadConfig(filepath.Join(hcCon.UserHome, ".config", "ocm", "ocm.json"))
+	if err != nil || config.URL != url || len(config.RefreshToken) == 0 {
+		return false
+	}
+	// Offline tokens do not expire
+	expiry := pkgIntOcm.GetTokenExpiry(config.RefreshToken)
+	return expiry.IsZero() || time.Until(expiry) > time.Minute
+}
+
+// Drops an elevation left in the persisted kubeconfig by a previous workspace.
+func dropPersistedElevation(ctx context.Context) {
+	stateDir := getEnvVar("PERSISTENT_STATE_DIR")
+	if len(stateDir) == 0 {
+		return
+	}
+	// The elevation is saved in the persisted hc cache of the workspace user
+	if _, err := os.Stat(filepath.Join(stateDir, "hc-cache", "elevation.json")); err != nil {
+		return
+	}
+	dropCmd := hcCon.userCommand("/usr/bin/hc", "elevate", "--drop")
+	if err := pkgIntHelper.RunStreamed(ctx, dropCmd[0], dropCmd[1:]...); err != nil {
+		logger.Warnf("Failed to drop the persisted elevation: %v", err)
+	}
+}
+
+func configureWorkspaceDirs(ctx context.Context) {
 	// Configure directories
 	commands := [][]string{
 		{
@@ -83,30 +214,37 @@ func configureWorkspaceDirs() {
 			"-p",
 			fmt.Sprintf("%s/.kube", hcCon.UserHome),
 		},
-		{
-			"chown",
-			"-R",
-			fmt.Sprintf("%s:%s", hcCon.HostUser, hcCon.HostUser),
-			fmt.Sprintf("%s/.kube", hcCon.UserHome),
-		},
 		{
 			"mkdir",
 			"-p",
 			fmt.Sprintf("%s/.config/ocm", hcCon.UserHome),
 		},
-		{
-			"chown",
-			"-R",
-			fmt.Sprintf("%s:%s", hcCon.HostUser, hcCon.HostUser),
-			fmt.Sprintf("%s/.config/ocm", hcCon.UserHome),
-		},
-		{
-			"chmod",
-			"o+rwx",
-			"/hc",
-		},
 	}
-	errors := pkgIntHelper.RunCommandListStreamOutput(commands)
+
+	// The directories are created by root unless in hardened mode
+	if !hcCon.Hardened {
+		commands = append(
+			commands,
+			[]string{
+				"chown",
+				"-R",
+				fmt.Sprintf("%s:%s", hcCon.HostUid, hcCon.HostGid),
+				fmt.Sprintf("%s/.kube", hcCon.UserHome),
+			},
+			[]string{
+				"chown",
+				"-R",
+				fmt.Sprintf("%s:%s", hcCon.HostUid, hcCon.HostGid),
+				fmt.Sprintf("%s/.config", hcCon.UserHome),
+			},
+			[]string{
+				"chmod",
+				"o+rwx",
+				"/hc",
+			},
+		)
+	}
+	errors := pkgIntHelper.RunStreamedList(ctx, commands)
 
 	if len(errors) > 0 {
 		logger.Fatalf("Encountered errors while configuring hc directories: %v", errors)
@@ -114,98 +252,120 @@ func configureWorkspaceDirs() {
 
 }
 
-func OCMLogin() {
+func OCMLogin(ctx context.Context) {
 	logger.Info("Logging into ocm ", hcCon.OcmEnvironment)
 
-	status := pkgIntHelper.RunCommandStreamOutput(
-		"sudo",
-		"-Eu",
-		hcCon.HostUser,
+	loginCmd := hcCon.userCommand(
 		"ocm",
 		"login",
 		fmt.Sprintf("--token=%s", hcCon.OcmToken),
 		fmt.Sprintf("--url=%s", hcCon.OcmEnvironment),
 	)
-
-	if status.Exit != 0 {
-		logger.Fatalf("OCM Login failed: %v", status.Error)
+	command := pkgIntHelper.NewCommand(loginCmd[0], loginCmd[1:]...).Streamed()
+	command.Secrets = []string{hcCon.OcmToken}
+	if _, err := pkgIntHelper.Run(ctx, command); err != nil {
+		logger.Fatalf("OCM Login failed: %v", err)
 	}
 
 	logger.Info("OCM Login successful.")
 }
 
-func OCMBackplaneLogin() {
+func OCMBackplaneLogin(ctx context.Context) {
 	isOcmLoginOnly, err := strconv.ParseBool(hcCon.IsOcmLoginOnly)
 	if err != nil {
 		logger.Fatal("Failed to parse environment variable: ", err)
 	}
 
 	if !isOcmLoginOnly {
-		// Backplane login
-		status := pkgIntHelper.RunCommandStreamOutput(
-			"sudo",
-			"-Eu",
-			hcCon.HostUser,
-			"ocm",
-			"backplane",
-			"login",
+		// Backplane login, registered as the first cluster of the workspace
+		backplaneLoginCmd := hcCon.userCommand(
+			"/usr/bin/hc",
+			"cluster",
+			"add",
 			hcCon.OcmCluster,
 		)
-
-		if status.Exit != 0 {
-			logger.Fatalf("OCM backplane login failed: %v", status.Error)
+		err := pkgIntHelper.RunStreamed(ctx, backplaneLoginCmd[0], backplaneLoginCmd[1:]...)
+		if err != nil {
+			logger.Fatalf("OCM backplane login failed: %v", err)
 		}
 		logger.Info("OCM backplane login successful.")
 	}
 
 }
 
-func runTerminal() {
-	// Run terminal
-	status := pkgIntHelper.RunCommandStreamOutput("cp", "/hc/terminal/bashrc", hcCon.UserBashrcPath)
-	if status.Exit != 0 {
-		logger.Fatalf("Failed to copy /hc/terminal/bashrc: %v", status.Error)
+// Directory of the terminal setup files in the image
+const terminalDir = "/hc/terminal"
+
+// Runs the workspace shell and exits with its exit code.
+func runTerminal(ctx context.Context) {
+	config := getHcConfig()
+	shell, err := pkgInt.GetShell(config.Shell)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	data := &pkgInt.ShellRcData{
+		HostUser:       hcCon.HostUser,
+		OcmEnvironment: hcCon.OcmEnvironment,
+		AddToPATHEnv:   config.AddToPATHEnv,
+		ExportEnvVars:  pkgInt.ParseShellEnvVars(config.ExportEnvVars),
+		UserRcFile:     getEnvVar("SHELL_RC_FILE"),
+	}
+	isRecorded := len(getEnvVar("SESSION_RECORDING_DIR")) > 0
+	if isRecorded {
+		data.AuditHookFile = shell.GetAuditHookFile(terminalDir)
 	}
 
-	file, err := os.OpenFile(hcCon.UserBashrcPath, os.O_APPEND|os.O_WRONLY, 0644)
+	rc, err := shell.RenderRc(terminalDir, data)
 	if err != nil {
-		logger.Errorf("Failed to open %s: %s\n", hcCon.UserBashrcPath, err)
-	} else {
-		defer file.Close()
-
-		ps1String := fmt.Sprintf(
-			"\nPS1='[%s %s $(/usr/bin/hc currentCluster) $(/usr/bin/hc currentNamespace -u %s)]$ '\n",
-			hcCon.HostUser,
-			hcCon.OcmEnvironment,
-			hcCon.HostUser)
-		_, err = file.WriteString(ps1String)
-		if err != nil {
-			logger.Errorf("Failed to write to file %s: %s\n", hcCon.UserBashrcPath, err)
-		}
+		logger.Fatalf("Failed to render the %s rc file: %v", shell.Name, err)
+	}
+	writeUserFile(ctx, filepath.Join(hcCon.UserHome, shell.RcFile), rc)
 
-		config := pkgInt.GetHcConfig()
-		exportStr := "\nexport PATH=$PATH"
-		for _, path := range config.AddToPATHEnv {
-			exportStr += fmt.Sprintf(":%s", path)
-		}
-		_, err = file.WriteString(exportStr)
-		if err != nil {
-			logger.Errorf("Failed to write to file %s: %s\n", hcCon.UserBashrcPath, err)
-		}
+	shellCmd := hcCon.userCommand(shell.Exec)
+	if isRecorded {
+		shellCmd = hcCon.userCommand("/usr/bin/hc", "sessions", "record", "--", shell.Exec)
+	}
+	err = pkgIntHelper.RunInteractive(ctx, shellCmd[0], shellCmd[1:]...)
+	exitCode := pkgIntHelper.GetExitCode(err)
+	if exitCode < 0 {
+		logger.Fatalf("Failed to run the %s shell: %v", shell.Name, err)
+	}
+	pkgIntHelper.Exit(exitCode)
+}
 
-		for _, path := range config.ExportEnvVars {
-			exportStr = fmt.Sprintf("\nexport %s", path)
-			_, err = file.WriteString(exportStr)
-			if err != nil {
-				logger.Errorf("Failed to write to file %s: %s\n", hcCon.UserBashrcPath, err)
-			}
+// Writes a file in the user home, owned by the workspace user.
+func writeUserFile(ctx context.Context, path string, content []byte) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		logger.Fatalf("Failed to create %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		logger.Fatalf("Failed to write %s: %v", path, err)
+	}
+
+	// Files are written by root unless in hardened mode
+	if !hcCon.Hardened {
+		err := pkgIntHelper.RunStreamed(
+			ctx,
+			"chown",
+			fmt.Sprintf("%s:%s", hcCon.HostUid, hcCon.HostGid),
+			filepath.Dir(path),
+			path,
+		)
+		if err != nil {
+			logger.Fatalf("Failed to change the owner of %s: %v", path, err)
 		}
 	}
-	pkgIntHelper.RunCommandWithOsFiles("sudo", os.Stdout, os.Stderr, os.Stdin, "-Eu", hcCon.HostUser, "bash")
 }
 
 func init() {
 	rootCmd.AddCommand(clusterLoginCmd)
+	clusterLoginCmd.Flags().StringVar(
+		&clusterLoginCmdArgs.script,
+		"script",
+		"",
+		"Script to run instead of a terminal",
+	)
 
 	// Here you will define your flags and configuration settings.
 
@@ -1,12 +1,14 @@
 package cmd
 
 import (
+	"context"
 	"errors"
-	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	pkgInt "hc/internal"
+	pkgIntHelper "hc/internal/helpers"
 
 	logger "github.com/sirupsen/logrus"
 )
@@ -22,19 +24,70 @@ func checkContainerCommand() error {
 	return nil
 }
 
+// Time a cleanup command has to complete
+const cleanupCommandTimeout = 30 * time.Second
+
+// Runs a command that cleans up after a command, which may have been stopped
+// by a signal.
+func runCleanupCommand(name string, args ...string) {
+	command := pkgIntHelper.NewCommand(name, args...)
+	command.Timeout = cleanupCommandTimeout
+	if _, err := pkgIntHelper.Run(context.Background(), command); err != nil {
+		logger.Warn("Failed to clean up: ", err)
+	}
+}
+
 func getEnvVar(name string) string {
 	return strings.TrimSpace(os.Getenv(name))
 }
 
+// Gets the hc config. Exits if the config cannot be decoded.
+func getHcConfig() *pkgInt.HcConfig {
+	config, err := pkgInt.GetHcConfig()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	return config
+}
+
+// Creates the container engine of the commands. Tests replace it, e.g. with
+// a pkgInt.FakeEngine.
+var newContainerEngine = func() pkgInt.ContainerEngine {
+	ceFactory := pkgInt.NewCeFactory(map[string]interface{}{
+		"ceName": "podman",
+	})
+	ce, err := ceFactory.Create()
+	if err != nil {
+		logger.Fatal("Failed to create container engine: ", err)
+	}
+	return ce
+}
+
+// Creates the OCM client of the commands. Tests replace it, e.g. with a client
+// of a pkgIntOcm.FakeServer.
+var newOcmCli = pkgInt.NewOcmCli
+
 type hcContainer struct {
 	HostUser       string
+	HostUid        string
+	HostGid        string
 	UserHome       string
 	IsOcmLoginOnly string
 	customPortMaps string
-	UserBashrcPath string
 	OcmCluster     string
 	OcmToken       string
 	OcmEnvironment string
+	// Whether the container runs unprivileged as the workspace user
+	Hardened bool
+}
+
+// Gets a command that runs as the workspace user. In hardened mode the
+// container already runs as the workspace user and sudo is unavailable.
+func (c *hcContainer) userCommand(args ...string) []string {
+	if c.Hardened {
+		return args
+	}
+	return append([]string{"sudo", "-Eu", c.HostUser}, args...)
 }
 
 func NewHcContainer(config *pkgInt.HcConfig) *hcContainer {
@@ -43,12 +96,14 @@ func NewHcContainer(config *pkgInt.HcConfig) *hcContainer {
 	}
 	return &hcContainer{
 		HostUser:       getEnvVar("HOST_USER"),
+		HostUid:        getEnvVar("HOST_UID"),
+		HostGid:        getEnvVar("HOST_GID"),
 		UserHome:       config.UserHome,
 		IsOcmLoginOnly: getEnvVar("IS_OCM_LOGIN_ONLY"),
 		customPortMaps: getEnvVar("CUSTOM_PORT_MAPS"),
-		UserBashrcPath: fmt.Sprintf("%s/.bashrc", config.UserHome),
 		OcmCluster:     getEnvVar("OCM_CLUSTER"),
 		OcmToken:       getEnvVar("OCM_TOKEN"),
 		OcmEnvironment: getEnvVar("OCM_ENVIRONMENT"),
+		Hardened:       getEnvVar("IS_HARDENED") == "true",
 	}
 }
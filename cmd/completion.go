@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"fmt"
+	"os/user"
+
+	pkgInt "hc/internal"
+	pkgIntHelper "hc/internal/helpers"
+	pkgIntSession "hc/internal/session"
+
+	"github.com/spf13/cobra"
+)
+
+// Completion functions only read local files and caches, so that they stay
+// fast and work offline.
+
+// Gets the hc config for completions, nil if it cannot be loaded.
+func getCompletionConfig() *pkgInt.HcConfig {
+	if configErr != nil {
+		return nil
+	}
+	config, err := pkgInt.GetHcConfig()
+	if err != nil {
+		return nil
+	}
+	return config
+}
+
+// Completes only the first positional argument.
+func completeFirstArg(complete func() []string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return complete(), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+func completeOcmEnvironments(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return []string{
+		"production\tOCM production",
+		"staging\tOCM staging",
+	}, cobra.ShellCompDirectiveNoFileComp
+}
+
+// Completes the clusters found by earlier cluster lookups in the OCM
+// environment of the command.
+func completeOcmClusters(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	ocmEnvironment, err := cmd.Flags().GetString("ocmEnvironment")
+	if err != nil || len(ocmEnvironment) == 0 {
+		ocmEnvironment = "production"
+	}
+
+	completions := []string{}
+	for _, cluster := range pkgInt.NewOcmCli(ocmEnvironment, pkgInt.OcmCliAlias{}).GetClusterIndex() {
+		completions = append(completions, fmt.Sprintf(
+			"%s\t%s %s %s %s",
+			cluster.Name,
+			cluster.State,
+			cluster.Version,
+			cluster.Region,
+			cluster.Product,
+		))
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeWorkspaceContainers(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	completions := []string{}
+	for _, container := range pkgInt.ListWorkspaceContainers() {
+		completions = append(completions, fmt.Sprintf(
+			"%s\t%s (%s)",
+			container.Name,
+			container.Cluster,
+			container.OcmEnvironment,
+		))
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// Completes the console port of the container given with
+// --consoleContainerName.
+func completeConsolePorts(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	name, _ := cmd.Flags().GetString("consoleContainerName")
+	completions := []string{}
+	for _, container := range pkgInt.ListWorkspaceContainers() {
+		if len(name) == 0 || container.Name == name {
+			completions = append(completions, fmt.Sprintf("%s\t%s", container.ConsolePort, container.Name))
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// Completes the configured OpenShift user and the host user.
+func completeOcUsers(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	users := []string{}
+	if config := getCompletionConfig(); config != nil {
+		users = append(users, config.OcUser, config.HostUser)
+	}
+	if current, err := user.Current(); err == nil {
+		users = append(users, current.Username)
+	}
+
+	completions := []string{}
+	for _, name := range users {
+		if len(name) > 0 && !containsString(completions, name) {
+			completions = append(completions, name)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// Gets the clusters found by earlier lookups in the OCM environment of the
+// workspace container.
+func getContainerOcmClusterNames() []string {
+	names := []string{}
+	for _, cluster := range pkgInt.NewOcmCli(getEnvVar("OCM_ENVIRONMENT"), pkgInt.OcmCliAlias{}).GetClusterIndex() {
+		names = append(names, cluster.Name)
+	}
+	return names
+}
+
+func getWorkspaceClusterNames() []string {
+	names := []string{}
+	clusters, err := pkgIntHelper.LoadWorkspaceClusters()
+	if err != nil {
+		return names
+	}
+	for _, cluster := range clusters {
+		names = append(names, cluster.Name)
+	}
+	return names
+}
+
+func getContextNames() []string {
+	names := []string{}
+	kubeconfig, err := pkgIntHelper.LoadKubeconfig()
+	if err != nil {
+		return names
+	}
+	for _, context := range kubeconfig.Contexts {
+		names = append(names, context.Name)
+	}
+	return names
+}
+
+// Gets the cached namespaces of the current cluster, regardless of their age.
+func getCachedNamespaces() []string {
+	namespaces := []string{}
+	kubeconfig, err := pkgIntHelper.LoadKubeconfig()
+	if err != nil {
+		return namespaces
+	}
+	cluster, err := kubeconfig.GetCurrentCluster()
+	if err != nil {
+		return namespaces
+	}
+	pkgInt.ReadCache(getNamespaceCacheName(cluster.Cluster.Server), 0, &namespaces)
+	return namespaces
+}
+
+func getSessionIDs() []string {
+	ids := []string{}
+	config := getCompletionConfig()
+	if config == nil {
+		return ids
+	}
+	sessions, err := pkgIntSession.List(config.GetSessionRecordingDir())
+	if err != nil {
+		return ids
+	}
+	for _, meta := range sessions {
+		ids = append(ids, meta.ID)
+	}
+	return ids
+}
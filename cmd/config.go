@@ -0,0 +1,413 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	pkgInt "hc/internal"
+	pkgIntHelper "hc/internal/helpers"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	configCmdArgs struct {
+		system  bool
+		project bool
+	}
+
+	configViewCmdArgs struct {
+		showOrigin bool
+	}
+
+	configInitCmdArgs struct {
+		yes   bool
+		force bool
+	}
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manages the hc config",
+	// The config commands are the way to fix a missing or broken config
+	Annotations: map[string]string{
+		configOptionalAnnotation: "true",
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:    "validate",
+	Short:  "Validates the hc config and reports all errors",
+	PreRun: pkgInt.ToggleDebug,
+	Run:    configValidate,
+}
+
+var configSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Prints the JSON Schema of the hc config",
+	Run: func(cmd *cobra.Command, args []string) {
+		schema, err := pkgInt.GetConfigJSONSchema()
+		if err != nil {
+			log.Fatal("Failed to generate config schema: ", err)
+		}
+		fmt.Println(string(schema))
+	},
+}
+
+var configInitCmd = &cobra.Command{
+	Use:    "init",
+	Short:  "Creates the hc config interactively",
+	PreRun: pkgInt.ToggleDebug,
+	Run:    configInit,
+}
+
+var configViewCmd = &cobra.Command{
+	Use:    "view",
+	Short:  "Shows the effective hc config with secrets masked",
+	PreRun: pkgInt.ToggleDebug,
+	Run:    configView,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:    "get <key>",
+	Short:  "Shows the value of a config key, e.g. image.registry",
+	Args:   cobra.ExactArgs(1),
+	PreRun: pkgInt.ToggleDebug,
+	Run:    configGet,
+}
+
+var configSetCmd = &cobra.Command{
+	Use:    "set <key> <value>",
+	Short:  "Sets the value of a config key. Lists are given in yaml syntax, e.g. \"[a, b]\"",
+	Args:   cobra.ExactArgs(2),
+	PreRun: pkgInt.ToggleDebug,
+	Run:    configSet,
+}
+
+var configEditCmd = &cobra.Command{
+	Use:    "edit",
+	Short:  "Opens the hc config in $EDITOR and validates it on save",
+	PreRun: pkgInt.ToggleDebug,
+	Run:    configEdit,
+}
+
+// Gets the path of the config file to create or modify.
+func getConfigFilePath() string {
+	switch {
+	case configCmdArgs.system:
+		return pkgInt.SystemConfigFile
+	case configCmdArgs.project:
+		cwd, err := os.Getwd()
+		if err != nil {
+			log.Fatal("Failed to get the current directory: ", err)
+		}
+		if project := pkgInt.FindProjectConfig(cwd); len(project) > 0 {
+			return project
+		}
+		return filepath.Join(cwd, pkgInt.ProjectConfigFileName)
+	}
+	return getUserConfigFile()
+}
+
+func configValidate(cmd *cobra.Command, args []string) {
+	if configErr != nil {
+		fmt.Fprintln(os.Stderr, configErr)
+		pkgIntHelper.Exit(1)
+	}
+	fmt.Printf("%s: valid\n", strings.Join(pkgInt.GetConfigFiles(), ", "))
+}
+
+// Prompts for a value on stdin, returning the default on empty input.
+func promptValue(reader *bufio.Reader, label string, defaultValue string) string {
+	if configInitCmdArgs.yes {
+		return defaultValue
+	}
+
+	fmt.Printf("%s [%s]: ", label, defaultValue)
+	line, err := reader.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		log.Fatal("Failed to read input: ", err)
+	}
+	line = strings.TrimSpace(line)
+	if len(line) == 0 {
+		return defaultValue
+	}
+	return line
+}
+
+// Guesses the production and staging backplane config files.
+func detectBackplaneConfigs(home string) (string, string) {
+	prod := "config.prod.json"
+	stage := "config.stage.json"
+
+	files, err := filepath.Glob(filepath.Join(home, ".config", "backplane", "*.json"))
+	if err != nil {
+		return prod, stage
+	}
+	for _, file := range files {
+		name := strings.ToLower(filepath.Base(file))
+		switch {
+		case strings.Contains(name, "stag"):
+			stage = filepath.Base(file)
+		case strings.Contains(name, "prod"), name == "config.json":
+			prod = filepath.Base(file)
+		}
+	}
+	return prod, stage
+}
+
+func detectToolVersion(repo string) string {
+	version, err := pkgIntHelper.GetLatestGithubRelease(repo)
+	if err != nil {
+		log.Debugf("Failed to get the latest release of %s: %v", repo, err)
+		return ""
+	}
+	return version
+}
+
+func configInit(cmd *cobra.Command, args []string) {
+	path := getConfigFilePath()
+	if _, err := os.Stat(path); err == nil && !configInitCmdArgs.force {
+		log.Fatalf("%s already exists. Use \"hc config edit\" or pass --force to overwrite it", path)
+	}
+
+	currentUser, err := user.Current()
+	if err != nil {
+		log.Fatal("Failed to get the current user: ", err)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		log.Fatal("Failed to get the user home directory: ", err)
+	}
+	backplaneProd, backplaneStage := detectBackplaneConfigs(home)
+
+	fmt.Println("Detecting the latest tool versions...")
+	ocmCLIVersion := detectToolVersion("openshift-online/ocm-cli")
+	backplaneCLIVersion := detectToolVersion("openshift/backplane-cli")
+
+	reader := bufio.NewReader(os.Stdin)
+	values := [][]string{
+		{"hostUser", promptValue(reader, "Host user", currentUser.Username)},
+		{"ocUser", promptValue(reader, "OpenShift user", currentUser.Username)},
+		{"userHome", promptValue(reader, "Home directory", home)},
+		{"backplaneConfigProd", promptValue(reader, "Production backplane config (~/.config/backplane)", backplaneProd)},
+		{"backplaneConfigStage", promptValue(reader, "Staging backplane config (~/.config/backplane)", backplaneStage)},
+		{"baseImageVersion", promptValue(reader, "Fedora base image version", viper.GetString("baseImageVersion"))},
+		{"ocmCLIVersion", promptValue(reader, "OCM CLI version", ocmCLIVersion)},
+		{"backplaneCLIVersion", promptValue(reader, "Backplane CLI version", backplaneCLIVersion)},
+	}
+
+	doc, err := pkgInt.LoadConfigDocument("")
+	if err != nil {
+		log.Fatal(err)
+	}
+	var unset []string
+	for _, value := range values {
+		if len(value[1]) == 0 {
+			unset = append(unset, value[0])
+			continue
+		}
+		if err := pkgInt.SetConfigValue(doc, value[0], value[1]); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := pkgInt.WriteConfigDocument(path, doc); err != nil {
+		log.Fatalf("Failed to write %s: %v", path, err)
+	}
+	fmt.Printf("Wrote %s\n", path)
+
+	for _, key := range unset {
+		fmt.Fprintf(os.Stderr, "Please set %s with \"hc config set %s <value>\"\n", key, key)
+	}
+	if errs := pkgInt.ValidateConfigFile(path, true); len(errs) > 0 {
+		fmt.Fprintf(os.Stderr, "Please fix the following before logging in:\n%s\n", errs)
+	}
+}
+
+func configView(cmd *cobra.Command, args []string) {
+	var doc *yaml.Node
+	var err error
+	if configCmdArgs.system || configCmdArgs.project {
+		path := getConfigFilePath()
+		doc, err = pkgInt.LoadConfigDocument(path)
+	} else {
+		doc, err = pkgInt.GetEffectiveConfigDocument(configViewCmdArgs.showOrigin)
+	}
+	if err != nil {
+		log.Fatal("Failed to load config: ", err)
+	}
+	pkgInt.MaskConfigSecrets(doc)
+
+	out, err := pkgInt.EncodeConfigDocument(doc)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(string(out))
+}
+
+func configGet(cmd *cobra.Command, args []string) {
+	key := args[0]
+	if err := pkgInt.ValidateConfigKey(key); err != nil {
+		log.Fatal(err)
+	}
+	if !viper.IsSet(key) {
+		pkgIntHelper.Exit(1)
+	}
+
+	value := viper.Get(key)
+	switch value.(type) {
+	case []interface{}, map[string]interface{}:
+		// Viper lowercases the keys of mappings, the node has the schema
+		// names so that the value can be pasted back into a config file
+		node, err := pkgInt.GetConfigValueNode(key)
+		if err != nil {
+			log.Fatal(err)
+		}
+		out, err := pkgInt.EncodeConfigDocument(node)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(string(out))
+	default:
+		fmt.Println(value)
+	}
+}
+
+func configSet(cmd *cobra.Command, args []string) {
+	key, value := args[0], args[1]
+	path := getConfigFilePath()
+
+	doc, err := pkgInt.LoadConfigDocument(path)
+	if err != nil {
+		log.Fatalf("Failed to load %s: %v", path, err)
+	}
+	if err := pkgInt.SetConfigValue(doc, key, value); err != nil {
+		log.Fatal(err)
+	}
+	if err := pkgInt.WriteConfigDocument(path, doc); err != nil {
+		log.Fatalf("Failed to write %s: %v", path, err)
+	}
+
+	// Only report errors of the key that was set, the rest of the
+	// config may still be in the making
+	for _, err := range pkgInt.ValidateConfigFile(path, !isInContainer()) {
+		if strings.HasPrefix(strings.ToLower(err.Key), strings.ToLower(key)) {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", err)
+		}
+	}
+}
+
+func getEditor() string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if editor := getEnvVar(env); len(editor) > 0 {
+			return editor
+		}
+	}
+	return "vi"
+}
+
+func configEdit(cmd *cobra.Command, args []string) {
+	path := getConfigFilePath()
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Failed to read %s: %v", path, err)
+	}
+
+	// Edit a copy so that an invalid config is never saved
+	tmp, err := os.CreateTemp("", "hc-config-*.yaml")
+	if err != nil {
+		log.Fatal("Failed to create a temporary file: ", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(content)
+	tmp.Close()
+	if err != nil {
+		log.Fatal("Failed to write a temporary file: ", err)
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		editorCmd := strings.Fields(getEditor())
+		editorCmd = append(editorCmd, tmp.Name())
+		err = pkgIntHelper.RunInteractive(cmd.Context(), editorCmd[0], editorCmd[1:]...)
+		if err != nil {
+			log.Fatal("Editor failed: ", err)
+		}
+
+		errs := pkgInt.ValidateConfigFile(tmp.Name(), !isInContainer())
+		if len(errs) == 0 {
+			break
+		}
+		fmt.Fprintln(os.Stderr, errs)
+		fmt.Print("Edit again? [Y/n]: ")
+		answer, err := reader.ReadString('\n')
+		if err != nil || strings.HasPrefix(strings.ToLower(strings.TrimSpace(answer)), "n") {
+			log.Fatalf("Discarded changes to %s", path)
+		}
+	}
+
+	edited, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		log.Fatal("Failed to read the edited config: ", err)
+	}
+	if err := pkgIntHelper.WriteFileAtomic(path, edited, 0600); err != nil {
+		log.Fatalf("Failed to write %s: %v", path, err)
+	}
+	fmt.Printf("Saved %s\n", path)
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configSchemaCmd)
+	configCmd.AddCommand(configInitCmd)
+	configCmd.AddCommand(configViewCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configEditCmd)
+
+	configCmd.PersistentFlags().BoolVar(
+		&configCmdArgs.system,
+		"system",
+		false,
+		fmt.Sprintf("Use the system config file %s.", pkgInt.SystemConfigFile),
+	)
+	configCmd.PersistentFlags().BoolVar(
+		&configCmdArgs.project,
+		"project",
+		false,
+		fmt.Sprintf("Use the closest project %s file.", pkgInt.ProjectConfigFileName),
+	)
+	configCmd.MarkFlagsMutuallyExclusive("system", "project")
+
+	configViewCmd.Flags().BoolVar(
+		&configViewCmdArgs.showOrigin,
+		"show-origin",
+		false,
+		"Show the file, environment variable or default each value comes from.",
+	)
+
+	flags := configInitCmd.Flags()
+	flags.BoolVarP(
+		&configInitCmdArgs.yes,
+		"yes",
+		"y",
+		false,
+		"Accept the detected values without prompting.",
+	)
+	flags.BoolVar(
+		&configInitCmdArgs.force,
+		"force",
+		false,
+		"Overwrite an existing config file.",
+	)
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// Lists and mappings are printed with the key names of the config file.
+func TestConfigGet(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Cleanup(viper.Reset)
+	viper.Reset()
+	setConfigState(t, false, nil)
+	content := "customDirMaps:\n  - hostDir: /tmp\n    containerDir: /mnt/tmp\n    fileAttrs: ro\nimage:\n  tlsVerify: false\n"
+	if err := os.WriteFile(filepath.Join(home, ".hc.yaml"), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, expected := range map[string]string{
+		"customDirMaps":   "- hostDir: /tmp\n  containerDir: /mnt/tmp\n  fileAttrs: ro\n",
+		"image":           "repository: hc\ntlsVerify: false\n",
+		"image.tlsVerify": "false\n",
+	} {
+		if out := executeRoot(t, "config", "get", key); out != expected {
+			t.Errorf("%s:\n%s\nexpected:\n%s", key, out, expected)
+		}
+	}
+}
@@ -49,27 +49,17 @@ type ocDeployment struct {
 }
 
 func launchOpenShiftConsole(cmd *cobra.Command, args []string) {
-	config := pkgInt.GetHcConfig()
+	config := getHcConfig()
 	ocUser := config.OcUser
 	userHome := config.UserHome
 	ocmCliAlias := config.OcmCliAlias
 
 	ocmEnvironment := "production"
 	if len(consoleCmdArgs.ocmEnvironment) > 0 {
-		ocmEnvironment = loginCmdArgs.ocmEnvironment
+		ocmEnvironment = consoleCmdArgs.ocmEnvironment
 	}
 
-	var err error
-	var out []byte
-	switch {
-	case ocmEnvironment == "production" && len(ocmCliAlias.OcmProduction) > 0:
-		out, err = pkgIntHelper.RunCommandPipeStdin("sh", ocmCliAlias.OcmProduction, "post", "/api/accounts_mgmt/v1/access_token")
-	case ocmEnvironment == "staging" && len(ocmCliAlias.OcmStaging) > 0:
-		out, err = pkgIntHelper.RunCommandPipeStdin("sh", ocmCliAlias.OcmStaging, "post", "/api/accounts_mgmt/v1/access_token")
-	default:
-		out, err = pkgIntHelper.RunCommandPipeStdin("ocm", "post", "/api/accounts_mgmt/v1/access_token")
-
-	}
+	out, err := newOcmCli(ocmEnvironment, ocmCliAlias).GetAccessToken()
 	if err != nil {
 		logger.Fatal("Failed to get access token: ", err)
 	}
@@ -77,12 +67,17 @@ func launchOpenShiftConsole(cmd *cobra.Command, args []string) {
 	path := fmt.Sprintf("%s/.kube/ocm-pull-secret-config.json", userHome)
 	logger.Debugf("ocm-pull-secret path: %s", path)
 
-	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
 		logger.Fatal("Failed to open ocm-pull-secret: ", err)
 	}
-	defer file.Close()
-	file.WriteString(string(out))
+	removePullSecret := pkgIntHelper.AddCleanup("remove the ocm pull secret", func() { os.Remove(path) })
+	defer removePullSecret()
+	_, err = file.WriteString(string(out))
+	file.Close()
+	if err != nil {
+		logger.Fatal("Failed to write ocm-pull-secret: ", err)
+	}
 
 	containerName := fmt.Sprintf("%s-openshift-console", consoleCmdArgs.consoleContainerName)
 	kubeConfigFileName := fmt.Sprintf("%s/.kube/ocm-pull-secret-config.json", userHome)
@@ -101,21 +96,24 @@ func launchOpenShiftConsole(cmd *cobra.Command, args []string) {
 		kubeConfigFileName,
 	}
 
-	out, err = pkgIntHelper.RunCommandOutput(
-		"podman",
-		"exec",
-		"-it",
-		"--user",
-		ocUser,
-		consoleCmdArgs.consoleContainerName,
-		"oc",
-		"get",
-		"deployment",
-		"console",
-		"-n",
-		"openshift-console",
-		"-o",
-		"json",
+	ctx := cmd.Context()
+	ce := newContainerEngine()
+	out, err = pkgIntHelper.RunOutput(
+		ctx,
+		ce.GetExecName(),
+		ce.GetExecCmd(
+			consoleCmdArgs.consoleContainerName,
+			ocUser,
+			true,
+			"oc",
+			"get",
+			"deployment",
+			"console",
+			"-n",
+			"openshift-console",
+			"-o",
+			"json",
+		)...,
 	)
 	if err != nil {
 		logger.Fatal("Failed to run command: ", err)
@@ -134,53 +132,55 @@ func launchOpenShiftConsole(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	out, err = pkgIntHelper.RunCommandOutput(
-		"podman",
-		"exec",
-		"-it",
-		"--user",
-		ocUser,
-		consoleCmdArgs.consoleContainerName,
-		"oc",
-		"config",
-		"view",
-		"-o",
-		"json",
+	out, err = pkgIntHelper.RunOutput(
+		ctx,
+		ce.GetExecName(),
+		ce.GetExecCmd(
+			consoleCmdArgs.consoleContainerName,
+			ocUser,
+			false,
+			"cat",
+			fmt.Sprintf("%s/.kube/config", userHome),
+		)...,
 	)
 	if err != nil {
-		logger.Fatal("Failed to run command: ", err)
+		logger.Fatal("Failed to read the kubeconfig: ", err)
 	}
 
-	var clusterConfig pkgIntHelper.OcConfig
-	err = json.Unmarshal(out, &clusterConfig)
+	kubeconfig, err := pkgIntHelper.ParseKubeconfig(out)
 	if err != nil {
-		logger.Fatal("Failed to unmarshal: ", err)
+		logger.Fatal("Failed to parse the kubeconfig: ", err)
+	}
+	cluster, err := kubeconfig.GetCurrentCluster()
+	if err != nil {
+		logger.Fatal("Failed to get the current cluster: ", err)
 	}
 
 	imagePullArgs := append(pullArgs, consoleImage)
-	_, err = pkgIntHelper.RunCommandOutput(
-		"podman",
+	_, err = pkgIntHelper.RunOutput(
+		ctx,
+		ce.GetExecName(),
 		imagePullArgs...,
 	)
 	if err != nil {
 		logger.Fatal("Failed to run command: ", err)
 	}
-	cluster := clusterConfig.Clusters[0]
-	apiUrl := cluster.ClusterUrls.Server
+	apiUrl := cluster.Cluster.Server
 	alertManagerUrl := strings.Replace(apiUrl, "/backplane/cluster", "/backplane/alertmanager", 1)
 	thanosUrl := strings.Replace(apiUrl, "/backplane/cluster", "/backplane/thanos", 1)
 	alertManagerUrl = strings.TrimRight(alertManagerUrl, "/")
 	thanosUrl = strings.TrimRight(thanosUrl, "/")
 
-	out, err = pkgIntHelper.RunCommandOutput(
-		"podman",
-		"exec",
-		"-it",
-		"--user",
-		ocUser,
-		consoleCmdArgs.consoleContainerName,
-		"ocm",
-		"token",
+	out, err = pkgIntHelper.RunOutput(
+		ctx,
+		ce.GetExecName(),
+		ce.GetExecCmd(
+			consoleCmdArgs.consoleContainerName,
+			ocUser,
+			true,
+			"ocm",
+			"token",
+		)...,
 	)
 	if err != nil {
 		logger.Fatal("Failed to run command: ", err)
@@ -220,8 +220,17 @@ func launchOpenShiftConsole(cmd *cobra.Command, args []string) {
 		"-v",
 		"5",
 	)
-	pkgIntHelper.RunCommandWithOsFiles("podman", os.Stdout, os.Stderr, os.Stdin, runArgs...)
-
+	// The sidecar is removed when it exits, unless podman was killed
+	removeSidecar := pkgIntHelper.AddCleanup("remove the console container", func() {
+		runCleanupCommand(ce.GetExecName(), ce.GetRemoveCmd(containerName)...)
+	})
+	defer removeSidecar()
+	consoleRunCmd := pkgIntHelper.NewCommand(ce.GetExecName(), runArgs...)
+	consoleRunCmd.Interactive = true
+	consoleRunCmd.Secrets = []string{ocmToken}
+	if _, err := pkgIntHelper.Run(ctx, consoleRunCmd); err != nil {
+		logger.Fatal("Failed to run the console: ", err)
+	}
 }
 
 func init() {
@@ -235,6 +244,7 @@ func init() {
 		"",
 		"The hc container name that is logged into an OpenShift cluster.",
 	)
+	consoleCmd.RegisterFlagCompletionFunc("consoleContainerName", completeWorkspaceContainers)
 
 	flags.StringVarP(
 		&consoleCmdArgs.consoleContainerPort,
@@ -243,14 +253,16 @@ func init() {
 		"",
 		"The hc container port that is logged into an OpenShift cluster.",
 	)
+	consoleCmd.RegisterFlagCompletionFunc("consoleContainerPort", completeConsolePorts)
 
 	flags.StringVarP(
-		&loginCmdArgs.ocmEnvironment,
+		&consoleCmdArgs.ocmEnvironment,
 		"ocmEnvironment",
 		"e",
 		"production",
 		"OCM environemnt (production, staging)",
 	)
+	consoleCmd.RegisterFlagCompletionFunc("ocmEnvironment", completeOcmEnvironments)
 
 	consoleCmd.MarkFlagRequired("consoleContainerName")
 	consoleCmd.MarkFlagRequired("consoleContainerPort")
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pkgIntHelper "hc/internal/helpers"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const fakeConsoleToken = "secret-console-token"
+
+// Responds to the commands of hc console as podman and a workspace logged in
+// to a backplane cluster would.
+func respondAsConsoleHost(ctx context.Context, command *pkgIntHelper.Command) *pkgIntHelper.Result {
+	args := strings.Join(command.Args, " ")
+	switch {
+	case strings.HasSuffix(args, "post /api/accounts_mgmt/v1/access_token"):
+		return &pkgIntHelper.Result{Stdout: []byte(`{"auths": {"quay.io": {"auth": "c2VjcmV0"}}}`)}
+	case strings.HasSuffix(args, "oc get deployment console -n openshift-console -o json"):
+		return &pkgIntHelper.Result{Stdout: []byte(`{"spec": {"template": {"spec": {"containers": [
+			{"name": "console", "image": "quay.io/openshift/console@sha256:0123"},
+			{"name": "other", "image": "quay.io/openshift/other"}
+		]}}}}`)}
+	case command.Args[len(command.Args)-2] == "cat" && strings.HasSuffix(args, "/.kube/config"):
+		return &pkgIntHelper.Result{Stdout: []byte(`apiVersion: v1
+clusters:
+- name: my-cluster
+  cluster:
+    server: https://api.backplane.example.com/backplane/cluster/1a2b3c/
+contexts:
+- name: my-cluster
+  context:
+    cluster: my-cluster
+    user: my-cluster
+current-context: my-cluster
+`)}
+	case strings.HasSuffix(args, "ocm token"):
+		return &pkgIntHelper.Result{Stdout: []byte(fakeConsoleToken + "\n")}
+	}
+	return &pkgIntHelper.Result{}
+}
+
+func TestConsole(t *testing.T) {
+	host := newFakeHost(t, respondAsConsoleHost)
+	if err := os.MkdirAll(filepath.Join(host.home, ".kube"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	previousArgs := consoleCmdArgs
+	t.Cleanup(func() { consoleCmdArgs = previousArgs })
+	consoleCmdArgs.consoleContainerName = "hc-my-cluster-1a2b3c"
+	consoleCmdArgs.consoleContainerPort = "9000"
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	launchOpenShiftConsole(cmd, nil)
+
+	checkGolden(t, "console-commands", host.normalize(host.runner.String()+"\n"))
+
+	recorded := host.runner.Commands()
+	run := recorded[len(recorded)-2]
+	if !strings.Contains(strings.Join(run.Args, " "), "-k8s-auth-bearer-token "+fakeConsoleToken) {
+		t.Errorf("token not passed to the console: %v", run.Args)
+	}
+	if strings.Contains(host.runner.String(), fakeConsoleToken) {
+		t.Errorf("token not masked in the recorded commands:\n%s", host.runner)
+	}
+	if _, err := os.Stat(filepath.Join(host.home, ".kube", "ocm-pull-secret-config.json")); !os.IsNotExist(err) {
+		t.Errorf("pull secret left behind: %v", err)
+	}
+}
+
+// The pull secret is taken from the OCM environment of the console.
+func TestConsoleOcmEnvironment(t *testing.T) {
+	host := newFakeHost(t, respondAsConsoleHost)
+	viper.Set("ocmCLIAlias.staging", "/opt/ocm-staging.sh")
+	if err := os.MkdirAll(filepath.Join(host.home, ".kube"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	previousArgs := consoleCmdArgs
+	t.Cleanup(func() { consoleCmdArgs = previousArgs })
+	consoleCmdArgs.consoleContainerName = "hc-my-cluster-1a2b3c"
+	consoleCmdArgs.consoleContainerPort = "9000"
+	consoleCmdArgs.ocmEnvironment = "staging"
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	launchOpenShiftConsole(cmd, nil)
+
+	if first := host.runner.Commands()[0].String(); !strings.HasPrefix(first, "sh /opt/ocm-staging.sh ") {
+		t.Errorf("access token from %q, expected the staging ocm CLI", first)
+	}
+}
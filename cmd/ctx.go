@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+
+	pkgInt "hc/internal"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ctxCmdArgs struct {
+		list bool
+	}
+)
+
+var ctxCmd = &cobra.Command{
+	Use:   "ctx [name|-]",
+	Short: "Switches the current kubeconfig context",
+	Long: `Switches the current kubeconfig context. The name may be a fuzzy match of a
+context name, "-" switches back to the previous context. Without a name, a
+context is picked interactively, or the contexts are listed if there is no
+terminal.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeFirstArg(getContextNames),
+	PreRun:            pkgInt.ToggleDebug,
+	Run:               switchContext,
+	Annotations: map[string]string{
+		configOptionalAnnotation: "true",
+	},
+}
+
+func switchContext(cmd *cobra.Command, args []string) {
+	kubeconfig := loadKubeconfig()
+	contexts := []string{}
+	for _, context := range kubeconfig.Contexts {
+		contexts = append(contexts, context.Name)
+	}
+
+	if ctxCmdArgs.list || (len(args) == 0 && !isTerminal()) {
+		printItems(contexts, kubeconfig.CurrentContext)
+		return
+	}
+
+	state := loadKubeconfigState()
+	var target string
+	var err error
+	switch {
+	case len(args) == 0:
+		target, err = pickItem(cmd.Context(), "context", contexts, "")
+	case args[0] == "-":
+		target = state.PreviousContext
+		if len(target) == 0 {
+			log.Fatal("No previous context")
+		}
+	default:
+		target, err = resolveItem(cmd.Context(), "context", contexts, args[0])
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if target == kubeconfig.CurrentContext {
+		fmt.Printf("Already on context %q\n", target)
+		return
+	}
+	previous := kubeconfig.CurrentContext
+	if err := kubeconfig.SetCurrentContext(target); err != nil {
+		log.Fatal("Failed to switch context: ", err)
+	}
+	state.PreviousContext = previous
+	saveKubeconfigState(state)
+	fmt.Printf("Switched to context %q\n", target)
+}
+
+func init() {
+	rootCmd.AddCommand(ctxCmd)
+	ctxCmd.Flags().BoolVarP(
+		&ctxCmdArgs.list,
+		"list",
+		"l",
+		false,
+		"List the contexts",
+	)
+}
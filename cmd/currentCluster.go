@@ -1,30 +1,66 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 
-	pkgIntHelper "hc/internal/helpers"
+	pkgInt "hc/internal"
 
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
+var (
+	currentClusterCmdArgs struct {
+		format string
+	}
+)
+
 // currentClusterCmd represents the currentCluster command
 var currentClusterCmd = &cobra.Command{
 	Use:   "currentCluster",
 	Short: "Shows the current cluster where a user is logged in.",
+	Long: `Shows the current cluster where a user is logged in. The cluster is resolved
+from the backplane URL of the current kubeconfig context and looked up in OCM.
+Lookups are cached for a day.`,
+	PreRun: pkgInt.ToggleDebug,
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := checkContainerCommand(); err != nil {
 			return
 		}
 
-		cluster, err := pkgIntHelper.OcGetCurrentOcmCluster()
+		cluster, err := pkgInt.GetCurrentOcmCluster()
 		if err != nil {
-			fmt.Print("")
+			log.Debugf("Failed to resolve the current cluster: %v", err)
+		}
+		if cluster == nil {
+			return
+		}
+
+		switch currentClusterCmdArgs.format {
+		case "name":
+			fmt.Print(cluster.Name)
+		case "id":
+			fmt.Print(cluster.ID)
+		case "json":
+			out, err := json.MarshalIndent(cluster, "", "  ")
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(string(out))
+		default:
+			log.Fatalf("Unsupported format: %s", currentClusterCmdArgs.format)
 		}
-		fmt.Print(cluster)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(currentClusterCmd)
+	currentClusterCmd.Flags().StringVarP(
+		&currentClusterCmdArgs.format,
+		"format",
+		"f",
+		"name",
+		"Output format (name, id, json)",
+	)
 }
@@ -37,7 +37,7 @@ func init() {
 		"ocUser",
 		"u",
 		"",
-		"Run as OpenShift user.",
+		"OpenShift user whose kubeconfig is read, by default the current user.",
 	)
-	currentNamespaceCmd.MarkFlagRequired("ocUser")
+	currentNamespaceCmd.RegisterFlagCompletionFunc("ocUser", completeOcUsers)
 }
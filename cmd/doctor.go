@@ -0,0 +1,425 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/user"
+	"runtime"
+	"strings"
+	"time"
+
+	pkgInt "hc/internal"
+	pkgIntHelper "hc/internal/helpers"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+
+	// Images older than this are reported as stale
+	imageMaxAge = 30 * 24 * time.Hour
+)
+
+var (
+	doctorCmdArgs struct {
+		output string
+	}
+)
+
+var doctorCmd = &cobra.Command{
+	Use:    "doctor",
+	Short:  "Diagnoses the host environment required to run hc.",
+	PreRun: pkgInt.ToggleDebug,
+	Run:    doctor,
+	Annotations: map[string]string{
+		configOptionalAnnotation: "true",
+	},
+}
+
+type doctorCheckResult struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+type doctorReport struct {
+	Time   time.Time           `json:"time"`
+	OS     string              `json:"os"`
+	Arch   string              `json:"arch"`
+	Config []string            `json:"config"`
+	Checks []doctorCheckResult `json:"checks"`
+}
+
+type doctorCheck struct {
+	name string
+	// Whether the check can only run with a loaded config
+	needsConfig bool
+	run         func(ctx context.Context, config *pkgInt.HcConfig) doctorCheckResult
+}
+
+var doctorChecks = []doctorCheck{
+	{name: "Container engine", run: checkContainerEngine},
+	{name: "Rootless setup", run: checkRootless},
+	{name: "Config file", run: checkConfigFile},
+	{name: "Required config", run: checkRequiredConfig},
+	{name: "Backplane config", needsConfig: true, run: checkBackplaneConfig},
+	{name: "OCM token", needsConfig: true, run: checkOcmToken},
+	{name: "hc image", needsConfig: true, run: checkImage},
+	{name: "Free ports", run: checkFreePorts},
+}
+
+// Gets the config of the checks, nil if it could not be loaded. An invalid
+// config is still decoded, so that the checks can tell what is wrong with it.
+func getDoctorConfig() *pkgInt.HcConfig {
+	if !configLoaded {
+		return nil
+	}
+	config, err := pkgInt.GetHcConfig()
+	if err != nil {
+		log.Debugf("Failed to decode the config: %v", err)
+		return nil
+	}
+	return config
+}
+
+func doctor(cmd *cobra.Command, args []string) {
+	if doctorCmdArgs.output != "text" && doctorCmdArgs.output != "json" {
+		log.Fatalf("Unsupported output format: %s", doctorCmdArgs.output)
+	}
+
+	report := doctorReport{
+		Time:   time.Now().UTC(),
+		OS:     runtime.GOOS,
+		Arch:   runtime.GOARCH,
+		Config: pkgInt.GetConfigFiles(),
+	}
+
+	config := getDoctorConfig()
+	failed := false
+	for _, check := range doctorChecks {
+		var result doctorCheckResult
+		if check.needsConfig && config == nil {
+			result = doctorCheckResult{
+				Status:  checkWarn,
+				Message: "Skipped because the hc config could not be loaded",
+				Hint:    "Fix the \"Config file\" and \"Required config\" checks first",
+			}
+		} else {
+			result = check.run(cmd.Context(), config)
+		}
+		result.Name = check.name
+		failed = failed || result.Status == checkFail
+		report.Checks = append(report.Checks, result)
+	}
+
+	if doctorCmdArgs.output == "json" {
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			log.Fatal("Failed to marshal report: ", err)
+		}
+		fmt.Println(string(out))
+	} else {
+		for _, result := range report.Checks {
+			fmt.Printf("[%s] %s: %s\n", strings.ToUpper(result.Status), result.Name, result.Message)
+			if len(result.Hint) > 0 && result.Status != checkPass {
+				fmt.Printf("       hint: %s\n", result.Hint)
+			}
+		}
+	}
+
+	if failed {
+		pkgIntHelper.Exit(1)
+	}
+}
+
+func checkContainerEngine(ctx context.Context, config *pkgInt.HcConfig) doctorCheckResult {
+	ce := newContainerEngine()
+	path, err := exec.LookPath(ce.GetExecName())
+	if err != nil {
+		return doctorCheckResult{
+			Status:  checkFail,
+			Message: fmt.Sprintf("%s not found in PATH", ce.GetExecName()),
+			Hint:    fmt.Sprintf("Install %s, e.g. \"sudo dnf install -y %s\"", ce.GetExecName(), ce.GetExecName()),
+		}
+	}
+	return doctorCheckResult{
+		Status:  checkPass,
+		Message: fmt.Sprintf("%s found at %s", ce.GetExecName(), path),
+	}
+}
+
+// Checks whether a user has subordinate ids in a subuid/subgid file.
+func hasSubIds(path string, username string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), username+":") {
+			return true
+		}
+	}
+	return false
+}
+
+func checkRootless(ctx context.Context, config *pkgInt.HcConfig) doctorCheckResult {
+	ce := newContainerEngine()
+	out, err := pkgIntHelper.RunOutput(ctx, ce.GetExecName(), ce.GetRootlessInfoCmd()...)
+	if err != nil {
+		return doctorCheckResult{
+			Status:  checkFail,
+			Message: fmt.Sprintf("Failed to query %s info: %v", ce.GetExecName(), err),
+			Hint:    fmt.Sprintf("Run \"%s info\" and fix the reported errors", ce.GetExecName()),
+		}
+	}
+
+	if strings.TrimSpace(string(out)) != "true" {
+		return doctorCheckResult{
+			Status:  checkWarn,
+			Message: fmt.Sprintf("%s is running rootful", ce.GetExecName()),
+			Hint:    "Run hc as a regular user so that workspaces run in rootless containers",
+		}
+	}
+
+	currentUser, err := user.Current()
+	if err != nil {
+		return doctorCheckResult{
+			Status:  checkWarn,
+			Message: fmt.Sprintf("Failed to get the current user: %v", err),
+		}
+	}
+	for _, path := range []string{"/etc/subuid", "/etc/subgid"} {
+		if !hasSubIds(path, currentUser.Username) {
+			return doctorCheckResult{
+				Status:  checkFail,
+				Message: fmt.Sprintf("No subordinate ids for %s in %s", currentUser.Username, path),
+				Hint: fmt.Sprintf(
+					"Run \"sudo usermod --add-subuids 100000-165535 --add-subgids 100000-165535 %s\"",
+					currentUser.Username,
+				),
+			}
+		}
+	}
+
+	return doctorCheckResult{
+		Status:  checkPass,
+		Message: fmt.Sprintf("%s is running rootless", ce.GetExecName()),
+	}
+}
+
+func checkConfigFile(ctx context.Context, config *pkgInt.HcConfig) doctorCheckResult {
+	files := pkgInt.GetConfigFiles()
+	if len(files) == 0 {
+		return doctorCheckResult{
+			Status:  checkFail,
+			Message: "No hc config file found",
+			Hint:    "Run \"hc config init\" or pass a config file with --config",
+		}
+	}
+
+	return doctorCheckResult{
+		Status:  checkPass,
+		Message: fmt.Sprintf("Using %s", strings.Join(files, ", ")),
+	}
+}
+
+func checkRequiredConfig(ctx context.Context, config *pkgInt.HcConfig) doctorCheckResult {
+	if configErr != nil {
+		return doctorCheckResult{
+			Status:  checkFail,
+			Message: configErr.Error(),
+			Hint:    "Run \"hc config edit\" to fix the config",
+		}
+	}
+	return doctorCheckResult{
+		Status:  checkPass,
+		Message: "All required keys are set",
+	}
+}
+
+func checkBackplaneConfig(ctx context.Context, config *pkgInt.HcConfig) doctorCheckResult {
+	var missing []string
+	var invalid []string
+	for _, environment := range []string{"production", "staging"} {
+		path := config.GetBackplaneConfigFile(environment)
+		if !fileExists(path) {
+			missing = append(missing, path)
+		} else if _, err := pkgInt.ValidateBackplaneConfigFile(path, true); err != nil {
+			invalid = append(invalid, err.Error())
+		}
+	}
+
+	if len(missing) > 0 {
+		return doctorCheckResult{
+			Status:  checkFail,
+			Message: fmt.Sprintf("Missing backplane config files: %s", strings.Join(missing, ", ")),
+			Hint:    "Create them with \"hc backplane config init --env <environment>\" or fix backplaneConfigProd/backplaneConfigStage in the hc config",
+		}
+	}
+	if len(invalid) > 0 {
+		return doctorCheckResult{
+			Status:  checkFail,
+			Message: strings.Join(invalid, "; "),
+			Hint:    "Check them with \"hc backplane config validate --env <environment>\"",
+		}
+	}
+	return doctorCheckResult{
+		Status:  checkPass,
+		Message: fmt.Sprintf("Found %s and %s", config.BackplaneConfigProd, config.BackplaneConfigStage),
+	}
+}
+
+func checkOcmToken(ctx context.Context, config *pkgInt.HcConfig) doctorCheckResult {
+	if len(config.OcmLongLivedTokenPath) > 0 {
+		content, err := os.ReadFile(config.OcmLongLivedTokenPath)
+		if err != nil || len(strings.TrimSpace(string(content))) == 0 {
+			return doctorCheckResult{
+				Status:  checkFail,
+				Message: fmt.Sprintf("Failed to read long lived token file %s", config.OcmLongLivedTokenPath),
+				Hint:    "Fix ocmLongLivedTokenPath in the hc config or remove it to use \"ocm token\"",
+			}
+		}
+		return doctorCheckResult{
+			Status:  checkPass,
+			Message: fmt.Sprintf("Using long lived token file %s", config.OcmLongLivedTokenPath),
+		}
+	}
+
+	var failed []string
+	for _, environment := range []string{"production", "staging"} {
+		_, err := pkgIntHelper.OcmGetOCMToken(
+			ctx,
+			environment,
+			config.OcmCliAlias.OcmProduction,
+			config.OcmCliAlias.OcmStaging,
+		)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", environment, strings.TrimSpace(err.Error())))
+		}
+	}
+
+	if len(failed) > 0 {
+		return doctorCheckResult{
+			Status:  checkFail,
+			Message: fmt.Sprintf("\"ocm token\" failed for %s", strings.Join(failed, "; ")),
+			Hint:    pkgInt.ErrOCMTokenFetchMsg,
+		}
+	}
+	return doctorCheckResult{
+		Status:  checkPass,
+		Message: "\"ocm token\" works for production and staging",
+	}
+}
+
+func checkImage(ctx context.Context, config *pkgInt.HcConfig) doctorCheckResult {
+	ce := newContainerEngine()
+
+	var image string
+	for _, candidate := range []string{config.GetRemoteImage(), config.GetLocalImage()} {
+		if len(candidate) > 0 && imageExists(ctx, ce, candidate) {
+			image = candidate
+			break
+		}
+	}
+	if len(image) == 0 {
+		return doctorCheckResult{
+			Status:  checkWarn,
+			Message: fmt.Sprintf("Image %s not found, it will be pulled or built on login", config.GetLocalImage()),
+			Hint:    "Run \"hc build\" to build it ahead of time",
+		}
+	}
+
+	out, err := pkgIntHelper.RunOutput(ctx, ce.GetExecName(), ce.GetImageCreatedCmd(image)...)
+	if err != nil {
+		return doctorCheckResult{
+			Status:  checkWarn,
+			Message: fmt.Sprintf("Failed to inspect image %s: %v", image, err),
+		}
+	}
+	created, err := time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", strings.TrimSpace(string(out)))
+	if err != nil {
+		return doctorCheckResult{
+			Status:  checkWarn,
+			Message: fmt.Sprintf("Failed to parse creation time of image %s: %v", image, err),
+		}
+	}
+
+	age := time.Since(created)
+	if age > imageMaxAge {
+		return doctorCheckResult{
+			Status:  checkWarn,
+			Message: fmt.Sprintf("Image %s is %d days old", image, int(age.Hours()/24)),
+			Hint:    "Run \"hc build\" to pick up OS and oc client updates",
+		}
+	}
+	return doctorCheckResult{
+		Status:  checkPass,
+		Message: fmt.Sprintf("Image %s created %s", image, created.Format(time.RFC3339)),
+	}
+}
+
+func checkFreePorts(ctx context.Context, config *pkgInt.HcConfig) doctorCheckResult {
+	if _, err := pkgIntHelper.GetFreePorts(1); err != nil {
+		return doctorCheckResult{
+			Status:  checkFail,
+			Message: fmt.Sprintf("Failed to allocate a port for the OpenShift console: %v", err),
+			Hint:    "Check for local firewall rules or port exhaustion",
+		}
+	}
+
+	// The custom ports are only known with a config
+	var portMaps []pkgInt.PortMap
+	if config != nil {
+		portMaps = config.CustomPortMaps
+	}
+	var busy []string
+	for _, portMap := range portMaps {
+		listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%s", portMap.HostPort))
+		if err != nil {
+			busy = append(busy, portMap.HostPort)
+			continue
+		}
+		listener.Close()
+	}
+	if len(busy) > 0 {
+		return doctorCheckResult{
+			Status:  checkFail,
+			Message: fmt.Sprintf("Host ports already in use: %s", strings.Join(busy, ", ")),
+			Hint:    "Stop the processes using these ports or change customPortMaps in the hc config",
+		}
+	}
+	return doctorCheckResult{
+		Status:  checkPass,
+		Message: "Ports are available",
+	}
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().StringVarP(
+		&doctorCmdArgs.output,
+		"output",
+		"o",
+		"text",
+		"Output format (text, json)",
+	)
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	pkgIntHelper "hc/internal/helpers"
+
+	"github.com/spf13/viper"
+)
+
+// Sets whether the config was loaded and its error for a test.
+func setConfigState(t *testing.T, loaded bool, err error) {
+	t.Helper()
+	previousLoaded, previousErr := configLoaded, configErr
+	configLoaded, configErr = loaded, err
+	t.Cleanup(func() { configLoaded, configErr = previousLoaded, previousErr })
+}
+
+// An invalid config is still checked, only a config that could not be loaded
+// skips the checks that need it.
+func TestGetDoctorConfig(t *testing.T) {
+	newFakeHost(t, nil)
+
+	setConfigState(t, true, errors.New("Invalid config: backplaneConfigProd: config.prod.json does not exist"))
+	config := getDoctorConfig()
+	if config == nil || config.BackplaneConfigProd != "config.prod.json" {
+		t.Errorf("config %+v of an invalid config, expected it decoded", config)
+	}
+
+	setConfigState(t, false, errors.New("Failed to read config file: no config file found"))
+	if config := getDoctorConfig(); config != nil {
+		t.Errorf("config %+v, expected none when loading failed", config)
+	}
+	if result := checkFreePorts(context.Background(), nil); result.Status != checkPass {
+		t.Errorf("free ports without a config: %+v", result)
+	}
+}
+
+func TestCheckOcmToken(t *testing.T) {
+	host := newFakeHost(t, func(ctx context.Context, command *pkgIntHelper.Command) *pkgIntHelper.Result {
+		if strings.HasSuffix(command.String(), "ocm-staging.sh token") {
+			return &pkgIntHelper.Result{ExitCode: 1, StderrTail: "not logged in"}
+		}
+		return &pkgIntHelper.Result{Stdout: []byte("token\n")}
+	})
+	viper.Set("ocmLongLivedTokenPath", "")
+	viper.Set("ocmCLIAlias.staging", "/opt/ocm-staging.sh")
+	setConfigState(t, true, nil)
+
+	result := checkOcmToken(context.Background(), getDoctorConfig())
+	if result.Status != checkFail || !strings.Contains(result.Message, "staging") || strings.Contains(result.Message, "production") {
+		t.Errorf("result %+v, expected staging to fail only", result)
+	}
+	expected := "sh /opt/ocm-production.sh token\nsh /opt/ocm-staging.sh token"
+	if commands := host.runner.String(); commands != expected {
+		t.Errorf("ran:\n%s\nexpected:\n%s", commands, expected)
+	}
+}
@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	pkgInt "hc/internal"
+	pkgIntHelper "hc/internal/helpers"
+	pkgIntSession "hc/internal/session"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	elevateCmdArgs struct {
+		ttl      time.Duration
+		drop     bool
+		expireAt string
+	}
+)
+
+var elevateCmd = &cobra.Command{
+	Use:   "elevate <reason>",
+	Short: "Elevates the current context to backplane-cluster-admin",
+	Long: `Elevates the current context to backplane-cluster-admin with
+"ocm backplane elevate". The reason is logged to the recorded session and the
+prompt shows the elevation with the time left. The elevation is dropped
+automatically after "elevation.ttl" in the hc config, 20m by default, or
+right away with --drop.`,
+	PreRun: pkgInt.ToggleDebug,
+	Run:    elevate,
+}
+
+func elevate(cmd *cobra.Command, args []string) {
+	if err := checkContainerCommand(); err != nil {
+		log.Fatal(err)
+	}
+
+	switch {
+	case len(elevateCmdArgs.expireAt) > 0:
+		expireElevation(elevateCmdArgs.expireAt)
+		return
+	case elevateCmdArgs.drop:
+		if !dropElevation("hc elevate --drop") {
+			fmt.Println("Not elevated")
+		}
+		return
+	}
+
+	reason := strings.TrimSpace(strings.Join(args, " "))
+	if len(reason) == 0 {
+		log.Fatal("A reason is required, e.g. hc elevate \"OHSS-1234 restart the router\"")
+	}
+	ttl := elevateCmdArgs.ttl
+	if !cmd.Flags().Changed("ttl") {
+		var err error
+		if ttl, err = getHcConfig().GetElevationTTL(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	err := pkgIntHelper.RunStreamed(cmd.Context(), "ocm", "backplane", "elevate", reason)
+	if err != nil {
+		log.Fatalf("OCM backplane elevate failed: %v", err)
+	}
+
+	kubeconfig := loadKubeconfig()
+	user, err := kubeconfig.GetCurrentUser()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Backplane versions that only elevate single commands leave the context as is
+	if len(user.User.As) == 0 {
+		err := kubeconfig.SetUserImpersonation(
+			user.Name,
+			pkgInt.ElevatedUser,
+			map[string][]string{"reason": {reason}},
+		)
+		if err != nil {
+			log.Fatal("Failed to elevate the current context: ", err)
+		}
+	}
+
+	now := time.Now().UTC()
+	elevation := &pkgInt.Elevation{
+		Context:   kubeconfig.CurrentContext,
+		User:      user.Name,
+		Reason:    reason,
+		StartedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := pkgInt.SaveElevation(elevation); err != nil {
+		log.Fatal("Failed to save the elevation: ", err)
+	}
+	appendSessionEntry(&pkgIntSession.CommandEntry{
+		Command: "hc elevate",
+		Event:   pkgIntSession.EventElevate,
+		Reason:  reason,
+	})
+
+	if err := startElevationTimer(elevation); err != nil {
+		log.Warnf("Failed to schedule the end of the elevation, drop it with \"hc elevate --drop\": %v", err)
+	}
+	fmt.Printf(
+		"Elevated context %q to %s until %s\n",
+		elevation.Context,
+		pkgInt.ElevatedUser,
+		elevation.ExpiresAt.Local().Format(time.Kitchen),
+	)
+}
+
+// Starts a process in the background that drops the elevation once it
+// expires.
+func startElevationTimer(elevation *pkgInt.Elevation) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	timerArgs := []string{"elevate", "--expire-at", elevation.StartedAt.Format(time.RFC3339Nano)}
+	if len(cfgFile) > 0 {
+		timerArgs = append(timerArgs, "--config", cfgFile)
+	}
+	timer := exec.Command(executable, timerArgs...)
+	// Detached from the shell so that it is not stopped with the command
+	timer.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := timer.Start(); err != nil {
+		return err
+	}
+	return timer.Process.Release()
+}
+
+// Drops the elevation started at the given time once it expires, unless it
+// was dropped or replaced before.
+func expireElevation(startedAt string) {
+	isCurrent := func() (*pkgInt.Elevation, bool) {
+		elevation, found := pkgInt.GetElevation()
+		if !found || elevation.StartedAt.Format(time.RFC3339Nano) != startedAt {
+			return nil, false
+		}
+		return elevation, true
+	}
+
+	elevation, found := isCurrent()
+	if !found {
+		return
+	}
+	time.Sleep(time.Until(elevation.ExpiresAt))
+	if _, found := isCurrent(); found {
+		dropElevation("hc elevate --expire-at " + startedAt)
+	}
+}
+
+// Drops the current elevation, logging the command that dropped it. Returns
+// false if there is none.
+func dropElevation(command string) bool {
+	elevation, found := pkgInt.GetElevation()
+	if !found {
+		return false
+	}
+
+	kubeconfig := loadKubeconfig()
+	if kubeconfig.GetUser(elevation.User) != nil {
+		if err := kubeconfig.ClearUserImpersonation(elevation.User); err != nil {
+			log.Fatal("Failed to drop the elevation: ", err)
+		}
+	}
+	if err := pkgInt.ClearElevation(); err != nil {
+		log.Fatal("Failed to drop the elevation: ", err)
+	}
+	appendSessionEntry(&pkgIntSession.CommandEntry{
+		Command: command,
+		Event:   pkgIntSession.EventDropElevation,
+		Reason:  elevation.Reason,
+	})
+	fmt.Printf("Dropped the elevation of context %q\n", elevation.Context)
+	return true
+}
+
+func init() {
+	rootCmd.AddCommand(elevateCmd)
+
+	flags := elevateCmd.Flags()
+	flags.DurationVar(
+		&elevateCmdArgs.ttl,
+		"ttl",
+		0,
+		"Time after which the elevation is dropped, overrides the config",
+	)
+	flags.BoolVar(
+		&elevateCmdArgs.drop,
+		"drop",
+		false,
+		"Drop the current elevation",
+	)
+	flags.StringVar(
+		&elevateCmdArgs.expireAt,
+		"expire-at",
+		"",
+		"Drop the elevation started at the given time once it expires",
+	)
+	flags.MarkHidden("expire-at")
+}
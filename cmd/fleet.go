@@ -0,0 +1,459 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	pkgInt "hc/internal"
+	pkgIntHelper "hc/internal/helpers"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// Clusters found by a search beyond this number are not run
+const fleetSearchSize = 500
+
+var (
+	fleetRunCmdArgs struct {
+		clustersFrom   string
+		ocmEnvironment string
+		parallel       int
+		timeout        time.Duration
+		script         string
+		outputDir      string
+		format         string
+	}
+)
+
+var fleetCmd = &cobra.Command{
+	Use:   "fleet",
+	Short: "Runs commands across many clusters",
+}
+
+var fleetRunCmd = &cobra.Command{
+	Use:   "run --clusters-from <file|search> [--script file | -- command...]",
+	Short: "Runs a script or a command on many clusters",
+	Long: `Runs a script or a command on many clusters, each in an ephemeral workspace
+run with hc run. The clusters are read from a file with a cluster name, id or
+external id per line, or found with an OCM search expression. A value without
+spaces nor quotes is taken as a file, which must exist.
+
+The output and artifacts of each cluster are written to the output directory
+along with a json or csv report of the status of each cluster. hc fleet run
+exits with 1 unless the command succeeded on every cluster.`,
+	Example: `  hc fleet run --clusters-from clusters.txt -- oc get co
+  hc fleet run --clusters-from "region.id = 'us-east-1'" --parallel 8 --timeout 5m -f csv -- oc get co`,
+	PreRun: pkgInt.ToggleDebug,
+	Run:    fleetRun,
+	Args: func(cmd *cobra.Command, args []string) error {
+		hasCommand := cmd.ArgsLenAtDash() >= 0 && len(args) > 0
+		switch {
+		case cmd.ArgsLenAtDash() != 0 && len(args) > 0:
+			return fmt.Errorf("the command must follow \"--\"")
+		case len(fleetRunCmdArgs.script) > 0 && hasCommand:
+			return fmt.Errorf("either --script or a command is accepted, not both")
+		case len(fleetRunCmdArgs.script) == 0 && !hasCommand:
+			return fmt.Errorf("a script or a command after \"--\" is required")
+		}
+		return nil
+	},
+}
+
+// Checks whether the clusters of a fleet run are given as a file. OCM search
+// expressions compare values, so they have spaces or quotes.
+func isFleetClusterFile(clustersFrom string) bool {
+	if _, err := os.Stat(clustersFrom); err == nil {
+		return true
+	}
+	return !strings.ContainsAny(clustersFrom, " '\"=")
+}
+
+// Gets the clusters of a fleet run from a file, or from an OCM search.
+// Clusters that cannot be found are returned as errors.
+func getFleetClusters(ocmCli *pkgInt.OcmCli, clustersFrom string) ([]pkgInt.FleetResult, error) {
+	results := []pkgInt.FleetResult{}
+	seen := map[string]bool{}
+	add := func(cluster *pkgInt.OcmCluster) {
+		if !seen[cluster.ID] {
+			seen[cluster.ID] = true
+			results = append(results, pkgInt.FleetResult{Cluster: cluster.Name, ID: cluster.ID})
+		}
+	}
+
+	if !isFleetClusterFile(clustersFrom) {
+		log.Infof("Searching clusters: %s", clustersFrom)
+		clusters, err := ocmCli.SearchClusters(clustersFrom, fleetSearchSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(clusters) == fleetSearchSize {
+			log.Warnf("Running on the first %d clusters found only", fleetSearchSize)
+		}
+		for idx := range clusters {
+			add(&clusters[idx])
+		}
+		return results, nil
+	}
+
+	keys, err := pkgInt.ReadClusterList(clustersFrom)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the cluster list: %w", err)
+	}
+	for _, key := range keys {
+		cluster, err := ocmCli.GetCluster(key)
+		if err != nil {
+			log.Errorf("Failed to look up cluster %s: %v", key, err)
+			results = append(results, pkgInt.FleetResult{
+				Cluster:  key,
+				Status:   pkgInt.FleetStatusError,
+				ExitCode: -1,
+				Error:    err.Error(),
+			})
+			continue
+		}
+		add(cluster)
+	}
+	return results, nil
+}
+
+// Runs hc run in a child process. Runs are stopped with SIGTERM, forwarded by
+// hc run to the workspace, and killed if they do not exit in time.
+type fleetJob struct {
+	result  *pkgInt.FleetResult
+	command *pkgIntHelper.Command
+	ctx     context.Context
+	cancel  context.CancelFunc
+	mutex   sync.Mutex
+	stopped bool
+}
+
+func newFleetJob(ctx context.Context, result *pkgInt.FleetResult, command *pkgIntHelper.Command) *fleetJob {
+	job := &fleetJob{result: result, command: command}
+	job.ctx, job.cancel = context.WithCancel(ctx)
+	return job
+}
+
+func (j *fleetJob) stop() {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.stopped = true
+	j.cancel()
+}
+
+// Runs the job and fills its result.
+func (j *fleetJob) run(timeout time.Duration) {
+	defer j.cancel()
+	result := j.result
+	result.ExitCode = -1
+	logFile, err := os.Create(result.LogFile)
+	if err != nil {
+		result.Status = pkgInt.FleetStatusError
+		result.Error = err.Error()
+		return
+	}
+	defer logFile.Close()
+	j.command.Stdout = logFile
+	j.command.Stderr = logFile
+	j.command.Timeout = timeout
+	j.command.Grace = runGracePeriod + 5*time.Second
+
+	j.mutex.Lock()
+	stopped := j.stopped
+	j.mutex.Unlock()
+	if stopped {
+		result.Status = pkgInt.FleetStatusSkipped
+		return
+	}
+	startedAt := time.Now().UTC()
+	result.StartedAt = &startedAt
+	runResult, err := pkgIntHelper.Run(j.ctx, j.command)
+
+	switch {
+	// Stopped before it started
+	case runResult == nil && j.ctx.Err() != nil:
+		result.StartedAt = nil
+		result.Status = pkgInt.FleetStatusSkipped
+	case runResult == nil:
+		result.Status = pkgInt.FleetStatusError
+		result.Error = err.Error()
+	case runResult.TimedOut:
+		result.Status = pkgInt.FleetStatusTimeout
+		result.Error = fmt.Sprintf("timed out after %s", timeout)
+	case err == nil:
+		result.Status = pkgInt.FleetStatusSucceeded
+		result.ExitCode = 0
+	case runResult.ExitCode >= 0:
+		result.Status = pkgInt.FleetStatusFailed
+		result.ExitCode = runResult.ExitCode
+	default:
+		result.Status = pkgInt.FleetStatusError
+		result.Error = err.Error()
+	}
+	if runResult != nil {
+		result.DurationSeconds = pkgInt.GetDurationSeconds(runResult.Duration)
+	}
+}
+
+// Gets the directory of a cluster's output, by name unless several clusters
+// have the same name.
+func getFleetClusterDir(outputDir string, result *pkgInt.FleetResult, names map[string]int) string {
+	if names[result.Cluster] > 1 {
+		return filepath.Join(outputDir, result.ID)
+	}
+	return filepath.Join(outputDir, result.Cluster)
+}
+
+type fleetOptions struct {
+	clustersFrom   string
+	ocmEnvironment string
+	parallel       int
+	timeout        time.Duration
+	// Absolute path of the script to run, empty to run the command
+	script    string
+	command   []string
+	outputDir string
+	format    string
+	// hc binary that runs the clusters with hc run
+	executable string
+	configFile string
+}
+
+// Runs a fleet run until all clusters ran or it is interrupted, in which case
+// the running clusters are stopped and the others are skipped. Returns the
+// report along with the file it was written to.
+func runFleet(ctx context.Context, opts *fleetOptions, interrupted <-chan struct{}) (*pkgInt.FleetReport, string, error) {
+	config := getHcConfig()
+	ocmCli := newOcmCli(opts.ocmEnvironment, config.OcmCliAlias)
+	results, err := getFleetClusters(ocmCli, opts.clustersFrom)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get the clusters: %w", err)
+	}
+	if len(results) == 0 {
+		return nil, "", errors.New("no clusters found")
+	}
+	if err := os.MkdirAll(opts.outputDir, 0700); err != nil {
+		return nil, "", fmt.Errorf("failed to create the output directory %s: %w", opts.outputDir, err)
+	}
+
+	names := map[string]int{}
+	for _, result := range results {
+		names[result.Cluster]++
+	}
+	jobs := []*fleetJob{}
+	for idx := range results {
+		result := &results[idx]
+		if len(result.Status) > 0 {
+			continue
+		}
+		clusterDir := getFleetClusterDir(opts.outputDir, result, names)
+		if err := os.MkdirAll(clusterDir, 0700); err != nil {
+			return nil, "", fmt.Errorf("failed to create %s: %w", clusterDir, err)
+		}
+		result.LogFile = filepath.Join(clusterDir, "output.log")
+		result.ArtifactsDir = filepath.Join(clusterDir, "artifacts")
+
+		runArgs := []string{
+			"run",
+			"--ocmCluster", result.ID,
+			"--ocmEnvironment", opts.ocmEnvironment,
+			"--artifacts", result.ArtifactsDir,
+		}
+		if len(opts.configFile) > 0 {
+			runArgs = append(runArgs, "--config", opts.configFile)
+		}
+		if pkgInt.Debug {
+			runArgs = append(runArgs, "-d")
+		}
+		if len(opts.script) > 0 {
+			runArgs = append(runArgs, "--script", opts.script)
+		} else {
+			runArgs = append(runArgs, "--")
+			runArgs = append(runArgs, opts.command...)
+		}
+		jobs = append(jobs, newFleetJob(ctx, result, pkgIntHelper.NewCommand(opts.executable, runArgs...)))
+	}
+
+	report := &pkgInt.FleetReport{
+		Command:   opts.command,
+		StartedAt: time.Now().UTC(),
+	}
+	if len(opts.script) > 0 {
+		report.Command = []string{opts.script}
+	}
+	log.Infof("Running on %d clusters, %d at a time", len(jobs), opts.parallel)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-interrupted:
+			log.Warn("Interrupted, stopping the running clusters")
+			for _, job := range jobs {
+				job.stop()
+			}
+		case <-done:
+		}
+	}()
+
+	slots := make(chan struct{}, opts.parallel)
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		select {
+		case <-interrupted:
+		case slots <- struct{}{}:
+		}
+		select {
+		case <-interrupted:
+			job.result.Status = pkgInt.FleetStatusSkipped
+			job.result.ExitCode = -1
+			continue
+		default:
+		}
+
+		wg.Add(1)
+		go func(job *fleetJob) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			job.run(opts.timeout)
+			log.Infof("%s: %s", job.result.Cluster, job.result.Status)
+		}(job)
+	}
+	wg.Wait()
+	report.DurationSeconds = pkgInt.GetDurationSeconds(time.Since(report.StartedAt))
+	report.Results = results
+
+	reportFile := filepath.Join(opts.outputDir, "report."+opts.format)
+	file, err := os.Create(reportFile)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to write the report: %w", err)
+	}
+	defer file.Close()
+	if err := pkgInt.WriteFleetReport(file, report, opts.format); err != nil {
+		return nil, "", fmt.Errorf("failed to write the report: %w", err)
+	}
+	return report, reportFile, nil
+}
+
+func fleetRun(cmd *cobra.Command, args []string) {
+	opts := &fleetOptions{
+		clustersFrom:   fleetRunCmdArgs.clustersFrom,
+		ocmEnvironment: fleetRunCmdArgs.ocmEnvironment,
+		parallel:       fleetRunCmdArgs.parallel,
+		timeout:        fleetRunCmdArgs.timeout,
+		command:        args,
+		outputDir:      fleetRunCmdArgs.outputDir,
+		format:         fleetRunCmdArgs.format,
+		configFile:     cfgFile,
+	}
+	if opts.parallel < 1 {
+		log.Fatal("--parallel must be at least 1")
+	}
+	if opts.format != "json" && opts.format != "csv" {
+		log.Fatalf("Unknown report format: %s", opts.format)
+	}
+	var err error
+	if len(fleetRunCmdArgs.script) > 0 {
+		if opts.script, err = filepath.Abs(fleetRunCmdArgs.script); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if opts.executable, err = os.Executable(); err != nil {
+		log.Fatal(err)
+	}
+	if len(opts.outputDir) == 0 {
+		opts.outputDir = filepath.Join("hc-fleet", time.Now().Format("20060102-150405"))
+	}
+	if opts.outputDir, err = filepath.Abs(opts.outputDir); err != nil {
+		log.Fatal(err)
+	}
+
+	// Running clusters are stopped on SIGINT and SIGTERM, pending ones are
+	// skipped
+	signals, stopIntercepting := pkgIntHelper.InterceptSignals()
+	defer stopIntercepting()
+	interrupted := make(chan struct{})
+	go func() {
+		<-signals
+		close(interrupted)
+	}()
+
+	report, reportFile, err := runFleet(cmd.Context(), opts, interrupted)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLUSTER\tSTATUS\tEXIT\tDURATION")
+	for _, result := range report.Results {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%.1fs\n", result.Cluster, result.Status, result.ExitCode, result.DurationSeconds)
+	}
+	w.Flush()
+	fmt.Printf("Report: %s\n", reportFile)
+
+	if report.CountStatuses()[pkgInt.FleetStatusSucceeded] != len(report.Results) {
+		pkgIntHelper.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(fleetCmd)
+	fleetCmd.AddCommand(fleetRunCmd)
+
+	flags := fleetRunCmd.Flags()
+	flags.StringVar(
+		&fleetRunCmdArgs.clustersFrom,
+		"clusters-from",
+		"",
+		"File with a cluster per line, or an OCM search expression",
+	)
+	fleetRunCmd.MarkFlagRequired("clusters-from")
+	flags.StringVarP(
+		&fleetRunCmdArgs.ocmEnvironment,
+		"ocmEnvironment",
+		"e",
+		"production",
+		"OCM environemnt (production, staging)",
+	)
+	fleetRunCmd.RegisterFlagCompletionFunc("ocmEnvironment", completeOcmEnvironments)
+	flags.IntVarP(
+		&fleetRunCmdArgs.parallel,
+		"parallel",
+		"p",
+		4,
+		"Number of clusters run at a time",
+	)
+	flags.DurationVar(
+		&fleetRunCmdArgs.timeout,
+		"timeout",
+		10*time.Minute,
+		"Time after which the run on a cluster is stopped",
+	)
+	flags.StringVar(
+		&fleetRunCmdArgs.script,
+		"script",
+		"",
+		"Script to run, with bash",
+	)
+	flags.StringVarP(
+		&fleetRunCmdArgs.outputDir,
+		"output",
+		"o",
+		"",
+		"Output directory, by default ./hc-fleet/<time>",
+	)
+	flags.StringVarP(
+		&fleetRunCmdArgs.format,
+		"format",
+		"f",
+		"json",
+		"Report format (json, csv)",
+	)
+}
@@ -0,0 +1,288 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	pkgInt "hc/internal"
+	pkgIntHelper "hc/internal/helpers"
+	pkgIntOcm "hc/internal/ocm"
+)
+
+// hc binary of the fleet runs, which are recorded instead of run
+const fakeFleetExecutable = "/usr/local/bin/hc"
+
+// Serves the clusters from a fake OCM, which the ocm CLI is logged in to.
+func (h *fakeHost) useFakeOcm(t *testing.T, clusters ...pkgIntOcm.Cluster) *pkgIntOcm.FakeServer {
+	t.Helper()
+	fake := pkgIntOcm.NewFakeServer(clusters...)
+	t.Cleanup(fake.Close)
+	content, err := json.Marshal(fake.GetConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(h.home, "ocm.json")
+	if err := os.WriteFile(file, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OCM_CONFIG", file)
+
+	previousOcmCli := newOcmCli
+	newOcmCli = func(environment string, alias pkgInt.OcmCliAlias) *pkgInt.OcmCli {
+		return &pkgInt.OcmCli{Environment: environment, URL: fake.URL}
+	}
+	t.Cleanup(func() { newOcmCli = previousOcmCli })
+	return fake
+}
+
+// Gets the cluster id that a fleet run runs hc run on.
+func getFleetRunCluster(command *pkgIntHelper.Command) string {
+	for idx, arg := range command.Args {
+		if arg == "--ocmCluster" && idx+1 < len(command.Args) {
+			return command.Args[idx+1]
+		}
+	}
+	return ""
+}
+
+func newFleetOptions(host *fakeHost) *fleetOptions {
+	return &fleetOptions{
+		clustersFrom:   "region.id = 'us-east-1'",
+		ocmEnvironment: "production",
+		parallel:       4,
+		timeout:        time.Minute,
+		command:        []string{"oc", "get", "co"},
+		outputDir:      filepath.Join(host.home, "hc-fleet"),
+		format:         "json",
+		executable:     fakeFleetExecutable,
+	}
+}
+
+func getFleetStatuses(report *pkgInt.FleetReport) map[string]string {
+	statuses := map[string]string{}
+	for _, result := range report.Results {
+		statuses[result.ID] = result.Status
+	}
+	return statuses
+}
+
+func TestFleetRunParallel(t *testing.T) {
+	var mutex sync.Mutex
+	running, maxRunning := 0, 0
+	host := newFakeHost(t, func(ctx context.Context, command *pkgIntHelper.Command) *pkgIntHelper.Result {
+		mutex.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mutex.Unlock()
+		defer func() {
+			mutex.Lock()
+			running--
+			mutex.Unlock()
+		}()
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(20 * time.Millisecond):
+		}
+		if getFleetRunCluster(command) == "c3" {
+			return &pkgIntHelper.Result{ExitCode: 2, Stdout: []byte("degraded\n")}
+		}
+		return &pkgIntHelper.Result{Stdout: []byte("available\n")}
+	})
+	host.useFakeOcm(t,
+		pkgIntOcm.NewFakeCluster("c1", "cluster-1", "us-east-1"),
+		pkgIntOcm.NewFakeCluster("c2", "cluster-2", "us-east-1"),
+		pkgIntOcm.NewFakeCluster("c3", "cluster-3", "us-east-1"),
+		pkgIntOcm.NewFakeCluster("c4", "cluster-4", "us-east-1"),
+		pkgIntOcm.NewFakeCluster("c5", "cluster-5", "us-east-1"),
+		pkgIntOcm.NewFakeCluster("c6", "cluster-6", "eu-west-1"),
+	)
+	opts := newFleetOptions(host)
+	opts.parallel = 2
+
+	report, reportFile, err := runFleet(context.Background(), opts, make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maxRunning != 2 {
+		t.Errorf("%d clusters ran at a time, expected 2", maxRunning)
+	}
+	expected := map[string]string{
+		"c1": pkgInt.FleetStatusSucceeded,
+		"c2": pkgInt.FleetStatusSucceeded,
+		"c3": pkgInt.FleetStatusFailed,
+		"c4": pkgInt.FleetStatusSucceeded,
+		"c5": pkgInt.FleetStatusSucceeded,
+	}
+	if statuses := getFleetStatuses(report); !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("statuses %v, expected %v", statuses, expected)
+	}
+
+	failed := report.Results[2]
+	if failed.ExitCode != 2 {
+		t.Errorf("exit code %d, expected 2", failed.ExitCode)
+	}
+	if output, err := os.ReadFile(failed.LogFile); err != nil || string(output) != "degraded\n" {
+		t.Errorf("output %q (%v), expected the output of hc run", output, err)
+	}
+	args := host.runner.Commands()[0].Args
+	expectedArgs := []string{
+		"run",
+		"--ocmCluster", args[2],
+		"--ocmEnvironment", "production",
+		"--artifacts", filepath.Join(opts.outputDir, "cluster-"+args[2][1:], "artifacts"),
+		"--", "oc", "get", "co",
+	}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("hc run args %v, expected %v", args, expectedArgs)
+	}
+
+	content, err := os.ReadFile(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written pkgInt.FleetReport
+	if err := json.Unmarshal(content, &written); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(getFleetStatuses(&written), expected) || !reflect.DeepEqual(written.Command, opts.command) {
+		t.Errorf("unexpected report %s:\n%s", reportFile, content)
+	}
+}
+
+func TestFleetRunTimeout(t *testing.T) {
+	host := newFakeHost(t, func(ctx context.Context, command *pkgIntHelper.Command) *pkgIntHelper.Result {
+		if getFleetRunCluster(command) == "c2" {
+			<-ctx.Done()
+			return &pkgIntHelper.Result{ExitCode: 143}
+		}
+		return &pkgIntHelper.Result{}
+	})
+	host.useFakeOcm(t,
+		pkgIntOcm.NewFakeCluster("c1", "cluster-1", "us-east-1"),
+		pkgIntOcm.NewFakeCluster("c2", "cluster-2", "us-east-1"),
+	)
+	opts := newFleetOptions(host)
+	opts.timeout = 50 * time.Millisecond
+
+	report, _, err := runFleet(context.Background(), opts, make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"c1": pkgInt.FleetStatusSucceeded, "c2": pkgInt.FleetStatusTimeout}
+	if statuses := getFleetStatuses(report); !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("statuses %v, expected %v", statuses, expected)
+	}
+	if command := host.runner.Commands()[1]; command.Timeout != opts.timeout {
+		t.Errorf("hc run timeout %s, expected %s", command.Timeout, opts.timeout)
+	}
+}
+
+// On an interrupt the running cluster is stopped and the pending ones are
+// skipped without being run.
+func TestFleetRunInterrupted(t *testing.T) {
+	interrupted := make(chan struct{})
+	var interrupt sync.Once
+	host := newFakeHost(t, func(ctx context.Context, command *pkgIntHelper.Command) *pkgIntHelper.Result {
+		interrupt.Do(func() { close(interrupted) })
+		<-ctx.Done()
+		// hc run exits with the signal forwarded to the workspace
+		return &pkgIntHelper.Result{ExitCode: 143}
+	})
+	host.useFakeOcm(t,
+		pkgIntOcm.NewFakeCluster("c1", "cluster-1", "us-east-1"),
+		pkgIntOcm.NewFakeCluster("c2", "cluster-2", "us-east-1"),
+		pkgIntOcm.NewFakeCluster("c3", "cluster-3", "us-east-1"),
+	)
+	opts := newFleetOptions(host)
+	opts.parallel = 1
+	opts.format = "csv"
+
+	report, reportFile, err := runFleet(context.Background(), opts, interrupted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commands := host.runner.Commands(); len(commands) != 1 {
+		t.Errorf("%d clusters ran, expected 1:\n%s", len(commands), host.runner)
+	}
+	expected := map[string]string{
+		"c1": pkgInt.FleetStatusFailed,
+		"c2": pkgInt.FleetStatusSkipped,
+		"c3": pkgInt.FleetStatusSkipped,
+	}
+	if statuses := getFleetStatuses(report); !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("statuses %v, expected %v", statuses, expected)
+	}
+
+	file, err := os.Open(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 || rows[1][2] != "failed" || rows[1][3] != "143" || rows[3][2] != "skipped" || rows[3][3] != "-1" {
+		t.Errorf("unexpected report %s: %v", reportFile, rows)
+	}
+}
+
+func TestGetFleetClusters(t *testing.T) {
+	host := newFakeHost(t, nil)
+	host.useFakeOcm(t,
+		pkgIntOcm.NewFakeCluster("c1", "cluster-1", "us-east-1"),
+		pkgIntOcm.NewFakeCluster("c2", "cluster-2", "eu-west-1"),
+	)
+	ocmCli := newOcmCli("production", pkgInt.OcmCliAlias{})
+	clusterList := filepath.Join(host.home, "clusters.txt")
+	if err := os.WriteFile(clusterList, []byte("# clusters\ncluster-1\n\next-c2\nc1\nunknown\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		clustersFrom string
+		expected     []pkgInt.FleetResult
+		fails        bool
+	}{
+		{
+			clustersFrom: clusterList,
+			expected: []pkgInt.FleetResult{
+				{Cluster: "cluster-1", ID: "c1"},
+				{Cluster: "cluster-2", ID: "c2"},
+				{Cluster: "unknown", Status: pkgInt.FleetStatusError, ExitCode: -1, Error: "cluster unknown not found"},
+			},
+		},
+		{
+			clustersFrom: "region.id = 'eu-west-1'",
+			expected:     []pkgInt.FleetResult{{Cluster: "cluster-2", ID: "c2"}},
+		},
+		{clustersFrom: filepath.Join(host.home, "missing.txt"), fails: true},
+		{clustersFrom: "clusters-prod", fails: true},
+	}
+	for _, test := range tests {
+		results, err := getFleetClusters(ocmCli, test.clustersFrom)
+		if test.fails {
+			if err == nil {
+				t.Errorf("%s: found %v, expected a missing file error", test.clustersFrom, results)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.clustersFrom, err)
+			continue
+		}
+		if !reflect.DeepEqual(results, test.expected) {
+			t.Errorf("%s: found %+v, expected %+v", test.clustersFrom, results, test.expected)
+		}
+	}
+}
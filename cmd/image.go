@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	pkgInt "hc/internal"
+	pkgIntHelper "hc/internal/helpers"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Checks whether an image is available in the local container storage.
+func imageExists(ctx context.Context, ce pkgInt.ContainerEngine, image string) bool {
+	_, err := pkgIntHelper.RunOutput(ctx, ce.GetExecName(), ce.GetImageExistsCmd(image)...)
+	return err == nil
+}
+
+// Checks whether the container engine runs rootless.
+func isRootless(ctx context.Context, ce pkgInt.ContainerEngine) bool {
+	out, err := pkgIntHelper.RunOutput(ctx, ce.GetExecName(), ce.GetRootlessInfoCmd()...)
+	if err != nil {
+		log.Debugf("Failed to check whether %s runs rootless: %v", ce.GetExecName(), err)
+		return false
+	}
+	return strings.TrimSpace(string(out)) == "true"
+}
+
+// Checks that an image is the hc image of the config, as told by its labels.
+func verifyHcImage(ctx context.Context, ce pkgInt.ContainerEngine, image string, config *pkgInt.HcConfig) error {
+	out, err := pkgIntHelper.RunOutput(ctx, ce.GetExecName(), ce.GetImageLabelsCmd(image)...)
+	if err != nil {
+		return err
+	}
+	labels := map[string]string{}
+	if err := json.Unmarshal(out, &labels); err != nil {
+		return fmt.Errorf("invalid labels of image %s: %w", image, err)
+	}
+	for name, expected := range config.GetImageLabels() {
+		if labels[name] != expected {
+			return fmt.Errorf("image %s has the label %s=%q, expected %q", image, name, labels[name], expected)
+		}
+	}
+	return nil
+}
+
+// Resolves the hc image to run. A prebuilt image matching the configured tool
+// versions and the hc build is pulled from the configured registry if there
+// is one, otherwise a locally built image is used and built if it does not
+// exist yet. Prebuilt images are checked against the config, since a tag may
+// have been pushed again or an image tagged locally.
+func resolveHcImage(ctx context.Context, ce pkgInt.ContainerEngine, config *pkgInt.HcConfig) (string, error) {
+	ce.SetTLSVerify(config.Image.TLSVerify)
+
+	remoteImage := config.GetRemoteImage()
+	if len(remoteImage) > 0 {
+		if imageExists(ctx, ce, remoteImage) {
+			err := verifyHcImage(ctx, ce, remoteImage, config)
+			if err == nil {
+				return remoteImage, nil
+			}
+			log.Warnf("Pulling image %s again: %v", remoteImage, err)
+		}
+
+		log.Infof("Pulling image %s", remoteImage)
+		err := pkgIntHelper.RunStreamed(ctx, ce.GetExecName(), ce.GetPullCmd(remoteImage)...)
+		if err == nil {
+			err = verifyHcImage(ctx, ce, remoteImage, config)
+		}
+		if err == nil {
+			return remoteImage, nil
+		}
+		log.Warnf("Failed to pull image %s, falling back to a local build: %v", remoteImage, err)
+	}
+
+	localImage := config.GetLocalImage()
+	if imageExists(ctx, ce, localImage) {
+		return localImage, nil
+	}
+
+	log.Infof("Image %s not found, building it", localImage)
+	if err := buildImage(ctx, ce, config); err != nil {
+		return "", fmt.Errorf("failed to build image %s: %w", localImage, err)
+	}
+	return localImage, nil
+}
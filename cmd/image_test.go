@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	pkgIntHelper "hc/internal/helpers"
+
+	"github.com/spf13/viper"
+)
+
+// Resolves the hc image with a registry, against podman that has the images
+// with the given labels. Pulled images get the labels of the registry.
+func resolveHcImageWith(t *testing.T, local map[string]map[string]string, registry map[string]string) (string, *fakeHost) {
+	t.Helper()
+	host := newFakeHost(t, func(ctx context.Context, command *pkgIntHelper.Command) *pkgIntHelper.Result {
+		image := command.Args[len(command.Args)-1]
+		args := strings.Join(command.Args, " ")
+		switch {
+		case strings.HasPrefix(args, "image exists"):
+			if _, found := local[image]; !found {
+				return &pkgIntHelper.Result{ExitCode: 1}
+			}
+		case strings.HasPrefix(args, "image inspect"):
+			labels, _ := json.Marshal(local[image])
+			return &pkgIntHelper.Result{Stdout: labels}
+		case strings.HasPrefix(args, "pull"):
+			local[image] = registry
+		}
+		return &pkgIntHelper.Result{}
+	})
+	viper.Set("image.registry", "quay.io/my-team")
+	config := getHcConfig()
+
+	image, err := resolveHcImage(context.Background(), host.engine, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return image, host
+}
+
+func TestResolveHcImage(t *testing.T) {
+	newFakeHost(t, nil)
+	viper.Set("image.registry", "quay.io/my-team")
+	config := getHcConfig()
+	remoteImage := config.GetRemoteImage()
+	localImage := config.GetLocalImage()
+	labels := config.GetImageLabels()
+	staleLabels := config.GetImageLabels()
+	staleLabels["hc.version"] = "v0.1.0"
+
+	tests := []struct {
+		name     string
+		local    map[string]map[string]string
+		registry map[string]string
+		expected string
+		pulled   bool
+	}{
+		{
+			name:     "verified image",
+			local:    map[string]map[string]string{remoteImage: labels},
+			expected: remoteImage,
+		},
+		{
+			name:     "stale image",
+			local:    map[string]map[string]string{remoteImage: staleLabels},
+			registry: labels,
+			expected: remoteImage,
+			pulled:   true,
+		},
+		{
+			name:     "stale registry image",
+			local:    map[string]map[string]string{remoteImage: staleLabels, localImage: labels},
+			registry: staleLabels,
+			expected: localImage,
+			pulled:   true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			image, host := resolveHcImageWith(t, test.local, test.registry)
+			if image != test.expected {
+				t.Errorf("resolved %s, expected %s", image, test.expected)
+			}
+			if pulled := strings.Contains(host.runner.String(), "podman pull"); pulled != test.pulled {
+				t.Errorf("pulled %v, expected %v:\n%s", pulled, test.pulled, host.runner)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	pkgInt "hc/internal"
+	pkgIntHelper "hc/internal/helpers"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const kubeconfigStateCache = "kubeconfig-state"
+
+// Contexts and namespaces switched from, for switching back with "-".
+type kubeconfigState struct {
+	PreviousContext string `json:"previousContext"`
+	// Keyed by context
+	PreviousNamespaces map[string]string `json:"previousNamespaces"`
+}
+
+func loadKubeconfigState() *kubeconfigState {
+	var state kubeconfigState
+	pkgInt.ReadCache(kubeconfigStateCache, 0, &state)
+	if state.PreviousNamespaces == nil {
+		state.PreviousNamespaces = map[string]string{}
+	}
+	return &state
+}
+
+func saveKubeconfigState(state *kubeconfigState) {
+	if err := pkgInt.WriteCache(kubeconfigStateCache, state); err != nil {
+		log.Warnf("Failed to save the previous context and namespace: %v", err)
+	}
+}
+
+// Loads the kubeconfig of the current user. Exits if it cannot be loaded.
+func loadKubeconfig() *pkgIntHelper.Kubeconfig {
+	kubeconfig, err := pkgIntHelper.LoadKubeconfig()
+	if err != nil {
+		log.Fatal("Failed to load the kubeconfig: ", err)
+	}
+	if len(kubeconfig.Files) == 0 {
+		log.Fatalf("No kubeconfig found in %v", pkgIntHelper.GetKubeconfigFiles())
+	}
+	return kubeconfig
+}
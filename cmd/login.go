@@ -1,16 +1,12 @@
 package cmd
 
 import (
-	"fmt"
+	"context"
+
 	pkgInt "hc/internal"
 	pkgIntHelper "hc/internal/helpers"
-	"os"
-	"strconv"
-	"strings"
-
-	log "github.com/sirupsen/logrus"
 
-	"github.com/google/uuid"
+	logger "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
@@ -20,6 +16,8 @@ var (
 		ocmEnvironment         string
 		isOcmLoginOnly         bool
 		extraContainerPortMaps string
+		hardened               bool
+		search                 string
 	}
 )
 
@@ -31,131 +29,39 @@ var loginCmd = &cobra.Command{
 }
 
 func login(cmd *cobra.Command, args []string) {
-	ocmEnvironment := "production"
-
-	if len(loginCmdArgs.ocmEnvironment) > 0 {
-		ocmEnvironment = loginCmdArgs.ocmEnvironment
-	}
-
-	ceFactory := pkgInt.NewCeFactory(map[string]interface{}{
-		"ceName": "podman",
+	exitCode, err := runLogin(cmd.Context(), &workspaceOptions{
+		cluster:                loginCmdArgs.cluster,
+		search:                 loginCmdArgs.search,
+		ocmEnvironment:         loginCmdArgs.ocmEnvironment,
+		isOcmLoginOnly:         loginCmdArgs.isOcmLoginOnly,
+		hardened:               loginCmdArgs.hardened,
+		extraContainerPortMaps: loginCmdArgs.extraContainerPortMaps,
+		interactive:            true,
 	})
-
-	ocmCluster := loginCmdArgs.cluster
-	isOcmLoginOnly := loginCmdArgs.isOcmLoginOnly
-
-	ce, err := ceFactory.Create()
-	if err != nil {
-		log.Fatal("Failed to create container engine: ", err)
-	}
-
-	config := pkgInt.GetHcConfig()
-	ocmLongLivedTokenPath := config.OcmLongLivedTokenPath
-	var ocmToken string
-
-	if len(ocmLongLivedTokenPath) > 0 {
-		content, err := os.ReadFile(ocmLongLivedTokenPath)
-		if err != nil {
-			log.Fatalf("Failed to open long lived token file: %v", content)
-		}
-		ocmToken = string(content)
-	} else {
-		ocmCliAlias := config.OcmCliAlias
-		ocmToken, err = pkgIntHelper.OcmGetOCMToken(
-			loginCmdArgs.ocmEnvironment,
-			ocmCliAlias.OcmProduction,
-			ocmCliAlias.OcmStaging,
-		)
-		if err != nil {
-			log.Fatalf("%s:\n%v", pkgInt.ErrOCMTokenFetchMsg, err)
-		}
-	}
-
-	// Path where backplane config is mounted in the container
-	containerBackplaneConfigPath := "/backplane-config.json"
-	// Path where hc config is mounted in the container
-	hcConfigPath := "/.hc.yaml"
-
-	// Allocate free port and map host port for OpenShift console
-	ports, err := pkgIntHelper.GetFreePorts(1)
 	if err != nil {
-		log.Fatal("Failed to generate port for Openshift console: ", err)
-	}
-	openshiftConsolePort := strconv.Itoa(ports[0])
-
-	// Gather values for the container's environment variables
-	ce.AppendEnvVar("HOST_USER", config.HostUser)
-	ce.AppendEnvVar("OC_USER", config.OcUser)
-	ce.AppendEnvVar("OCM_CLUSTER", ocmCluster)
-	ce.AppendEnvVar("IS_OCM_LOGIN_ONLY", strconv.FormatBool(isOcmLoginOnly))
-	ce.AppendEnvVar("OCM_TOKEN", ocmToken)
-	ce.AppendEnvVar("IS_IN_CONTAINER", "true")
-	ce.AppendEnvVar("OCM_ENVIRONMENT", ocmEnvironment)
-	ce.AppendEnvVar("BACKPLANE_CONFIG", containerBackplaneConfigPath)
-	ce.AppendEnvVar("OPENSHIFT_CONSOLE_PORT", openshiftConsolePort)
-
-	// Gather values for the container's host-mounted volumes
-	if ocmEnvironment == "production" {
-		ce.AppendVolMap(
-			fmt.Sprintf("%s/.config/backplane/%s", config.UserHome, config.BackplaneConfigProd),
-			containerBackplaneConfigPath,
-			"ro",
-		)
-	} else {
-		ce.AppendVolMap(
-			fmt.Sprintf("%s/.config/backplane/%s", config.UserHome, config.BackplaneConfigStage),
-			containerBackplaneConfigPath,
-			"ro",
-		)
+		logger.Fatal("Failed to run the workspace: ", err)
 	}
-	ce.AppendVolMap(fmt.Sprintf("%s/.hc.yaml", config.UserHome), hcConfigPath, "ro")
-
-	for _, dirMap := range config.CustomDirMaps {
-		ce.AppendVolMap(dirMap.HostDir, dirMap.ContainerDir, dirMap.FileAttrs)
-	}
-
-	// Gather values for the containers host-mapped TCP ports
-	// Openshift console port
-	ce.AppendPortMap(openshiftConsolePort, openshiftConsolePort, "127.0.0.1")
-
-	// Append extra ports if there's any
-	extraContainerPorts := strings.Fields(loginCmdArgs.extraContainerPortMaps)
-	extraHostPorts, err := pkgIntHelper.GetFreePorts(len(extraContainerPorts))
-	if err != nil {
-		log.Fatal("Failed to generate and map extra container ports: ", err)
-	}
-	for idx, containerPort := range extraContainerPorts {
-		port := strconv.Itoa(extraHostPorts[idx])
-		ce.AppendPortMap(port, containerPort, "127.0.0.1")
-	}
-
-	suffix := uuid.New()
-	containerName := fmt.Sprintf("hc-%s-%s", ocmCluster, suffix.String()[:6])
+	pkgIntHelper.Exit(exitCode)
+}
 
-	runCmd := ce.GetRunCmd(
-		containerName,
-		"./hc",
-		"hc:latest",
-		"clusterLogin",
-		ocmCluster,
-		"--config",
-		hcConfigPath,
-	)
+// Runs a workspace container in the terminal until it exits. Returns its exit
+// code.
+func runLogin(ctx context.Context, opts *workspaceOptions) (int, error) {
+	w := prepareWorkspace(ctx, opts)
+	workspaceCmd := w.getRunCommand()
+	w.register()
+	// The container keeps running if podman is killed
+	w.addCleanup("stop the workspace container", func() {
+		runCleanupCommand(w.ce.GetExecName(), w.ce.GetStopCmd(w.name, containerStopTimeout)...)
+	})
 
-	if pkgInt.Debug {
-		runCmd = append(runCmd, "-d")
+	_, err := pkgIntHelper.Run(ctx, workspaceCmd)
+	w.close()
+	exitCode := pkgIntHelper.GetExitCode(err)
+	if exitCode < 0 {
+		return exitCode, err
 	}
-
-	log.Debugf("Container run command: %v", runCmd)
-
-	pkgIntHelper.RunCommandWithOsFiles(
-		ce.GetExecName(),
-		os.Stdout,
-		os.Stderr,
-		os.Stdin,
-		runCmd...,
-	)
-
+	return exitCode, nil
 }
 
 func init() {
@@ -167,7 +73,15 @@ func init() {
 		"ocmCluster",
 		"c",
 		"",
-		"Cluster name or id.",
+		"Cluster name, id or external id, or a part of its name.",
+	)
+	loginCmd.RegisterFlagCompletionFunc("ocmCluster", completeOcmClusters)
+
+	flags.StringVar(
+		&loginCmdArgs.search,
+		"search",
+		"",
+		"OCM search expression to pick the cluster from, e.g. \"region.id = 'us-east-1'\"",
 	)
 
 	flags.StringVarP(
@@ -177,6 +91,7 @@ func init() {
 		"production",
 		"OCM environemnt (production, staging)",
 	)
+	loginCmd.RegisterFlagCompletionFunc("ocmEnvironment", completeOcmEnvironments)
 
 	flags.BoolVar(
 		&loginCmdArgs.isOcmLoginOnly,
@@ -185,6 +100,13 @@ func init() {
 		"Log in to OCM only.",
 	)
 
+	flags.BoolVar(
+		&loginCmdArgs.hardened,
+		"hardened",
+		false,
+		"Run the container unprivileged with a read-only root filesystem.",
+	)
+
 	flags.StringVarP(
 		&loginCmdArgs.extraContainerPortMaps,
 		"extraContainerPortMaps",
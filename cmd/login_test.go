@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	pkgInt "hc/internal"
+	pkgIntHelper "hc/internal/helpers"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+// Compares a text to a golden file in testdata, or writes the golden file with
+// -update.
+func checkGolden(t *testing.T, name string, actual string) {
+	t.Helper()
+	golden := filepath.Join("testdata", name+".golden")
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(golden, []byte(actual), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v, run the tests with -update to create it", err)
+	}
+	if actual != string(expected) {
+		t.Errorf("%s differs from %s:\n%s", name, golden, actual)
+	}
+}
+
+// A host set up for hc: a config, a backplane config with a reachable
+// proxy and an OCM token file, in a temporary home directory.
+type fakeHost struct {
+	home   string
+	engine *pkgInt.FakeEngine
+	runner *pkgIntHelper.RecordingRunner
+}
+
+func newFakeHost(t *testing.T, respond func(ctx context.Context, command *pkgIntHelper.Command) *pkgIntHelper.Result) *fakeHost {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+	t.Setenv("TMPDIR", home)
+
+	proxy := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(proxy.Close)
+	backplaneDir := filepath.Join(home, ".config", "backplane")
+	if err := os.MkdirAll(backplaneDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	backplaneConfig := fmt.Sprintf(`{"url": "https://api.backplane.example.com", "proxy-url": %q}`, proxy.URL)
+	if err := os.WriteFile(filepath.Join(backplaneDir, "config.prod.json"), []byte(backplaneConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tokenFile := filepath.Join(home, "ocm-token")
+	if err := os.WriteFile(tokenFile, []byte("secret-ocm-token"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	pkgInt.SetConfigDefaults()
+	for key, value := range map[string]interface{}{
+		"hostUser":                 "me",
+		"ocUser":                   "me",
+		"userHome":                 home,
+		"backplaneConfigProd":      "config.prod.json",
+		"ocmCLIVersion":            "0.1.70",
+		"backplaneCLIVersion":      "0.1.20",
+		"ocmLongLivedTokenPath":    tokenFile,
+		"ocmCLIAlias.production":   "/opt/ocm-production.sh",
+		"sessionRecording.enabled": false,
+	} {
+		viper.Set(key, value)
+	}
+
+	host := &fakeHost{
+		home:   home,
+		engine: pkgInt.NewFakeEngine(),
+		runner: &pkgIntHelper.RecordingRunner{Respond: respond},
+	}
+	previousEngine := newContainerEngine
+	newContainerEngine = func() pkgInt.ContainerEngine { return host.engine }
+	t.Cleanup(func() { newContainerEngine = previousEngine })
+	t.Cleanup(pkgIntHelper.SetRunner(host.runner))
+	return host
+}
+
+// Replaces the values that change between runs in recorded commands.
+func (h *fakeHost) normalize(text string) string {
+	for _, replacement := range []struct {
+		pattern string
+		value   string
+	}{
+		{regexp.QuoteMeta(h.home), "~"},
+		{`hc-config-\d+\.yaml`, "hc-config-N.yaml"},
+		{`(hc-[a-z-]+)-[0-9a-f]{6}\b`, "$1-XXXXXX"},
+		{`(HOST_[UG]ID)=\d+`, "$1=N"},
+		{`OPENSHIFT_CONSOLE_PORT=\d+`, "OPENSHIFT_CONSOLE_PORT=PORT"},
+		{`127\.0\.0\.1:\d+:\d+`, "127.0.0.1:PORT:PORT"},
+		{`AppendPortMap \d+ \d+`, "AppendPortMap PORT PORT"},
+	} {
+		text = regexp.MustCompile(replacement.pattern).ReplaceAllString(text, replacement.value)
+	}
+	return text
+}
+
+// Responds to the commands of a workspace as podman and OCM would.
+func respondAsWorkspaceHost(exitCode int) func(ctx context.Context, command *pkgIntHelper.Command) *pkgIntHelper.Result {
+	return func(ctx context.Context, command *pkgIntHelper.Command) *pkgIntHelper.Result {
+		args := strings.Join(command.Args, " ")
+		switch {
+		case strings.Contains(args, "/api/clusters_mgmt/v1/clusters"):
+			return &pkgIntHelper.Result{Stdout: []byte(`{"items": [{
+				"id": "1a2b3c", "name": "my-cluster", "state": "ready",
+				"openshift_version": "4.14.1", "region": {"id": "us-east-1"}, "product": {"id": "osd"}
+			}]}`)}
+		case command.Name == "podman" && command.Args[0] == "info":
+			return &pkgIntHelper.Result{Stdout: []byte("true\n")}
+		case command.Name == "podman" && command.Args[0] == "run":
+			return &pkgIntHelper.Result{ExitCode: exitCode}
+		}
+		return &pkgIntHelper.Result{}
+	}
+}
+
+func TestLogin(t *testing.T) {
+	host := newFakeHost(t, respondAsWorkspaceHost(3))
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	log.SetLevel(log.DebugLevel)
+	defer func() {
+		log.SetOutput(os.Stderr)
+		log.SetLevel(log.InfoLevel)
+	}()
+
+	exitCode, err := runLogin(context.Background(), &workspaceOptions{
+		cluster:        "my-cluster",
+		ocmEnvironment: "production",
+		interactive:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exitCode != 3 {
+		t.Errorf("exit code %d, expected the exit code of the container, 3", exitCode)
+	}
+
+	checkGolden(t, "login-commands", host.normalize(host.runner.String()+"\n"))
+	checkGolden(t, "login-engine-calls", host.normalize(strings.Join(host.engine.Calls, "\n")+"\n"))
+	// The token is passed to podman, but masked wherever the command is shown
+	passed := false
+	for _, command := range host.runner.Commands() {
+		if command.Name == "podman" && command.Args[0] == "run" {
+			passed = strings.Contains(strings.Join(command.Args, " "), "OCM_TOKEN=secret-ocm-token")
+		}
+	}
+	if !passed {
+		t.Error("the OCM token is not passed to the workspace")
+	}
+	if strings.Contains(host.runner.String(), "secret-ocm-token") || strings.Contains(logs.String(), "secret-ocm-token") {
+		t.Error("the OCM token is shown in the logged commands")
+	}
+
+	if containers := pkgInt.ListWorkspaceContainers(); len(containers) > 0 {
+		t.Errorf("workspace containers still registered: %v", containers)
+	}
+	configs, _ := filepath.Glob(filepath.Join(host.home, "hc-config-*.yaml"))
+	if len(configs) > 0 {
+		t.Errorf("effective configs left behind: %v", configs)
+	}
+}
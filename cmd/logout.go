@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	pkgInt "hc/internal"
+	pkgIntHelper "hc/internal/helpers"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	logoutCmdArgs struct {
+		ocmEnvironment string
+		all            bool
+	}
+)
+
+var logoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Wipes the persisted workspace state of an OCM environment",
+	Long: `Wipes the OCM config, backplane sessions and kubeconfig persisted for an OCM
+environment, see persistentState in the hc config. The state cannot be wiped
+while a workspace uses it.`,
+	Args:   cobra.NoArgs,
+	PreRun: pkgInt.ToggleDebug,
+	Run:    logout,
+	Annotations: map[string]string{
+		configOptionalAnnotation: "true",
+	},
+}
+
+func logout(cmd *cobra.Command, args []string) {
+	config := getBackplaneHcConfig()
+
+	environments := []string{logoutCmdArgs.ocmEnvironment}
+	if logoutCmdArgs.all {
+		// The state of every environment is under the same directory
+		entries, err := os.ReadDir(config.GetPersistentStateDir(""))
+		if err != nil && !os.IsNotExist(err) {
+			log.Fatal("Failed to list the persisted states: ", err)
+		}
+		environments = []string{}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				environments = append(environments, entry.Name())
+			}
+		}
+	}
+
+	failed := false
+	for _, environment := range environments {
+		stateDir := config.GetPersistentStateDir(environment)
+		if _, err := os.Stat(stateDir); os.IsNotExist(err) {
+			fmt.Printf("No persisted state for %s\n", environment)
+			continue
+		}
+		if err := pkgInt.WipeState(stateDir); err != nil {
+			log.Errorf("Failed to wipe the persisted state of %s: %v", environment, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("Wiped the persisted state of %s\n", environment)
+	}
+	if failed {
+		pkgIntHelper.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(logoutCmd)
+	logoutCmd.Flags().StringVarP(
+		&logoutCmdArgs.ocmEnvironment,
+		"env",
+		"e",
+		"production",
+		"OCM environment of the state",
+	)
+	logoutCmd.Flags().BoolVar(
+		&logoutCmdArgs.all,
+		"all",
+		false,
+		"Wipe the state of every OCM environment",
+	)
+	logoutCmd.MarkFlagsMutuallyExclusive("env", "all")
+	logoutCmd.RegisterFlagCompletionFunc("env", completeOcmEnvironments)
+}
@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	pkgInt "hc/internal"
+	pkgIntHelper "hc/internal/helpers"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// Namespace lists are refreshed after this period
+const namespaceCacheMaxAge = 10 * time.Minute
+
+var (
+	nsCmdArgs struct {
+		list    bool
+		refresh bool
+	}
+)
+
+var nsCmd = &cobra.Command{
+	Use:   "ns [name|-]",
+	Short: "Switches the namespace of the current kubeconfig context",
+	Long: `Switches the namespace of the current kubeconfig context. The name may be a
+fuzzy match of a namespace name, "-" switches back to the previous namespace
+of the context. Without a name, a namespace is picked interactively, or the
+namespaces are listed if there is no terminal.
+
+Namespace lists are cached per cluster for 10 minutes.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeFirstArg(getCachedNamespaces),
+	PreRun:            pkgInt.ToggleDebug,
+	Run:               switchNamespace,
+	Annotations: map[string]string{
+		configOptionalAnnotation: "true",
+	},
+}
+
+func getNamespaceCacheName(server string) string {
+	sum := sha256.Sum256([]byte(server))
+	return "namespaces/" + hex.EncodeToString(sum[:8])
+}
+
+// Lists the namespaces of the current cluster, or the projects if the user
+// cannot list namespaces.
+func listNamespaces(ctx context.Context) ([]string, error) {
+	var err error
+	for _, resource := range []string{"namespaces", "projects"} {
+		var out []byte
+		out, err = pkgIntHelper.RunOutput(
+			ctx,
+			"oc",
+			"get",
+			resource,
+			"-o",
+			"jsonpath={.items[*].metadata.name}",
+		)
+		if err == nil {
+			namespaces := strings.Fields(string(out))
+			sort.Strings(namespaces)
+			return namespaces, nil
+		}
+	}
+	return nil, err
+}
+
+// Gets the namespaces of the current cluster from the cache unless it is
+// stale or a refresh is forced.
+func getNamespaces(ctx context.Context, server string, refresh bool) ([]string, error) {
+	cacheName := getNamespaceCacheName(server)
+	var namespaces []string
+	if !refresh && pkgInt.ReadCache(cacheName, namespaceCacheMaxAge, &namespaces) {
+		return namespaces, nil
+	}
+
+	namespaces, err := listNamespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := pkgInt.WriteCache(cacheName, namespaces); err != nil {
+		log.Debugf("Failed to cache namespaces: %v", err)
+	}
+	return namespaces, nil
+}
+
+func containsString(items []string, value string) bool {
+	for _, item := range items {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func switchNamespace(cmd *cobra.Command, args []string) {
+	kubeconfig := loadKubeconfig()
+	context, err := kubeconfig.GetCurrentContext()
+	if err != nil {
+		log.Fatal(err)
+	}
+	cluster, err := kubeconfig.GetCurrentCluster()
+	if err != nil {
+		log.Fatal(err)
+	}
+	current := context.Context.Namespace
+
+	refresh := nsCmdArgs.refresh
+	namespaces, err := getNamespaces(cmd.Context(), cluster.Cluster.Server, refresh)
+	if err != nil {
+		log.Warnf("Failed to list namespaces: %v", err)
+	}
+
+	if nsCmdArgs.list || (len(args) == 0 && !isTerminal()) {
+		if err != nil {
+			log.Fatal("Failed to list namespaces")
+		}
+		printItems(namespaces, current)
+		return
+	}
+
+	state := loadKubeconfigState()
+	var target string
+	switch {
+	case len(args) == 0:
+		target, err = pickItem(cmd.Context(), "namespace", namespaces, "")
+	case args[0] == "-":
+		target = state.PreviousNamespaces[context.Name]
+		if len(target) == 0 {
+			log.Fatalf("No previous namespace in context %q", context.Name)
+		}
+	case err != nil:
+		// Switch blindly if namespaces cannot be listed
+		target, err = args[0], nil
+	default:
+		// The namespace may have been created since the list was cached
+		if !containsString(namespaces, args[0]) && !refresh {
+			if refreshed, err := getNamespaces(cmd.Context(), cluster.Cluster.Server, true); err == nil {
+				namespaces = refreshed
+			}
+		}
+		target, err = resolveItem(cmd.Context(), "namespace", namespaces, args[0])
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if target == current {
+		fmt.Printf("Already on namespace %q\n", target)
+		return
+	}
+	if err := kubeconfig.SetContextNamespace(context.Name, target); err != nil {
+		log.Fatal("Failed to switch namespace: ", err)
+	}
+	if len(current) > 0 {
+		state.PreviousNamespaces[context.Name] = current
+		saveKubeconfigState(state)
+	}
+	fmt.Printf("Switched to namespace %q\n", target)
+}
+
+func init() {
+	rootCmd.AddCommand(nsCmd)
+	nsCmd.Flags().BoolVarP(
+		&nsCmdArgs.list,
+		"list",
+		"l",
+		false,
+		"List the namespaces",
+	)
+	nsCmd.Flags().BoolVarP(
+		&nsCmdArgs.refresh,
+		"refresh",
+		"r",
+		false,
+		"Refresh the cached namespaces",
+	)
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	pkgInt "hc/internal"
+	pkgIntHelper "hc/internal/helpers"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Clusters are picked from at most this many search results
+const ocmClusterSearchSize = 100
+
+// Formats a cluster as a line of the cluster picker.
+func formatOcmCluster(cluster *pkgInt.OcmCluster) string {
+	return fmt.Sprintf(
+		"%-32s %-32s %-12s %-8s %-14s %s",
+		cluster.Name,
+		cluster.ID,
+		cluster.State,
+		cluster.Version,
+		cluster.Region,
+		cluster.Product,
+	)
+}
+
+// Gets the lines of the cluster picker, one per cluster id, ranked by how well
+// the cluster names match a query, along with their clusters. Names are not
+// unique, so each line shows the id of its cluster.
+func getOcmClusterPickerItems(clusters []pkgInt.OcmCluster, query string) ([]string, []*pkgInt.OcmCluster) {
+	byID := map[string]*pkgInt.OcmCluster{}
+	ids := []string{}
+	for idx := range clusters {
+		if _, found := byID[clusters[idx].ID]; !found {
+			ids = append(ids, clusters[idx].ID)
+		}
+		byID[clusters[idx].ID] = &clusters[idx]
+	}
+	if len(query) > 0 {
+		sort.SliceStable(ids, func(i, j int) bool {
+			return pkgIntHelper.FuzzyScore(query, byID[ids[i]].Name) > pkgIntHelper.FuzzyScore(query, byID[ids[j]].Name)
+		})
+	}
+
+	lines := []string{}
+	items := []*pkgInt.OcmCluster{}
+	for _, id := range ids {
+		lines = append(lines, formatOcmCluster(byID[id]))
+		items = append(items, byID[id])
+	}
+	return lines, items
+}
+
+// Picks one of the clusters, ranked by how well their name matches a query.
+func pickOcmCluster(ctx context.Context, clusters []pkgInt.OcmCluster, query string) (*pkgInt.OcmCluster, error) {
+	lines, items := getOcmClusterPickerItems(clusters, query)
+	switch {
+	case len(items) == 0:
+		return nil, errors.New("no cluster found")
+	case len(items) == 1:
+		return items[0], nil
+	}
+
+	if !isTerminal() {
+		fmt.Fprintln(os.Stderr, strings.Join(lines, "\n"))
+		return nil, fmt.Errorf("%d clusters match, pass an exact --ocmCluster or a narrower --search", len(lines))
+	}
+	line, err := pickItem(ctx, "cluster", lines, "")
+	if err != nil {
+		return nil, err
+	}
+	for idx := range lines {
+		if lines[idx] == line {
+			return items[idx], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown cluster: %s", line)
+}
+
+// Resolves the cluster to log in to from a name, id or external id, a part of
+// a name or an OCM search expression. Without any, a cluster is picked from
+// the clusters found before.
+func resolveOcmCluster(ctx context.Context, ocmCli *pkgInt.OcmCli, query string, search string) (*pkgInt.OcmCluster, error) {
+	switch {
+	case len(search) > 0:
+		clusters, err := ocmCli.SearchClusters(search, ocmClusterSearchSize)
+		if err != nil {
+			return nil, err
+		}
+		return pickOcmCluster(ctx, clusters, query)
+
+	case len(query) > 0:
+		// Looked up again, a cached cluster may have been deleted or changed
+		// state since
+		if cluster, err := ocmCli.LookupCluster(query); err == nil {
+			return cluster, nil
+		} else {
+			log.Debugf("No exact match for cluster %s: %v", query, err)
+		}
+		clusters, err := ocmCli.SearchClustersByName(query, ocmClusterSearchSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(clusters) == 0 {
+			// Fall back to the clusters found before, which match fuzzily
+			for _, cluster := range ocmCli.GetClusterIndex() {
+				if pkgIntHelper.FuzzyScore(query, cluster.Name) >= 0 {
+					clusters = append(clusters, cluster)
+				}
+			}
+		}
+		if len(clusters) == 0 {
+			return nil, fmt.Errorf("no cluster matches %q", query)
+		}
+		return pickOcmCluster(ctx, clusters, query)
+	}
+
+	clusters := ocmCli.GetClusterIndex()
+	if len(clusters) == 0 {
+		return nil, errors.New("no cluster given, pass --ocmCluster or --search")
+	}
+	return pickOcmCluster(ctx, clusters, "")
+}
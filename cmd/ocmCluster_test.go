@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	pkgInt "hc/internal"
+	pkgIntOcm "hc/internal/ocm"
+)
+
+// Clusters of the same name are told apart by their id.
+func TestOcmClusterPickerItems(t *testing.T) {
+	clusters := []pkgInt.OcmCluster{
+		{Name: "my-cluster", ID: "1a2b3c", State: "ready"},
+		{Name: "other", ID: "4d5e6f", State: "ready"},
+		{Name: "my-cluster", ID: "7g8h9i", State: "hibernating"},
+		{Name: "my-cluster-2", ID: "0j1k2l", State: "ready"},
+		// Found again, e.g. in the cluster index
+		{Name: "my-cluster", ID: "1a2b3c", State: "ready"},
+	}
+
+	lines, items := getOcmClusterPickerItems(clusters, "my-cluster")
+	ids := []string{}
+	for idx, item := range items {
+		ids = append(ids, item.ID)
+		if !strings.Contains(lines[idx], item.ID) {
+			t.Errorf("line %q does not show the id %s", lines[idx], item.ID)
+		}
+	}
+	// Exact matches first, then prefixes, then the others
+	if expected := []string{"1a2b3c", "7g8h9i", "0j1k2l", "4d5e6f"}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("picker clusters %v, expected %v", ids, expected)
+	}
+
+	if cluster, err := pickOcmCluster(context.Background(), clusters[:1], ""); err != nil || cluster.ID != "1a2b3c" {
+		t.Errorf("picked %v (%v), expected the only cluster", cluster, err)
+	}
+}
+
+// The cluster to log in to is looked up in OCM even if it is cached.
+func TestResolveOcmClusterSkipsCache(t *testing.T) {
+	host := newFakeHost(t, nil)
+	fake := host.useFakeOcm(t, pkgIntOcm.NewFakeCluster("1a2b3c", "my-cluster", "us-east-1"))
+	ocmCli := newOcmCli("production", pkgInt.OcmCliAlias{})
+
+	if _, err := ocmCli.GetCluster("my-cluster"); err != nil {
+		t.Fatal(err)
+	}
+	requests := len(fake.GetRequests())
+	cluster, err := resolveOcmCluster(context.Background(), ocmCli, "my-cluster", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cluster.ID != "1a2b3c" {
+		t.Errorf("resolved %s, expected 1a2b3c", cluster.ID)
+	}
+	if len(fake.GetRequests()) == requests {
+		t.Error("the cluster to log in to was taken from the cache")
+	}
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	pkgIntHelper "hc/internal/helpers"
+
+	"golang.org/x/term"
+)
+
+var errPickCancelled = errors.New("nothing selected")
+
+// Whether hc runs in a terminal, replaced in tests
+var isTerminal = func() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// Picks one of the items interactively, with fzf if it is installed,
+// otherwise from a numbered list. The query narrows down the items.
+func pickItem(ctx context.Context, title string, items []string, query string) (string, error) {
+	if !isTerminal() {
+		return "", fmt.Errorf("no terminal to pick a %s from", title)
+	}
+
+	if fzf, err := exec.LookPath("fzf"); err == nil {
+		command := pkgIntHelper.NewCommand(
+			fzf,
+			"--height=40%",
+			"--reverse",
+			"--select-1",
+			"--exit-0",
+			"--prompt", title+"> ",
+			"--query", query,
+		)
+		command.Stdin = strings.NewReader(strings.Join(items, "\n"))
+		command.Stderr = os.Stderr
+		result, err := pkgIntHelper.Run(ctx, command)
+		// fzf exits with 130 when the picker is quit and with 1 when nothing
+		// matches
+		switch code := pkgIntHelper.GetExitCode(err); {
+		case err == nil:
+		case ctx.Err() != nil || code == 130:
+			return "", errPickCancelled
+		case code == 1:
+			return "", fmt.Errorf("no %s matches %q", title, query)
+		default:
+			return "", err
+		}
+		// Items may end with spaces, e.g. padded columns
+		selected := strings.TrimRight(string(result.Stdout), "\n")
+		if len(selected) == 0 {
+			return "", errPickCancelled
+		}
+		return selected, nil
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		candidates := items
+		if len(query) > 0 {
+			candidates = pkgIntHelper.FuzzyFilter(query, items)
+		}
+		switch len(candidates) {
+		case 0:
+			return "", fmt.Errorf("no %s matches %q", title, query)
+		case 1:
+			return candidates[0], nil
+		}
+
+		for idx, candidate := range candidates {
+			fmt.Fprintf(os.Stderr, "%3d) %s\n", idx+1, candidate)
+		}
+		fmt.Fprintf(os.Stderr, "Select a %s [1-%d] or type to filter: ", title, len(candidates))
+		line, err := reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if err != nil || len(line) == 0 {
+			return "", errPickCancelled
+		}
+		if idx, err := strconv.Atoi(line); err == nil && idx >= 1 && idx <= len(candidates) {
+			return candidates[idx-1], nil
+		}
+		query = line
+		items = candidates
+	}
+}
+
+// Resolves a name from a query: an exact match, the only fuzzy match or one
+// picked interactively from the fuzzy matches.
+func resolveItem(ctx context.Context, title string, items []string, query string) (string, error) {
+	for _, item := range items {
+		if item == query {
+			return item, nil
+		}
+	}
+
+	matches := pkgIntHelper.FuzzyFilter(query, items)
+	switch {
+	case len(matches) == 0:
+		return "", fmt.Errorf("no %s matches %q", title, query)
+	case len(matches) == 1:
+		return matches[0], nil
+	case !isTerminal():
+		return "", fmt.Errorf("%q matches several %ss: %s", query, title, strings.Join(matches, ", "))
+	}
+	return pickItem(ctx, title, matches, "")
+}
+
+// Prints items one per line, marking the current one as oc projects does.
+func printItems(items []string, current string) {
+	for _, item := range items {
+		marker := "  "
+		if item == current {
+			marker = "* "
+		}
+		fmt.Println(marker + item)
+	}
+}
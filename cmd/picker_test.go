@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pkgInt "hc/internal"
+)
+
+// Installs a fake fzf running a shell script, in a terminal.
+func useFakeFzf(t *testing.T, script string) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "fzf"), []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	previous := isTerminal
+	isTerminal = func() bool { return true }
+	t.Cleanup(func() { isTerminal = previous })
+}
+
+// Padded lines are picked as they are, e.g. those of clusters without a product.
+func TestPickOcmClusterWithFzf(t *testing.T) {
+	useFakeFzf(t, "tail -n 1")
+	clusters := []pkgInt.OcmCluster{
+		{Name: "my-cluster", ID: "1a2b3c", State: "ready", Product: "rosa"},
+		{Name: "my-cluster", ID: "4d5e6f", State: "ready"},
+	}
+
+	cluster, err := pickOcmCluster(context.Background(), clusters, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cluster.ID != "4d5e6f" {
+		t.Errorf("picked %s, expected 4d5e6f", cluster.ID)
+	}
+}
+
+func TestPickItemWithFzfErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		// Whether the pick is cancelled rather than failed
+		cancelled bool
+	}{
+		{name: "quit", script: "exit 130", cancelled: true},
+		{name: "nothing selected", script: "exit 0", cancelled: true},
+		{name: "no match", script: "exit 1"},
+		{name: "fzf error", script: "echo 'unknown option' >&2; exit 2"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useFakeFzf(t, test.script)
+			_, err := pickItem(context.Background(), "cluster", []string{"one", "two"}, "")
+			if err == nil {
+				t.Fatal("no error")
+			}
+			if cancelled := errors.Is(err, errPickCancelled); cancelled != test.cancelled {
+				t.Errorf("error %v, expected cancelled %t", err, test.cancelled)
+			}
+		})
+	}
+
+	// Cancelling the command cancels the pick
+	useFakeFzf(t, "exec sleep 5")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := pickItem(ctx, "cluster", []string{"one", "two"}, ""); !errors.Is(err, errPickCancelled) {
+		t.Errorf("error %v, expected the pick to be cancelled", err)
+	}
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+
+	pkgInt "hc/internal"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	promptCmdArgs struct {
+		shell    string
+		template string
+		noColor  bool
+	}
+)
+
+var promptCmd = &cobra.Command{
+	Use:   "prompt",
+	Short: "Renders the workspace prompt.",
+	Long: `Renders the workspace prompt from the "prompt.template" Go template in the hc
+config. The kubeconfig and OCM config are read directly and cached until they
+change, so that the prompt stays fast. The hc config is read without being
+validated, and the default template is used when it cannot be read.
+
+Template values:
+  .User            workspace user
+  .OcmEnvironment  OCM environment
+  .Cluster         backplane cluster of the current context
+  .Context         current kubeconfig context
+  .Namespace       namespace of the current context
+  .Server          API server of the current context
+  .Backplane       whether the current context goes through backplane
+  .Elevated        whether the current user impersonates another user
+  .ElevationExpiresIn
+                   time until the elevation with hc elevate is dropped
+  .TokenExpiresIn  time until the OCM access token expires
+  .TokenExpiring   whether the OCM access token expires within 10 minutes
+
+Colors are applied with {{color "<name>" <value>}} when "prompt.colors" is
+enabled. Available colors: black, red, green, yellow, blue, magenta, cyan,
+white and bold.`,
+	PreRun: pkgInt.ToggleDebug,
+	Run:    prompt,
+	Annotations: map[string]string{
+		configSkipAnnotation: "true",
+	},
+}
+
+func prompt(cmd *cobra.Command, args []string) {
+	// The prompt falls back to its defaults rather than breaking the shell
+	var promptConfig pkgInt.PromptConfig
+	if err := pkgInt.LoadConfigLayers(getUserConfigFile(), cfgFile != ""); err != nil {
+		log.Debugf("Failed to read the config, using the default prompt: %v", err)
+	} else if config, err := pkgInt.GetHcConfig(); err == nil {
+		promptConfig = config.Prompt
+	}
+
+	tmpl := promptConfig.Template
+	if cmd.Flags().Changed("template") {
+		tmpl = promptCmdArgs.template
+	}
+
+	info := pkgInt.GetPromptInfo()
+	colors := promptConfig.Colors && !promptCmdArgs.noColor
+	out, err := pkgInt.RenderPrompt(tmpl, info, promptCmdArgs.shell, colors)
+	if err != nil {
+		log.Debugf("Failed to render the prompt, using the default template: %v", err)
+		out, _ = pkgInt.RenderPrompt(pkgInt.DefaultPromptTemplate, info, promptCmdArgs.shell, colors)
+	}
+	fmt.Print(out)
+}
+
+func init() {
+	rootCmd.AddCommand(promptCmd)
+
+	promptCmd.Flags().StringVarP(
+		&promptCmdArgs.shell,
+		"shell",
+		"s",
+		"",
+		"Shell that renders the prompt (bash, zsh, fish)",
+	)
+	promptCmd.Flags().StringVarP(
+		&promptCmdArgs.template,
+		"template",
+		"t",
+		"",
+		"Prompt template, overrides the config",
+	)
+	promptCmd.Flags().BoolVar(
+		&promptCmdArgs.noColor,
+		"no-color",
+		false,
+		"Disable colors",
+	)
+}
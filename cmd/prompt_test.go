@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pkgInt "hc/internal"
+
+	"github.com/spf13/viper"
+)
+
+// Runs hc with the given args and gets what it prints.
+func executeRoot(t *testing.T, args ...string) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	rootCmd.SetArgs(args)
+	err = rootCmd.Execute()
+	os.Stdout = stdout
+	writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+// The prompt neither validates the config nor fails on it.
+func TestPromptSkipsConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+	t.Cleanup(viper.Reset)
+	configErr = nil
+	t.Cleanup(func() { configErr = nil })
+
+	defaultPrompt, err := pkgInt.RenderPrompt(pkgInt.DefaultPromptTemplate, pkgInt.GetPromptInfo(), "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configFile := filepath.Join(home, ".hc.yaml")
+	for content, expected := range map[string]string{
+		// Required keys are missing and a key is misspelled
+		"ocUser: me\nshel: bash\nprompt:\n  template: \"[hc] $ \"\n": "[hc] $ ",
+		"prompt: [": defaultPrompt,
+	} {
+		viper.Reset()
+		if err := os.WriteFile(configFile, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if out := executeRoot(t, "prompt", "--shell", "", "--no-color"); out != expected {
+			t.Errorf("prompt %q, expected %q", out, expected)
+		}
+		if configErr != nil {
+			t.Errorf("config loaded for the prompt: %v", configErr)
+		}
+	}
+}
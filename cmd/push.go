@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	pkgInt "hc/internal"
+	pkgIntHelper "hc/internal/helpers"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	pushCmdArgs struct {
+		latest bool
+	}
+)
+
+var pushCmd = &cobra.Command{
+	Use:    "push",
+	Short:  "Pushes the locally built hc image to the configured registry",
+	PreRun: pkgInt.ToggleDebug,
+	Run:    push,
+}
+
+func push(cmd *cobra.Command, args []string) {
+	ce := newContainerEngine()
+	config := getHcConfig()
+	ce.SetTLSVerify(config.Image.TLSVerify)
+
+	remoteImages := []string{config.GetRemoteImage()}
+	if len(remoteImages[0]) == 0 {
+		log.Fatal("No image registry configured. Please set \"image.registry\" in the hc config")
+	}
+	if pushCmdArgs.latest {
+		remoteImages = append(remoteImages, config.GetRemoteImageWithTag("latest"))
+	}
+
+	localImage := config.GetLocalImage()
+	if !imageExists(cmd.Context(), ce, localImage) {
+		log.Fatalf("Image %s not found. Please run \"hc build\" first", localImage)
+	}
+
+	for _, remoteImage := range remoteImages {
+		for _, ceCmd := range [][]string{
+			ce.GetTagCmd(localImage, remoteImage),
+			ce.GetPushCmd(remoteImage),
+		} {
+			if err := pkgIntHelper.RunStreamed(cmd.Context(), ce.GetExecName(), ceCmd...); err != nil {
+				log.Fatalf("Failed to push image %s: %v", remoteImage, err)
+			}
+		}
+		log.Infof("Pushed image %s", remoteImage)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(pushCmd)
+
+	pushCmd.Flags().BoolVar(
+		&pushCmdArgs.latest,
+		"latest",
+		false,
+		"Also push the image with the \"latest\" tag.",
+	)
+}
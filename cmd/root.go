@@ -1,68 +1,117 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
 	"os"
-
-	log "github.com/sirupsen/logrus"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 
 	pkgInt "hc/internal"
+	pkgIntHelper "hc/internal/helpers"
 )
 
 var cfgFile string
 
+// Error encountered while loading the hc config. Commands fail with it
+// unless they are annotated with configOptionalAnnotation.
+var configErr error
+
+// Whether the config layers were loaded, even if the config is invalid
+var configLoaded bool
+
+const configOptionalAnnotation = "hc.config.optional"
+
+// Commands annotated with configSkipAnnotation neither load nor validate the
+// hc config, e.g. the prompt that runs on every shell prompt.
+const configSkipAnnotation = "hc.config.skip"
+
 var rootCmd = &cobra.Command{
 	Use:   "hc",
 	Short: "Hybric cloud containerized environment",
 	Long: `A CLI for locally provisioning a container that provides a management 
 	environment for managing an OpenShift-based hybrid cloud.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if cmd.Annotations[configSkipAnnotation] == "true" {
+			return
+		}
+		initConfig()
+		if configErr != nil && !isConfigOptional(cmd) {
+			fmt.Fprintln(os.Stderr, configErr)
+			pkgIntHelper.Exit(1)
+		}
+	},
 }
 
 func Execute() {
-	err := rootCmd.Execute()
+	ctx, stop := pkgIntHelper.HandleSignals(context.Background())
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
 	if err != nil {
-		os.Exit(1)
+		pkgIntHelper.Exit(1)
 	}
+	pkgIntHelper.RunCleanups()
 }
 
 func init() {
-	cobra.OnInitialize(initConfig)
 	rootCmd.PersistentFlags().BoolVarP(&pkgInt.Debug, "debug", "d", false, "verbose logging")
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.hc.yaml)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "user config file (default is $HOME/.hc.yaml)")
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
 
-func initConfig() {
-	if cfgFile != "" {
-		viper.SetConfigFile(cfgFile)
-	} else {
-		var src string
-		if os.Getenv("IS_IN_CONTAINER") == "true" {
-			src = "/"
-		} else {
-			home, err := os.UserHomeDir()
-			src = home
-			cobra.CheckErr(err)
+// Commands generated by cobra that never need the config
+var configOptionalCommands = map[string]bool{
+	"help":                          true,
+	"completion":                    true,
+	cobra.ShellCompRequestCmd:       true,
+	cobra.ShellCompNoDescRequestCmd: true,
+}
+
+// Checks whether a command, or any of its parents, can run without a valid config.
+func isConfigOptional(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		if c.Annotations[configOptionalAnnotation] == "true" || configOptionalCommands[c.Name()] {
+			return true
 		}
-		viper.AddConfigPath(src)
-		viper.SetConfigType("yaml")
-		viper.SetConfigName(".hc")
 	}
+	return false
+}
+
+// Gets the user config file, $HOME/.hc.yaml on the host or the config
+// mounted by login in the container.
+func getUserConfigFile() string {
+	if cfgFile != "" {
+		return cfgFile
+	}
+	if isInContainer() {
+		return "/.hc.yaml"
+	}
+	home, err := os.UserHomeDir()
+	cobra.CheckErr(err)
+	return filepath.Join(home, ".hc.yaml")
+}
 
-	viper.AutomaticEnv() // read in environment variables that match
+func initConfig() {
+	pkgInt.SetConfigDefaults()
 
-	// If a config file is found, read it in.
-	err := viper.ReadInConfig()
+	err := pkgInt.LoadConfigLayers(getUserConfigFile(), cfgFile != "")
 	if err != nil {
-		log.Fatal("Failed to read config file: ", err)
+		configErr = fmt.Errorf("Failed to read config file: %w", err)
+		return
 	}
-
-	if err = pkgInt.ValidateConfig(); err != nil {
-		log.Fatalf("Invalid config: %s", err)
+	configLoaded = true
+	if keys := pkgInt.GetIgnoredProjectKeys(); len(keys) > 0 {
+		fmt.Fprintf(
+			os.Stderr,
+			"Warning: ignoring %s of the project config, only the system and user configs may set them unless %s=true\n",
+			strings.Join(keys, ", "),
+			pkgInt.TrustProjectConfigEnvVar,
+		)
 	}
 
-	// Todo: Handle os signals
-
+	if err = pkgInt.ValidateConfig(!isInContainer()); err != nil {
+		configErr = fmt.Errorf("Invalid config: %w", err)
+	}
 }
@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	pkgInt "hc/internal"
+	pkgIntHelper "hc/internal/helpers"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// Directory where the artifacts directory is mounted in the container
+	containerArtifactsDir = "/hc-artifacts"
+	// Path where the script is mounted in the container
+	containerRunScriptPath = "/hc-run-script"
+	// Time the workspace has to exit after an interrupt before it is killed
+	runGracePeriod = 10 * time.Second
+)
+
+var (
+	runCmdArgs struct {
+		cluster        string
+		search         string
+		ocmEnvironment string
+		hardened       bool
+		script         string
+		artifactsDir   string
+	}
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run -c <cluster> [--script file | -- command...]",
+	Short: "Runs a script or a command in an ephemeral workspace",
+	Long: `Runs a script or a command in an ephemeral workspace without a terminal. The
+workspace logs in to OCM and to the cluster, runs the payload as the oc user
+and is removed once the payload exits, including on SIGINT and SIGTERM.
+
+The payload runs in the artifacts directory, mounted from the host and also
+given in $ARTIFACTS_DIR. Files written there are kept on the host. hc run
+exits with the exit code of the payload.`,
+	Example: `  hc run -c my-cluster -- oc get nodes
+  hc run -c my-cluster --script must-gather.sh --artifacts ./out`,
+	PreRun: pkgInt.ToggleDebug,
+	Run:    run,
+	Args: func(cmd *cobra.Command, args []string) error {
+		hasCommand := cmd.ArgsLenAtDash() >= 0 && len(args) > 0
+		switch {
+		case cmd.ArgsLenAtDash() != 0 && len(args) > 0:
+			return fmt.Errorf("the command must follow \"--\"")
+		case len(runCmdArgs.script) > 0 && hasCommand:
+			return fmt.Errorf("either --script or a command is accepted, not both")
+		case len(runCmdArgs.script) == 0 && !hasCommand:
+			return fmt.Errorf("a script or a command after \"--\" is required")
+		}
+		return nil
+	},
+}
+
+func run(cmd *cobra.Command, args []string) {
+	var script string
+	if len(runCmdArgs.script) > 0 {
+		var err error
+		if script, err = filepath.Abs(runCmdArgs.script); err != nil {
+			log.Fatal(err)
+		}
+		if _, err := os.Stat(script); err != nil {
+			log.Fatal("Failed to read the script: ", err)
+		}
+	}
+
+	ctx := cmd.Context()
+	w := prepareWorkspace(ctx, &workspaceOptions{
+		cluster:        runCmdArgs.cluster,
+		search:         runCmdArgs.search,
+		ocmEnvironment: runCmdArgs.ocmEnvironment,
+		hardened:       runCmdArgs.hardened,
+	})
+	w.ce.SetAutoRemove(true)
+
+	artifactsDir := runCmdArgs.artifactsDir
+	if len(artifactsDir) == 0 {
+		artifactsDir = filepath.Join("hc-artifacts", w.name)
+	}
+	artifactsDir, err := filepath.Abs(artifactsDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.MkdirAll(artifactsDir, 0700); err != nil {
+		log.Fatalf("Failed to create the artifacts directory %s: %v", artifactsDir, err)
+	}
+	w.ce.AppendVolMap(artifactsDir, containerArtifactsDir, "rw")
+	w.ce.AppendEnvVar("ARTIFACTS_DIR", containerArtifactsDir)
+
+	var workspaceCmd *pkgIntHelper.Command
+	if len(script) > 0 {
+		w.ce.AppendVolMap(script, containerRunScriptPath, "ro")
+		workspaceCmd = w.getRunCommand("--script", containerRunScriptPath)
+	} else {
+		workspaceCmd = w.getRunCommand(append([]string{"--"}, args...)...)
+	}
+	w.register()
+	// The container is removed when it exits, unless it was killed
+	w.addCleanup("remove the workspace container", func() {
+		runCleanupCommand(w.ce.GetExecName(), w.ce.GetRemoveCmd(w.name)...)
+	})
+
+	workspaceCmd.ForwardSignals = true
+	workspaceCmd.Grace = runGracePeriod
+	_, err = pkgIntHelper.Run(ctx, workspaceCmd)
+	exitCode := pkgIntHelper.GetExitCode(err)
+	if exitCode < 0 {
+		log.Error("Failed to run the workspace: ", err)
+		exitCode = 1
+	}
+
+	w.close()
+
+	reportArtifacts(artifactsDir)
+	log.Infof("Exit code: %d", exitCode)
+	pkgIntHelper.Exit(exitCode)
+}
+
+// Logs where the artifacts are, and removes the artifacts directory if the
+// payload wrote none.
+func reportArtifacts(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Warnf("Failed to read the artifacts directory %s: %v", dir, err)
+		return
+	}
+	if len(entries) == 0 {
+		os.Remove(dir)
+		return
+	}
+	log.Infof("Artifacts: %s (%d entries)", dir, len(entries))
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+
+	flags := runCmd.Flags()
+	flags.StringVarP(
+		&runCmdArgs.cluster,
+		"ocmCluster",
+		"c",
+		"",
+		"Cluster name, id or external id, or a part of its name.",
+	)
+	runCmd.RegisterFlagCompletionFunc("ocmCluster", completeOcmClusters)
+
+	flags.StringVar(
+		&runCmdArgs.search,
+		"search",
+		"",
+		"OCM search expression to pick the cluster from, e.g. \"region.id = 'us-east-1'\"",
+	)
+
+	flags.StringVarP(
+		&runCmdArgs.ocmEnvironment,
+		"ocmEnvironment",
+		"e",
+		"production",
+		"OCM environemnt (production, staging)",
+	)
+	runCmd.RegisterFlagCompletionFunc("ocmEnvironment", completeOcmEnvironments)
+
+	flags.BoolVar(
+		&runCmdArgs.hardened,
+		"hardened",
+		false,
+		"Run the container unprivileged with a read-only root filesystem.",
+	)
+
+	flags.StringVar(
+		&runCmdArgs.script,
+		"script",
+		"",
+		"Script to run, with bash",
+	)
+
+	flags.StringVar(
+		&runCmdArgs.artifactsDir,
+		"artifacts",
+		"",
+		"Host directory of the artifacts, by default ./hc-artifacts/<container>",
+	)
+}
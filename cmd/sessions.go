@@ -0,0 +1,338 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	pkgInt "hc/internal"
+	pkgIntHelper "hc/internal/helpers"
+	pkgIntSession "hc/internal/session"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sessionsCmdArgs struct {
+		output     string
+		exportFile string
+		speed      float64
+		idleLimit  time.Duration
+		format     string
+		exitCode   int
+	}
+)
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "Lists, replays and exports recorded workspace sessions",
+}
+
+var sessionsListCmd = &cobra.Command{
+	Use:    "list",
+	Short:  "Lists the recorded workspace sessions",
+	Args:   cobra.NoArgs,
+	PreRun: pkgInt.ToggleDebug,
+	Run:    sessionsList,
+}
+
+var sessionsReplayCmd = &cobra.Command{
+	Use:               "replay <id>",
+	Short:             "Replays a recorded workspace session in the terminal",
+	Args:              cobra.ExactArgs(1),
+	PreRun:            pkgInt.ToggleDebug,
+	Run:               sessionsReplay,
+	ValidArgsFunction: completeFirstArg(getSessionIDs),
+}
+
+var sessionsExportCmd = &cobra.Command{
+	Use:   "export <id>",
+	Short: "Exports a recorded workspace session",
+	Long: `Exports a recorded workspace session as a gzipped tarball of its recording,
+command log and metadata, or only its command log as JSON lines.`,
+	Args:              cobra.ExactArgs(1),
+	PreRun:            pkgInt.ToggleDebug,
+	Run:               sessionsExport,
+	ValidArgsFunction: completeFirstArg(getSessionIDs),
+}
+
+// Runs the workspace shell in the container while recording it
+var sessionsRecordCmd = &cobra.Command{
+	Use:    "record -- <shell> [args...]",
+	Short:  "Records a workspace session.",
+	Hidden: true,
+	Args:   cobra.MinimumNArgs(1),
+	PreRun: pkgInt.ToggleDebug,
+	Run:    sessionsRecord,
+}
+
+// Called by the workspace shell after each command. The log is written by the
+// workspace user, who can also rewrite it.
+var sessionsLogCommandCmd = &cobra.Command{
+	Use:    "log-command -- <command>",
+	Short:  "Appends a command to the command log of the current session.",
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	Run:    sessionsLogCommand,
+}
+
+func getSessionRecordingDir() string {
+	return getHcConfig().GetSessionRecordingDir()
+}
+
+func sessionsList(cmd *cobra.Command, args []string) {
+	if sessionsCmdArgs.output != "text" && sessionsCmdArgs.output != "json" {
+		log.Fatalf("Unsupported output format: %s", sessionsCmdArgs.output)
+	}
+
+	dir := getSessionRecordingDir()
+	sessions, err := pkgIntSession.List(dir)
+	if err != nil {
+		log.Fatalf("Failed to list sessions in %s: %v", dir, err)
+	}
+
+	if sessionsCmdArgs.output == "json" {
+		out, err := json.MarshalIndent(sessions, "", "  ")
+		if err != nil {
+			log.Fatal("Failed to marshal sessions: ", err)
+		}
+		fmt.Println(string(out))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTARTED\tDURATION\tUSER\tCLUSTER\tENVIRONMENT\tCOMMANDS\tEXIT")
+	for _, meta := range sessions {
+		duration, exitCode := "running", "-"
+		if meta.EndedAt != nil {
+			duration = meta.EndedAt.Sub(meta.StartedAt).Round(time.Second).String()
+		}
+		if meta.ExitCode != nil {
+			exitCode = fmt.Sprint(*meta.ExitCode)
+		}
+		commands, err := pkgIntSession.ReadCommands(dir, meta.ID)
+		if err != nil {
+			log.Warn(err)
+		}
+		fmt.Fprintf(
+			w,
+			"%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			meta.ID,
+			meta.StartedAt.Local().Format(time.DateTime),
+			duration,
+			meta.User,
+			meta.Cluster,
+			meta.OcmEnvironment,
+			len(commands),
+			exitCode,
+		)
+	}
+	w.Flush()
+}
+
+func sessionsReplay(cmd *cobra.Command, args []string) {
+	dir := getSessionRecordingDir()
+	if _, err := pkgIntSession.ReadMeta(dir, args[0]); err != nil {
+		log.Fatalf("Session %s not found in %s", args[0], dir)
+	}
+
+	err := pkgIntSession.Replay(
+		pkgIntSession.GetCastFile(dir, args[0]),
+		os.Stdout,
+		sessionsCmdArgs.speed,
+		sessionsCmdArgs.idleLimit,
+	)
+	if err != nil {
+		log.Fatalf("Failed to replay session %s: %v", args[0], err)
+	}
+}
+
+func sessionsExport(cmd *cobra.Command, args []string) {
+	id := args[0]
+	dir := getSessionRecordingDir()
+	if _, err := pkgIntSession.ReadMeta(dir, id); err != nil {
+		log.Fatalf("Session %s not found in %s", id, dir)
+	}
+
+	output := sessionsCmdArgs.exportFile
+	var export func(*os.File) error
+	switch sessionsCmdArgs.format {
+	case "tar.gz":
+		if len(output) == 0 {
+			output = id + ".tar.gz"
+		}
+		export = func(file *os.File) error {
+			return pkgIntSession.Export(dir, id, file)
+		}
+	case "jsonl":
+		if len(output) == 0 {
+			output = id + ".jsonl"
+		}
+		export = func(file *os.File) error {
+			content, err := os.ReadFile(pkgIntSession.GetCommandLogFile(dir, id))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			_, err = file.Write(content)
+			return err
+		}
+	default:
+		log.Fatalf("Unsupported export format: %s", sessionsCmdArgs.format)
+	}
+
+	if output == "-" {
+		if err := export(os.Stdout); err != nil {
+			log.Fatalf("Failed to export session %s: %v", id, err)
+		}
+		return
+	}
+
+	file, err := os.OpenFile(output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Fatalf("Failed to create %s: %v", output, err)
+	}
+	if err := export(file); err != nil {
+		file.Close()
+		os.Remove(output)
+		log.Fatalf("Failed to export session %s: %v", id, err)
+	}
+	if err := file.Close(); err != nil {
+		log.Fatalf("Failed to write %s: %v", output, err)
+	}
+	fmt.Fprintf(os.Stderr, "Exported session %s to %s\n", id, output)
+}
+
+func sessionsRecord(cmd *cobra.Command, args []string) {
+	if err := checkContainerCommand(); err != nil {
+		log.Fatal(err)
+	}
+
+	dir := getEnvVar("SESSION_RECORDING_DIR")
+	if len(dir) == 0 {
+		log.Fatal("SESSION_RECORDING_DIR must be set by hc login")
+	}
+
+	// A restarted workspace container records a new session
+	startedAt := time.Now().UTC()
+	meta := &pkgIntSession.Meta{
+		ID:             fmt.Sprintf("%s-%s", getEnvVar("SESSION_NAME"), startedAt.Format("20060102T150405Z")),
+		User:           getEnvVar("HOST_USER"),
+		Cluster:        getEnvVar("OCM_CLUSTER"),
+		OcmEnvironment: getEnvVar("OCM_ENVIRONMENT"),
+		StartedAt:      startedAt,
+	}
+	if err := pkgIntSession.Create(dir, meta); err != nil {
+		log.Fatalf("Failed to create session %s: %v", meta.ID, err)
+	}
+	// Used by the shell to log commands to the session
+	os.Setenv("SESSION_ID", meta.ID)
+
+	exitCode, err := pkgIntSession.Record(
+		cmd.Context(),
+		pkgIntSession.GetCastFile(dir, meta.ID),
+		fmt.Sprintf("%s %s %s", meta.User, meta.OcmEnvironment, meta.Cluster),
+		args[0],
+		args[1:]...,
+	)
+	if err != nil {
+		log.Errorf("Failed to record session %s: %v", meta.ID, err)
+	}
+
+	endedAt := time.Now().UTC()
+	meta.EndedAt = &endedAt
+	meta.ExitCode = &exitCode
+	if err := pkgIntSession.WriteMeta(dir, meta); err != nil {
+		log.Errorf("Failed to update session %s: %v", meta.ID, err)
+	}
+	pkgIntHelper.Exit(exitCode)
+}
+
+func sessionsLogCommand(cmd *cobra.Command, args []string) {
+	command := strings.TrimSpace(args[0])
+	if len(command) == 0 {
+		return
+	}
+	appendSessionEntry(&pkgIntSession.CommandEntry{
+		Command:  command,
+		ExitCode: sessionsCmdArgs.exitCode,
+	})
+}
+
+// Logs an entry to the recorded session, if the session is recorded. The
+// session, user, cluster and namespace are filled in.
+func appendSessionEntry(entry *pkgIntSession.CommandEntry) {
+	dir := getEnvVar("SESSION_RECORDING_DIR")
+	id := getEnvVar("SESSION_ID")
+	if len(dir) == 0 || len(id) == 0 {
+		return
+	}
+
+	entry.Timestamp = time.Now().UTC()
+	entry.Session = id
+	entry.User = getEnvVar("HOST_USER")
+	entry.Cluster, _ = pkgIntHelper.OcGetCurrentOcmCluster()
+	entry.Namespace, _ = pkgIntHelper.OcGetCurrentNamespace("")
+	if err := pkgIntSession.AppendCommand(dir, entry); err != nil {
+		log.Errorf("Failed to log to session %s: %v", id, err)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(sessionsCmd)
+	sessionsCmd.AddCommand(
+		sessionsListCmd,
+		sessionsReplayCmd,
+		sessionsExportCmd,
+		sessionsRecordCmd,
+		sessionsLogCommandCmd,
+	)
+
+	sessionsListCmd.Flags().StringVarP(
+		&sessionsCmdArgs.output,
+		"output",
+		"o",
+		"text",
+		"Output format (text, json)",
+	)
+
+	sessionsReplayCmd.Flags().Float64VarP(
+		&sessionsCmdArgs.speed,
+		"speed",
+		"s",
+		1,
+		"Playback speed",
+	)
+	sessionsReplayCmd.Flags().DurationVarP(
+		&sessionsCmdArgs.idleLimit,
+		"idle-limit",
+		"i",
+		2*time.Second,
+		"Maximum pause between outputs, 0 for none",
+	)
+
+	sessionsExportCmd.Flags().StringVarP(
+		&sessionsCmdArgs.exportFile,
+		"output",
+		"o",
+		"",
+		"Output file, - for stdout (default is <id>.tar.gz or <id>.jsonl)",
+	)
+	sessionsExportCmd.Flags().StringVarP(
+		&sessionsCmdArgs.format,
+		"format",
+		"f",
+		"tar.gz",
+		"Export format (tar.gz, jsonl)",
+	)
+
+	sessionsLogCommandCmd.Flags().IntVar(
+		&sessionsCmdArgs.exitCode,
+		"exit-code",
+		0,
+		"Exit code of the command",
+	)
+}
@@ -0,0 +1,334 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	pkgInt "hc/internal"
+	pkgIntHelper "hc/internal/helpers"
+	pkgIntSession "hc/internal/session"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Path where backplane config is mounted in the container
+	containerBackplaneConfigPath = "/backplane-config.json"
+	// Path where hc config is mounted in the container
+	hcConfigPath = "/.hc.yaml"
+	// Path where the user's shell rc snippet is mounted in the container
+	containerShellRcPath = "/hc-shell-rc"
+	// Time a workspace container left running by hc has to stop before it
+	// is killed
+	containerStopTimeout = 10 * time.Second
+)
+
+// Options of a workspace container.
+type workspaceOptions struct {
+	cluster                string
+	search                 string
+	ocmEnvironment         string
+	isOcmLoginOnly         bool
+	hardened               bool
+	extraContainerPortMaps string
+	// Whether the workspace runs an interactive terminal
+	interactive bool
+}
+
+// A workspace container ready to be run.
+type workspace struct {
+	ce     pkgInt.ContainerEngine
+	config *pkgInt.HcConfig
+	image  string
+	// Container name
+	name string
+	// Cluster id, empty if the workspace logs in to OCM only
+	ocmCluster string
+	// Cluster name, for display
+	clusterName    string
+	ocmEnvironment string
+	consolePort    string
+	// Passed to the container, masked in logged commands
+	ocmToken string
+	// Run in reverse order when the workspace is closed, or when hc exits
+	// before
+	cleanups []func()
+}
+
+// Prepares a workspace container: validates the backplane config and the
+// cluster, gets an OCM token and gathers the container's environment,
+// volumes and ports.
+func prepareWorkspace(ctx context.Context, opts *workspaceOptions) *workspace {
+	w := &workspace{
+		ocmEnvironment: "production",
+		ocmCluster:     opts.cluster,
+		clusterName:    opts.cluster,
+	}
+	if len(opts.ocmEnvironment) > 0 {
+		w.ocmEnvironment = opts.ocmEnvironment
+	}
+
+	ce := newContainerEngine()
+	ce.SetTTY(opts.interactive)
+	w.ce = ce
+
+	config := getHcConfig()
+	w.config = config
+	ocmLongLivedTokenPath := config.OcmLongLivedTokenPath
+	var ocmToken string
+
+	// Validate the backplane config before the container starts, backplane
+	// login fails deep inside the container otherwise
+	backplaneConfigFile := config.GetBackplaneConfigFile(w.ocmEnvironment)
+	if _, err := pkgInt.ValidateBackplaneConfigFile(backplaneConfigFile, true); err != nil {
+		log.Error("Invalid backplane config: ", err)
+		log.Fatalf(
+			"Create it with \"hc backplane config init --env %s\" or check it with \"hc backplane config validate --env %s\"",
+			w.ocmEnvironment,
+			w.ocmEnvironment,
+		)
+	}
+
+	// Validate the cluster before the container starts
+	if !opts.isOcmLoginOnly {
+		ocmCli := newOcmCli(w.ocmEnvironment, config.OcmCliAlias)
+		cluster, err := resolveOcmCluster(ctx, ocmCli, opts.cluster, opts.search)
+		if err != nil {
+			log.Fatal("Failed to resolve the cluster: ", err)
+		}
+		log.Infof(
+			"Cluster %s (%s): state %s, version %s, region %s, product %s",
+			cluster.Name,
+			cluster.ID,
+			cluster.State,
+			cluster.Version,
+			cluster.Region,
+			cluster.Product,
+		)
+		if cluster.State != "ready" {
+			log.Warnf("Cluster %s is %s, backplane login may fail", cluster.Name, cluster.State)
+		}
+		// Backplane logs in by id, names are not unique
+		w.ocmCluster = cluster.ID
+		w.clusterName = cluster.Name
+	}
+
+	if len(ocmLongLivedTokenPath) > 0 {
+		content, err := os.ReadFile(ocmLongLivedTokenPath)
+		if err != nil {
+			log.Fatalf("Failed to open long lived token file: %v", err)
+		}
+		ocmToken = strings.TrimSpace(string(content))
+	} else {
+		ocmCliAlias := config.OcmCliAlias
+		var err error
+		ocmToken, err = pkgIntHelper.OcmGetOCMToken(
+			ctx,
+			opts.ocmEnvironment,
+			ocmCliAlias.OcmProduction,
+			ocmCliAlias.OcmStaging,
+		)
+		if err != nil {
+			log.Fatalf("%s:\n%v", pkgInt.ErrOCMTokenFetchMsg, err)
+		}
+	}
+
+	// Allocate free port and map host port for OpenShift console
+	ports, err := pkgIntHelper.GetFreePorts(1)
+	if err != nil {
+		log.Fatal("Failed to generate port for Openshift console: ", err)
+	}
+	w.consolePort = strconv.Itoa(ports[0])
+
+	suffix := uuid.New()
+	w.name = fmt.Sprintf("hc-%s", suffix.String()[:6])
+	if len(w.clusterName) > 0 {
+		w.name = fmt.Sprintf("hc-%s-%s", w.clusterName, suffix.String()[:6])
+	}
+
+	// Gather values for the container's environment variables
+	ce.AppendEnvVar("HOST_USER", config.HostUser)
+	ce.AppendEnvVar("HOST_UID", strconv.Itoa(os.Getuid()))
+	ce.AppendEnvVar("HOST_GID", strconv.Itoa(os.Getgid()))
+	ce.AppendEnvVar("OC_USER", config.OcUser)
+	ce.AppendEnvVar("OCM_CLUSTER", w.ocmCluster)
+	ce.AppendEnvVar("IS_OCM_LOGIN_ONLY", strconv.FormatBool(opts.isOcmLoginOnly))
+	w.ocmToken = ocmToken
+	ce.AppendEnvVar("OCM_TOKEN", ocmToken)
+	ce.AppendEnvVar("IS_IN_CONTAINER", "true")
+	ce.AppendEnvVar("OCM_ENVIRONMENT", w.ocmEnvironment)
+	ce.AppendEnvVar("BACKPLANE_CONFIG", containerBackplaneConfigPath)
+	ce.AppendEnvVar("OPENSHIFT_CONSOLE_PORT", w.consolePort)
+
+	// Rootless podman maps the host user to root in the container by
+	// default. Keep the host uid instead so that the workspace user created
+	// with it owns files in host-mounted directories.
+	rootless := isRootless(ctx, ce)
+	if config.Hardened || opts.hardened {
+		if !rootless {
+			log.Fatal("Hardened mode requires rootless podman")
+		}
+		// The container runs as the host user, so the bootstrap must not need root
+		ce.SetHardened(true)
+		ce.SetUserNs("keep-id")
+		ce.AppendTmpfs("/tmp", "rw,mode=1777")
+		ce.AppendTmpfs(
+			config.UserHome,
+			fmt.Sprintf("rw,exec,mode=0700,uid=%d,gid=%d", os.Getuid(), os.Getgid()),
+		)
+		ce.AppendEnvVar("HOME", config.UserHome)
+		ce.AppendEnvVar("IS_HARDENED", "true")
+	} else if rootless {
+		ce.SetUserNs("keep-id")
+		ce.SetUser("root")
+	}
+
+	// Gather values for the container's host-mounted volumes
+	ce.AppendVolMap(backplaneConfigFile, containerBackplaneConfigPath, "ro")
+
+	// Mount the effective config so that the container sees the merged config layers
+	effectiveConfigPath, err := writeEffectiveConfig()
+	if err != nil {
+		log.Fatal("Failed to write the effective config: ", err)
+	}
+	w.addCleanup("remove the effective config", func() { os.Remove(effectiveConfigPath) })
+	ce.AppendVolMap(effectiveConfigPath, hcConfigPath, "ro")
+
+	// Mount the user's rc snippet, sourced by the workspace shell
+	if len(config.ShellRcFile) > 0 {
+		ce.AppendVolMap(config.ShellRcFile, containerShellRcPath, "ro")
+		ce.AppendEnvVar("SHELL_RC_FILE", containerShellRcPath)
+	}
+
+	for _, dirMap := range config.CustomDirMaps {
+		ce.AppendVolMap(dirMap.HostDir, dirMap.ContainerDir, dirMap.FileAttrs)
+	}
+
+	// Gather values for the containers host-mapped TCP ports
+	// Openshift console port
+	ce.AppendPortMap(w.consolePort, w.consolePort, "127.0.0.1")
+
+	// Append extra ports if there's any
+	extraContainerPorts := strings.Fields(opts.extraContainerPortMaps)
+	extraHostPorts, err := pkgIntHelper.GetFreePorts(len(extraContainerPorts))
+	if err != nil {
+		log.Fatal("Failed to generate and map extra container ports: ", err)
+	}
+	for idx, containerPort := range extraContainerPorts {
+		port := strconv.Itoa(extraHostPorts[idx])
+		ce.AppendPortMap(port, containerPort, "127.0.0.1")
+	}
+
+	// Record the session to a host directory. Only terminal sessions are
+	// recorded.
+	if config.SessionRecording.Enabled && opts.interactive {
+		sessionRecordingDir := config.GetSessionRecordingDir()
+		if err := os.MkdirAll(sessionRecordingDir, 0700); err != nil {
+			log.Fatalf("Failed to create session recording directory %s: %v", sessionRecordingDir, err)
+		}
+		ce.AppendVolMap(sessionRecordingDir, pkgIntSession.ContainerDir, "rw")
+		ce.AppendEnvVar("SESSION_RECORDING_DIR", pkgIntSession.ContainerDir)
+		ce.AppendEnvVar("SESSION_NAME", w.name)
+		log.Infof("Recording the session to %s", sessionRecordingDir)
+	}
+
+	// Keep the OCM config, backplane sessions and kubeconfig across workspaces
+	// of the same environment. The state is locked so that two workspaces
+	// never write it at the same time.
+	if config.PersistentState.Enabled {
+		stateDir := config.GetPersistentStateDir(w.ocmEnvironment)
+		stateLock, err := pkgInt.LockState(stateDir, fmt.Sprintf("%s (pid %d)", w.name, os.Getpid()))
+		var lockedErr *pkgInt.StateLockedError
+		switch {
+		case errors.As(err, &lockedErr):
+			log.Warnf("Not persisting the workspace state: %v", err)
+		case err != nil:
+			log.Fatalf("Failed to lock the persistent state %s: %v", stateDir, err)
+		default:
+			w.addCleanup("release the persistent state", func() { stateLock.Release() })
+			ce.AppendVolMap(stateDir, pkgInt.StateContainerDir, "rw")
+			ce.AppendEnvVar("PERSISTENT_STATE_DIR", pkgInt.StateContainerDir)
+			log.Infof("Persisting the workspace state to %s", stateDir)
+		}
+	}
+
+	w.image, err = resolveHcImage(ctx, ce, config)
+	if err != nil {
+		log.Fatal("Failed to resolve hc image: ", err)
+	}
+	return w
+}
+
+func (w *workspace) addCleanup(name string, cleanup func()) {
+	w.cleanups = append(w.cleanups, pkgIntHelper.AddCleanup(name, cleanup))
+}
+
+// Gets the command that runs the workspace container in the terminal with
+// clusterLogin as the entry point.
+func (w *workspace) getRunCommand(clusterLoginArgs ...string) *pkgIntHelper.Command {
+	entryPointArgs := []string{"clusterLogin", w.ocmCluster, "--config", hcConfigPath}
+	if pkgInt.Debug {
+		entryPointArgs = append(entryPointArgs, "-d")
+	}
+	// Last, since they may end with a command after "--"
+	entryPointArgs = append(entryPointArgs, clusterLoginArgs...)
+	command := pkgIntHelper.NewCommand(w.ce.GetExecName(), w.ce.GetRunCmd(w.name, "./hc", w.image, entryPointArgs...)...)
+	command.Interactive = true
+	command.Secrets = []string{w.ocmToken}
+	log.Debugf("Container run command: %s", command)
+	return command
+}
+
+// Registers the workspace container for hc console and completions.
+func (w *workspace) register() {
+	err := pkgInt.RegisterWorkspaceContainer(&pkgInt.WorkspaceContainer{
+		Name:           w.name,
+		Cluster:        w.clusterName,
+		OcmEnvironment: w.ocmEnvironment,
+		ConsolePort:    w.consolePort,
+		StartedAt:      time.Now().UTC(),
+		Pid:            os.Getpid(),
+	})
+	if err != nil {
+		log.Debugf("Failed to register the container: %v", err)
+	}
+	w.addCleanup("unregister the container", func() { pkgInt.UnregisterWorkspaceContainer(w.name) })
+}
+
+// Releases what the workspace holds on the host.
+func (w *workspace) close() {
+	for idx := len(w.cleanups) - 1; idx >= 0; idx-- {
+		w.cleanups[idx]()
+	}
+	w.cleanups = nil
+}
+
+// Writes the effective config to a temporary file readable only by the user.
+func writeEffectiveConfig() (string, error) {
+	doc, err := pkgInt.GetEffectiveConfigDocument(false)
+	if err != nil {
+		return "", err
+	}
+	content, err := pkgInt.EncodeConfigDocument(doc)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.CreateTemp("", "hc-config-*.yaml")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(content); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
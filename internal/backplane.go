@@ -0,0 +1,263 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	// Proxies that do not accept a connection within this period are unreachable
+	backplaneProxyDialTimeout = 5 * time.Second
+
+	defaultBackplaneSessionDir = "backplane"
+)
+
+// Backplane API URLs per OCM environment.
+var backplaneURLs = map[string]string{
+	"production": "https://api.backplane.openshift.com",
+	"staging":    "https://api.stage.backplane.openshift.com",
+}
+
+// Default backplane config file names per OCM environment.
+var backplaneConfigFileNames = map[string]string{
+	"production": "config.prod.json",
+	"staging":    "config.stage.json",
+}
+
+// Config of the backplane CLI, see ocm backplane config.
+type BackplaneConfig struct {
+	URL string `json:"url"`
+	// Proxies the backplane API is reached through, backplane accepts one or
+	// a list
+	ProxyURLs  []string `json:"-"`
+	SessionDir string   `json:"session-dir,omitempty"`
+}
+
+func (c *BackplaneConfig) MarshalJSON() ([]byte, error) {
+	config := map[string]interface{}{
+		"url": c.URL,
+	}
+	switch len(c.ProxyURLs) {
+	case 0:
+	case 1:
+		config["proxy-url"] = c.ProxyURLs[0]
+	default:
+		config["proxy-url"] = c.ProxyURLs
+	}
+	if len(c.SessionDir) > 0 {
+		config["session-dir"] = c.SessionDir
+	}
+	return json.Marshal(config)
+}
+
+func (c *BackplaneConfig) UnmarshalJSON(content []byte) error {
+	var config struct {
+		URL        string          `json:"url"`
+		ProxyURL   json.RawMessage `json:"proxy-url"`
+		SessionDir string          `json:"session-dir"`
+	}
+	if err := json.Unmarshal(content, &config); err != nil {
+		return err
+	}
+	c.URL = config.URL
+	c.SessionDir = config.SessionDir
+	c.ProxyURLs = nil
+
+	if len(config.ProxyURL) == 0 || string(config.ProxyURL) == "null" {
+		return nil
+	}
+	var proxyURL string
+	if err := json.Unmarshal(config.ProxyURL, &proxyURL); err == nil {
+		if len(proxyURL) > 0 {
+			c.ProxyURLs = []string{proxyURL}
+		}
+		return nil
+	}
+	if err := json.Unmarshal(config.ProxyURL, &c.ProxyURLs); err != nil {
+		return errors.New("proxy-url must be a URL or a list of URLs")
+	}
+	return nil
+}
+
+// Gets the default backplane config of an OCM environment.
+func NewBackplaneConfig(environment string) (*BackplaneConfig, error) {
+	backplaneURL, found := backplaneURLs[environment]
+	if !found {
+		return nil, fmt.Errorf("unknown OCM environment: %s", environment)
+	}
+	return &BackplaneConfig{
+		URL:        backplaneURL,
+		SessionDir: defaultBackplaneSessionDir,
+	}, nil
+}
+
+// Gets the backplane config file of an OCM environment. Environments other
+// than production use the staging config.
+func (c *HcConfig) GetBackplaneConfigFile(environment string) string {
+	name := c.BackplaneConfigProd
+	if len(name) == 0 {
+		name = backplaneConfigFileNames["production"]
+	}
+	if environment != "production" {
+		name = c.BackplaneConfigStage
+		if len(name) == 0 {
+			name = backplaneConfigFileNames["staging"]
+		}
+	}
+	return filepath.Join(c.UserHome, ".config", "backplane", name)
+}
+
+// Checks the fields of a backplane config.
+func (c *BackplaneConfig) Validate() error {
+	if len(c.URL) == 0 {
+		return errors.New("url is not set")
+	}
+	if err := checkURL(c.URL, "https"); err != nil {
+		return fmt.Errorf("url: %w", err)
+	}
+	for _, proxyURL := range c.ProxyURLs {
+		if err := checkURL(proxyURL, "http", "https"); err != nil {
+			return fmt.Errorf("proxy-url: %w", err)
+		}
+	}
+	return nil
+}
+
+func checkURL(value string, schemes ...string) error {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	if len(parsed.Host) == 0 {
+		return fmt.Errorf("%q has no host", value)
+	}
+	for _, scheme := range schemes {
+		if parsed.Scheme == scheme {
+			return nil
+		}
+	}
+	return fmt.Errorf("%q must use %v", value, schemes)
+}
+
+// Checks that one of the proxies accepts connections. Backplane uses the
+// first reachable proxy.
+func (c *BackplaneConfig) CheckProxies() error {
+	if len(c.ProxyURLs) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, proxyURL := range c.ProxyURLs {
+		parsed, err := url.Parse(proxyURL)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		address := parsed.Host
+		if len(parsed.Port()) == 0 {
+			port := "80"
+			if parsed.Scheme == "https" {
+				port = "443"
+			}
+			address = net.JoinHostPort(parsed.Hostname(), port)
+		}
+		conn, err := net.DialTimeout("tcp", address, backplaneProxyDialTimeout)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("proxy %s is unreachable: %w", proxyURL, err))
+			continue
+		}
+		conn.Close()
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+func LoadBackplaneConfig(file string) (*BackplaneConfig, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var config BackplaneConfig
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("invalid JSON in %s: %w", file, err)
+	}
+	return &config, nil
+}
+
+// Keys of the backplane CLI config.
+var backplaneConfigKeys = []string{
+	"url",
+	"proxy-url",
+	"proxy-check-endpoint",
+	"vpn-check-endpoint",
+	"session-dir",
+	"assume-initial-arn",
+	"prod-env-name",
+	"pd-key",
+	"jira-base-url",
+	"jira-token",
+	"jira-config-for-access-requests",
+	"display-cluster-info",
+	"govcloud",
+}
+
+// Checks that a backplane config file has no keys unknown to hc, e.g.
+// misspelt ones that backplane would ignore. Returns a warning per unknown
+// key, since backplane may know of keys that hc does not.
+func CheckBackplaneConfigKeys(file string) ([]string, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(content, &values); err != nil {
+		return nil, fmt.Errorf("invalid JSON in %s: %w", file, err)
+	}
+
+	warnings := []string{}
+	for name := range values {
+		known := false
+		best, bestDist := "", 4
+		for _, key := range backplaneConfigKeys {
+			known = known || name == key
+			if dist := levenshtein(name, key); dist < bestDist {
+				best, bestDist = key, dist
+			}
+		}
+		if known {
+			continue
+		}
+		if len(best) > 0 {
+			warnings = append(warnings, fmt.Sprintf("%s: unknown key %q, did you mean %q?", file, name, best))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("%s: unknown key %q", file, name))
+		}
+	}
+	sort.Strings(warnings)
+	return warnings, nil
+}
+
+// Loads and validates a backplane config file, optionally checking that its
+// proxy is reachable.
+func ValidateBackplaneConfigFile(file string, checkProxies bool) (*BackplaneConfig, error) {
+	config, err := LoadBackplaneConfig(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if checkProxies {
+		if err := config.CheckProxies(); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return config, nil
+}
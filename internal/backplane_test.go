@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckBackplaneConfigKeys(t *testing.T) {
+	tests := []struct {
+		content  string
+		warnings []string
+		// Part of the error, empty if the config can be read
+		err string
+	}{
+		{content: `{"url": "https://api.backplane.example.com", "proxy-url": "http://proxy.example.com:3128"}`},
+		{content: `{"url": "https://api.backplane.example.com", "proxy-url": ["http://a:3128", "http://b:3128"], "session-dir": "backplane", "pd-key": "key", "govcloud": false}`},
+		{content: `{"url": "https://api.backplane.example.com", "proxy-url": "http://proxy.example.com:3128", "proxy-check-endpoint": "https://example.com", "vpn-check-endpoint": "https://vpn.example.com"}`},
+		{
+			content:  `{"url": "https://api.backplane.example.com", "proxy_url": "http://proxy.example.com:3128", "unrelated": true}`,
+			warnings: []string{`unknown key "proxy_url", did you mean "proxy-url"?`, `unknown key "unrelated"`},
+		},
+		{content: `{"url": "https://api.backplane.example.com",}`, err: "invalid character"},
+	}
+	for _, test := range tests {
+		file := filepath.Join(t.TempDir(), "config.json")
+		if err := os.WriteFile(file, []byte(test.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		warnings, err := CheckBackplaneConfigKeys(file)
+		if len(test.err) == 0 && err != nil {
+			t.Errorf("%s: %v", test.content, err)
+		}
+		if len(test.err) > 0 && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%s: error %v, expected %q", test.content, err, test.err)
+		}
+		expected := []string{}
+		for _, warning := range test.warnings {
+			expected = append(expected, file+": "+warning)
+		}
+		if err == nil && !reflect.DeepEqual(warnings, expected) {
+			t.Errorf("%s: warnings %q, expected %q", test.content, warnings, expected)
+		}
+	}
+}
+
+// Backplane accepts one proxy or a list of proxies.
+func TestBackplaneConfigProxyURLs(t *testing.T) {
+	for content, expected := range map[string][]string{
+		`{"url": "https://api.backplane.example.com"}`:                                                  nil,
+		`{"url": "https://api.backplane.example.com", "proxy-url": "http://a:3128"}`:                    {"http://a:3128"},
+		`{"url": "https://api.backplane.example.com", "proxy-url": ["http://a:3128", "http://b:3128"]}`: {"http://a:3128", "http://b:3128"},
+	} {
+		file := filepath.Join(t.TempDir(), "config.json")
+		if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		config, err := ValidateBackplaneConfigFile(file, false)
+		if err != nil {
+			t.Fatalf("%s: %v", content, err)
+		}
+		if !reflect.DeepEqual(config.ProxyURLs, expected) {
+			t.Errorf("%s: proxies %v, expected %v", content, config.ProxyURLs, expected)
+		}
+	}
+}
+
+func TestGetConfigOriginDefault(t *testing.T) {
+	if origin := GetConfigOrigin("backplaneConfigStage"); origin != ConfigOriginDefault {
+		t.Errorf("origin %q of an unset key, expected %q", origin, ConfigOriginDefault)
+	}
+	t.Setenv(GetConfigEnvVar("backplaneConfigStage"), "config.stage.json")
+	if origin := GetConfigOrigin("backplaneConfigStage"); origin == ConfigOriginDefault {
+		t.Error("the origin of a key set in the environment is the default")
+	}
+}
@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	pkgIntHelper "hc/internal/helpers"
+)
+
+// A cached value with the time it was cached.
+type cacheEntry struct {
+	UpdatedAt time.Time       `json:"updatedAt"`
+	Value     json.RawMessage `json:"value"`
+}
+
+// Gets the hc cache directory, ~/.cache/hc.
+func GetCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "hc"), nil
+}
+
+// Gets the file of a cache entry. Names may contain slashes to group entries.
+func GetCacheFile(name string) (string, error) {
+	dir, err := GetCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.FromSlash(name)+".json"), nil
+}
+
+// Reads a cached value into v. Returns false if there is no cached value or
+// if it is older than maxAge, unless maxAge is zero.
+func ReadCache(name string, maxAge time.Duration, v interface{}) bool {
+	file, err := GetCacheFile(name)
+	if err != nil {
+		return false
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return false
+	}
+	if maxAge > 0 && time.Since(entry.UpdatedAt) > maxAge {
+		return false
+	}
+	return json.Unmarshal(entry.Value, v) == nil
+}
+
+func WriteCache(name string, v interface{}) error {
+	file, err := GetCacheFile(name)
+	if err != nil {
+		return err
+	}
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(&cacheEntry{UpdatedAt: time.Now().UTC(), Value: value})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	return pkgIntHelper.WriteFileAtomic(file, content, 0600)
+}
@@ -3,6 +3,8 @@ package internal
 import (
 	"errors"
 	"fmt"
+	"strconv"
+	"time"
 )
 
 type ceFactory struct {
@@ -15,7 +17,7 @@ func NewCeFactory(args map[string]interface{}) *ceFactory {
 	}
 }
 
-type containerEngine interface {
+type ContainerEngine interface {
 	// Append run arg - environment variables
 	AppendEnvVar(key string, value string)
 	// Append build arg - container build arg
@@ -24,16 +26,53 @@ type containerEngine interface {
 	AppendVolMap(hostVol string, containerVol string, mapAttrs string)
 	// Append run arg - host/container port/address
 	AppendPortMap(hostPort string, containerPort string, hostAddr string)
+	// Append build arg - additional image tag
+	AppendImageTag(image string)
+	// Set run arg - user namespace mode (e.g. keep-id)
+	SetUserNs(mode string)
+	// Set run arg - user the entry point runs as
+	SetUser(user string)
+	// Set run args - drop privileges, capabilities and the writable root filesystem
+	SetHardened(hardened bool)
+	// Set run arg - whether the container gets a terminal and stdin, true by default
+	SetTTY(tty bool)
+	// Set run arg - whether the container is removed when it exits
+	SetAutoRemove(autoRemove bool)
+	// Append run arg - tmpfs mount
+	AppendTmpfs(containerDir string, mountOpts string)
+	// Sets whether registry TLS certificates are verified on pull/push
+	SetTLSVerify(verify bool)
 	// Constructs and returns a build image command
 	GetBuildCmd() []string
+	// Constructs and returns a pull image command
+	GetPullCmd(image string) []string
+	// Constructs and returns a push image command
+	GetPushCmd(image string) []string
+	// Constructs and returns a tag image command
+	GetTagCmd(srcImage string, dstImage string) []string
+	// Constructs and returns a command that exits with 0 if an image exists locally
+	GetImageExistsCmd(image string) []string
+	// Constructs and returns a command that prints an image's creation time
+	GetImageCreatedCmd(image string) []string
+	// Constructs and returns a command that prints an image's labels as json
+	GetImageLabelsCmd(image string) []string
+	// Constructs and returns a command that prints whether the ce runs rootless
+	GetRootlessInfoCmd() []string
 	// Constructs and returns a run container command
 	GetRunCmd(containerName string, entryPoint string, image string, entryPointArgs ...string) []string
+	// Constructs and returns a command that runs a command in a running container as a user
+	GetExecCmd(containerName string, user string, tty bool, command ...string) []string
+	// Constructs and returns a command that force-removes a container if it exists
+	GetRemoveCmd(containerName string) []string
+	// Constructs and returns a command that stops a container if it runs,
+	// killing it after the timeout
+	GetStopCmd(containerName string, timeout time.Duration) []string
 	// Returns ce executable name (e.g. podman)
 	GetExecName() string
 	// Todo: Add func here as necessary
 }
 
-func (cf *ceFactory) Create() (containerEngine, error) {
+func (cf *ceFactory) Create() (ContainerEngine, error) {
 	if cf.args["ceName"] == "podman" {
 		return NewPodman(), nil
 	} else {
@@ -48,10 +87,21 @@ type podman struct {
 	portMaps     [][]string
 	portMapAddrs map[string]string
 	buildArgs    [][]string
+	imageTags    []string
+	tlsVerify    bool
+	userNs       string
+	user         string
+	hardened     bool
+	tmpfsMounts  [][]string
+	tty          bool
+	autoRemove   bool
 }
 
 func NewPodman() *podman {
-	return &podman{}
+	return &podman{
+		tlsVerify: true,
+		tty:       true,
+	}
 }
 
 func (p *podman) AppendEnvVar(key string, value string) {
@@ -133,15 +183,88 @@ func (p *podman) ToBuildArgs() []string {
 	return args
 }
 
+func (p *podman) AppendImageTag(image string) {
+	p.imageTags = append(p.imageTags, image)
+}
+
+func (p *podman) ToImageTagArgs() []string {
+	args := []string{"-t", "hc:latest"}
+	for _, image := range p.imageTags {
+		args = append(args, "-t", image)
+	}
+	return args
+}
+
+func (p *podman) SetTLSVerify(verify bool) {
+	p.tlsVerify = verify
+}
+
+func (p *podman) ToTLSVerifyArg() string {
+	return fmt.Sprintf("--tls-verify=%t", p.tlsVerify)
+}
+
+func (p *podman) SetUserNs(mode string) {
+	p.userNs = mode
+}
+
+func (p *podman) SetUser(user string) {
+	p.user = user
+}
+
+func (p *podman) ToUserArgs() []string {
+	args := []string{}
+	if len(p.userNs) > 0 {
+		args = append(args, fmt.Sprintf("--userns=%s", p.userNs))
+	}
+	if len(p.user) > 0 {
+		args = append(args, "--user", p.user)
+	}
+	return args
+}
+
+func (p *podman) SetHardened(hardened bool) {
+	p.hardened = hardened
+}
+
+func (p *podman) AppendTmpfs(containerDir string, mountOpts string) {
+	p.tmpfsMounts = append(p.tmpfsMounts, []string{containerDir, mountOpts})
+}
+
+func (p *podman) ToTmpfsArgs() []string {
+	args := []string{}
+	for _, val := range p.tmpfsMounts {
+		args = append(args, "--tmpfs", fmt.Sprintf("%s:%s", val[0], val[1]))
+	}
+	return args
+}
+
+func (p *podman) ToPrivilegeArgs() []string {
+	if !p.hardened {
+		return []string{"--privileged"}
+	}
+	return []string{
+		"--read-only",
+		"--cap-drop=all",
+		"--security-opt=no-new-privileges",
+	}
+}
+
 func (p *podman) GetRunCmd(containerName string, entryPoint string, image string, entryPointArgs ...string) []string {
 
 	runCmd := []string{
 		"run",
 		"--name",
 		containerName,
-		"-it",
-		"--privileged",
 	}
+	if p.tty {
+		runCmd = append(runCmd, "-it")
+	}
+	if p.autoRemove {
+		runCmd = append(runCmd, "--rm")
+	}
+	runCmd = append(runCmd, p.ToPrivilegeArgs()...)
+	runCmd = append(runCmd, p.ToUserArgs()...)
+	runCmd = append(runCmd, p.ToTmpfsArgs()...)
 	runCmd = append(runCmd, p.ToEnvVarArgs()...)
 	runCmd = append(runCmd, p.ToPortMapArgs()...)
 	runCmd = append(runCmd, p.ToVolMapArgs()...)
@@ -155,6 +278,34 @@ func (p *podman) GetRunCmd(containerName string, entryPoint string, image string
 	return runCmd
 }
 
+func (p *podman) SetTTY(tty bool) {
+	p.tty = tty
+}
+
+func (p *podman) SetAutoRemove(autoRemove bool) {
+	p.autoRemove = autoRemove
+}
+
+func (p *podman) GetExecCmd(containerName string, user string, tty bool, command ...string) []string {
+	execCmd := []string{"exec"}
+	if tty {
+		execCmd = append(execCmd, "-it")
+	}
+	if len(user) > 0 {
+		execCmd = append(execCmd, "--user", user)
+	}
+	execCmd = append(execCmd, containerName)
+	return append(execCmd, command...)
+}
+
+func (p *podman) GetRemoveCmd(containerName string) []string {
+	return []string{"rm", "--force", "--ignore", containerName}
+}
+
+func (p *podman) GetStopCmd(containerName string, timeout time.Duration) []string {
+	return []string{"stop", "--ignore", "--time", strconv.Itoa(int(timeout.Seconds())), containerName}
+}
+
 func (p *podman) GetExecName() string {
 	return "podman"
 }
@@ -172,12 +323,69 @@ func (p *podman) GetPortMaps() [][]string {
 }
 
 func (p *podman) GetBuildCmd() []string {
-	buildCmd := []string{
-		"build",
-		"-t",
-		"hc:latest",
-	}
+	buildCmd := []string{"build"}
+	buildCmd = append(buildCmd, p.ToImageTagArgs()...)
 	buildCmd = append(buildCmd, p.ToBuildArgs()...)
 	buildCmd = append(buildCmd, ".")
 	return buildCmd
 }
+
+func (p *podman) GetPullCmd(image string) []string {
+	return []string{
+		"pull",
+		p.ToTLSVerifyArg(),
+		image,
+	}
+}
+
+func (p *podman) GetPushCmd(image string) []string {
+	return []string{
+		"push",
+		p.ToTLSVerifyArg(),
+		image,
+	}
+}
+
+func (p *podman) GetTagCmd(srcImage string, dstImage string) []string {
+	return []string{
+		"tag",
+		srcImage,
+		dstImage,
+	}
+}
+
+func (p *podman) GetImageExistsCmd(image string) []string {
+	return []string{
+		"image",
+		"exists",
+		image,
+	}
+}
+
+func (p *podman) GetImageCreatedCmd(image string) []string {
+	return []string{
+		"image",
+		"inspect",
+		"--format",
+		"{{.Created}}",
+		image,
+	}
+}
+
+func (p *podman) GetImageLabelsCmd(image string) []string {
+	return []string{
+		"image",
+		"inspect",
+		"--format",
+		"{{json .Labels}}",
+		image,
+	}
+}
+
+func (p *podman) GetRootlessInfoCmd() []string {
+	return []string{
+		"info",
+		"--format",
+		"{{.Host.Security.Rootless}}",
+	}
+}
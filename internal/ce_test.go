@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+// Compares the arguments of a command, one per line, to a golden file in
+// testdata, or writes the golden file with -update.
+func checkGoldenArgs(t *testing.T, name string, args []string) {
+	t.Helper()
+	golden := filepath.Join("testdata", name+".golden")
+	actual := strings.Join(args, "\n") + "\n"
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(golden, []byte(actual), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v, run the tests with -update to create it", err)
+	}
+	if actual != string(expected) {
+		t.Errorf("%s differs from %s:\n%s", name, golden, actual)
+	}
+}
+
+// Sets up an engine as hc login does, rootless.
+func newWorkspaceEngine(ce ContainerEngine, hardened bool) {
+	ce.AppendEnvVar("HOST_USER", "me")
+	ce.AppendEnvVar("HOST_UID", "1000")
+	ce.AppendEnvVar("HOST_GID", "1000")
+	ce.AppendEnvVar("OC_USER", "me")
+	ce.AppendEnvVar("OCM_CLUSTER", "1a2b3c")
+	ce.AppendEnvVar("IS_OCM_LOGIN_ONLY", "false")
+	ce.AppendEnvVar("IS_IN_CONTAINER", "true")
+	ce.AppendEnvVar("OCM_ENVIRONMENT", "production")
+	ce.AppendEnvVar("OPENSHIFT_CONSOLE_PORT", "9000")
+	if hardened {
+		ce.SetHardened(true)
+		ce.SetUserNs("keep-id")
+		ce.AppendTmpfs("/tmp", "rw,mode=1777")
+		ce.AppendTmpfs("/home/me", "rw,exec,mode=0700,uid=1000,gid=1000")
+		ce.AppendEnvVar("HOME", "/home/me")
+		ce.AppendEnvVar("IS_HARDENED", "true")
+	} else {
+		ce.SetUserNs("keep-id")
+		ce.SetUser("root")
+	}
+	ce.AppendVolMap("/home/me/.config/backplane/config.prod.json", "/backplane-config.json", "ro")
+	ce.AppendVolMap("/tmp/hc-config.yaml", "/.hc.yaml", "ro")
+	ce.AppendVolMap("/home/me/work", "/work", "rw")
+	ce.AppendPortMap("9000", "9000", "127.0.0.1")
+	ce.AppendPortMap("9001", "8080", "127.0.0.1")
+}
+
+func TestPodmanRunCmd(t *testing.T) {
+	tests := []struct {
+		name     string
+		hardened bool
+		tty      bool
+		args     []string
+	}{
+		{name: "podman-run", tty: true},
+		{name: "podman-run-hardened", hardened: true, tty: true},
+		{name: "podman-run-script", tty: false, args: []string{"--script", "/hc-run-script"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ce := NewPodman()
+			newWorkspaceEngine(ce, test.hardened)
+			ce.SetTTY(test.tty)
+			ce.SetAutoRemove(!test.tty)
+			entryPointArgs := append([]string{"clusterLogin", "1a2b3c", "--config", "/.hc.yaml"}, test.args...)
+			checkGoldenArgs(t, test.name, ce.GetRunCmd("hc-my-cluster-abc123", "./hc", "hc:37-ocm0.1.70-bp0.1.20", entryPointArgs...))
+		})
+	}
+}
+
+func TestPodmanBuildCmd(t *testing.T) {
+	ce := NewPodman()
+	ce.AppendBuildArg("BASE_IMAGE_VERSION", "37")
+	ce.AppendBuildArg("OCM_CLI_VERSION", "0.1.70")
+	ce.AppendBuildArg("BACKPLANE_CLI_VERSION", "0.1.20")
+	ce.AppendBuildArg("BUILD_SHA", "0123456789abcdef")
+	ce.AppendImageTag("hc:37-ocm0.1.70-bp0.1.20")
+	checkGoldenArgs(t, "podman-build", ce.GetBuildCmd())
+}
+
+func TestPodmanExecCmd(t *testing.T) {
+	tests := []struct {
+		name    string
+		tty     bool
+		user    string
+		command []string
+	}{
+		{name: "podman-exec", tty: true, user: "me", command: []string{"oc", "get", "deployment", "console", "-n", "openshift-console", "-o", "json"}},
+		{name: "podman-exec-notty", user: "me", command: []string{"cat", "/home/me/.kube/config"}},
+		{name: "podman-exec-nouser", command: []string{"id"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ce := NewPodman()
+			checkGoldenArgs(t, test.name, ce.GetExecCmd("hc-my-cluster-abc123", test.user, test.tty, test.command...))
+		})
+	}
+}
+
+// The fake engine builds the same commands as podman and records the calls
+// made to it.
+func TestFakeEngine(t *testing.T) {
+	fake := NewFakeEngine()
+	newWorkspaceEngine(fake, true)
+	fake.SetTTY(true)
+	checkGoldenArgs(t, "podman-run-hardened", fake.GetRunCmd(
+		"hc-my-cluster-abc123",
+		"./hc",
+		"hc:37-ocm0.1.70-bp0.1.20",
+		"clusterLogin", "1a2b3c", "--config", "/.hc.yaml",
+	))
+	checkGoldenArgs(t, "fake-engine-calls", fake.Calls)
+}
@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A container engine that builds podman commands and records the calls made
+// to it. Used with a RecordingRunner, commands can be run without podman.
+type FakeEngine struct {
+	*podman
+	// Calls that set up the container, e.g. "AppendEnvVar HOST_USER=me"
+	Calls []string
+}
+
+func NewFakeEngine() *FakeEngine {
+	return &FakeEngine{podman: NewPodman()}
+}
+
+func (f *FakeEngine) record(method string, args ...string) {
+	f.Calls = append(f.Calls, strings.TrimSpace(fmt.Sprintf("%s %s", method, strings.Join(args, " "))))
+}
+
+func (f *FakeEngine) AppendEnvVar(key string, value string) {
+	f.record("AppendEnvVar", key+"="+value)
+	f.podman.AppendEnvVar(key, value)
+}
+
+func (f *FakeEngine) AppendBuildArg(name string, value string) {
+	f.record("AppendBuildArg", name+"="+value)
+	f.podman.AppendBuildArg(name, value)
+}
+
+func (f *FakeEngine) AppendVolMap(hostVol string, containerVol string, mapAttrs string) {
+	f.record("AppendVolMap", hostVol, containerVol, mapAttrs)
+	f.podman.AppendVolMap(hostVol, containerVol, mapAttrs)
+}
+
+func (f *FakeEngine) AppendPortMap(hostPort string, containerPort string, hostAddr string) {
+	f.record("AppendPortMap", hostPort, containerPort, hostAddr)
+	f.podman.AppendPortMap(hostPort, containerPort, hostAddr)
+}
+
+func (f *FakeEngine) AppendImageTag(image string) {
+	f.record("AppendImageTag", image)
+	f.podman.AppendImageTag(image)
+}
+
+func (f *FakeEngine) SetUserNs(mode string) {
+	f.record("SetUserNs", mode)
+	f.podman.SetUserNs(mode)
+}
+
+func (f *FakeEngine) SetUser(user string) {
+	f.record("SetUser", user)
+	f.podman.SetUser(user)
+}
+
+func (f *FakeEngine) SetHardened(hardened bool) {
+	f.record("SetHardened", fmt.Sprint(hardened))
+	f.podman.SetHardened(hardened)
+}
+
+func (f *FakeEngine) SetTTY(tty bool) {
+	f.record("SetTTY", fmt.Sprint(tty))
+	f.podman.SetTTY(tty)
+}
+
+func (f *FakeEngine) SetAutoRemove(autoRemove bool) {
+	f.record("SetAutoRemove", fmt.Sprint(autoRemove))
+	f.podman.SetAutoRemove(autoRemove)
+}
+
+func (f *FakeEngine) AppendTmpfs(containerDir string, mountOpts string) {
+	f.record("AppendTmpfs", containerDir, mountOpts)
+	f.podman.AppendTmpfs(containerDir, mountOpts)
+}
+
+func (f *FakeEngine) SetTLSVerify(verify bool) {
+	f.record("SetTLSVerify", fmt.Sprint(verify))
+	f.podman.SetTLSVerify(verify)
+}
@@ -2,8 +2,9 @@ package internal
 
 import (
 	"fmt"
-	"log"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -24,51 +25,82 @@ type OcmCliAlias struct {
 	OcmStaging    string `mapstructure:"staging"`
 }
 
+type ImageConfig struct {
+	Registry   string `mapstructure:"registry"`
+	Repository string `mapstructure:"repository"`
+	TLSVerify  bool   `mapstructure:"tlsVerify"`
+}
+
+type SessionRecordingConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Dir     string `mapstructure:"dir"`
+}
+
+type PersistentStateConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Dir     string `mapstructure:"dir"`
+}
+
+type ElevationConfig struct {
+	TTL string `mapstructure:"ttl"`
+}
+
+type PromptConfig struct {
+	Template string `mapstructure:"template"`
+	Colors   bool   `mapstructure:"colors"`
+}
+
 type HcConfig struct {
-	CustomDirMaps         []DirMap    `mapstructure:"customDirMaps"`
-	AddToPATHEnv          []string    `mapstructure:"addToPATHEnv"`
-	ExportEnvVars         []string    `mapstructure:"exportEnvVars"`
-	HostUser              string      `mapstructure:"hostUser"`
-	OcUser                string      `mapstructure:"ocUser"`
-	UserHome              string      `mapstructure:"userHome"`
-	BackplaneConfigProd   string      `mapstructure:"backplaneConfigProd"`
-	BackplaneConfigStage  string      `mapstructure:"backplaneConfigStage"`
-	BaseImageVersion      string      `mapstructure:"baseImageVersion"`
-	OCMCLIVersion         string      `mapstructure:"ocmCLIVersion"`
-	BackplaneCLIVersion   string      `mapstructure:"backplaneCLIVersion"`
-	CustomPortMaps        []PortMap   `mapstructure:"customPortMaps"`
-	OcmLongLivedTokenPath string      `mapstructure:"ocmLongLivedTokenPath"`
-	OcmCliAlias           OcmCliAlias `mapstructure:"ocmCLIAlias"`
-}
-
-func GetHcConfig() *HcConfig {
+	CustomDirMaps         []DirMap               `mapstructure:"customDirMaps"`
+	AddToPATHEnv          []string               `mapstructure:"addToPATHEnv"`
+	ExportEnvVars         []string               `mapstructure:"exportEnvVars"`
+	HostUser              string                 `mapstructure:"hostUser"`
+	OcUser                string                 `mapstructure:"ocUser"`
+	UserHome              string                 `mapstructure:"userHome"`
+	BackplaneConfigProd   string                 `mapstructure:"backplaneConfigProd"`
+	BackplaneConfigStage  string                 `mapstructure:"backplaneConfigStage"`
+	BaseImageVersion      string                 `mapstructure:"baseImageVersion"`
+	OCMCLIVersion         string                 `mapstructure:"ocmCLIVersion"`
+	BackplaneCLIVersion   string                 `mapstructure:"backplaneCLIVersion"`
+	CustomPortMaps        []PortMap              `mapstructure:"customPortMaps"`
+	OcmLongLivedTokenPath string                 `mapstructure:"ocmLongLivedTokenPath"`
+	OcmCliAlias           OcmCliAlias            `mapstructure:"ocmCLIAlias"`
+	Image                 ImageConfig            `mapstructure:"image"`
+	Hardened              bool                   `mapstructure:"hardened"`
+	Shell                 string                 `mapstructure:"shell"`
+	ShellRcFile           string                 `mapstructure:"shellRcFile"`
+	Prompt                PromptConfig           `mapstructure:"prompt"`
+	SessionRecording      SessionRecordingConfig `mapstructure:"sessionRecording"`
+	Elevation             ElevationConfig        `mapstructure:"elevation"`
+	PersistentState       PersistentStateConfig  `mapstructure:"persistentState"`
+}
+
+const (
+	defaultBaseImageVersion = "37"
+	defaultImageRepository  = "hc"
+	defaultShell            = "bash"
+	defaultElevationTTL     = 20 * time.Minute
+	// Relative to the user home
+	defaultSessionRecordingDir = ".local/share/hc/sessions"
+	// Relative to the user home
+	defaultPersistentStateDir = ".local/share/hc/state"
+)
+
+// Sets the default values of optional config keys.
+func SetConfigDefaults() {
+	viper.SetDefault("baseImageVersion", defaultBaseImageVersion)
+	viper.SetDefault("image.repository", defaultImageRepository)
+	viper.SetDefault("image.tlsVerify", true)
+	viper.SetDefault("shell", defaultShell)
+}
+
+func GetHcConfig() (*HcConfig, error) {
 	var conf HcConfig
 	err := viper.Unmarshal(&conf)
 	if err != nil {
-		log.Fatal("Failed to unmarshal config: ", err)
-	}
-	return &conf
-}
-
-func ValidateConfig() error {
-	conf := GetHcConfig()
-	requiredStrs := map[string]string{
-		"hostUser":             conf.HostUser,
-		"ocUser":               conf.OcUser,
-		"userHome":             conf.UserHome,
-		"backplaneConfigProd":  conf.BackplaneConfigProd,
-		"backplaneConfigStage": conf.BackplaneConfigStage,
-		"ocmCLIVersion":        conf.OCMCLIVersion,
-		"backplaneCLIVersion":  conf.BackplaneCLIVersion,
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
-
-	for name, value := range requiredStrs {
-		noSpaces := strings.TrimSpace(value)
-		if len(noSpaces) == 0 {
-			return fmt.Errorf("missing required config: \"%s\"", name)
-		}
-	}
-	return nil
+	return &conf, nil
 }
 
 func (c *HcConfig) GetAddToPATHEnv() []string {
@@ -104,7 +136,107 @@ func (c *HcConfig) GetBackplaneConfigStage() string {
 }
 
 func (c *HcConfig) GetBaseImage() string {
-	return fmt.Sprintf("fedora:%s", c.BaseImageVersion)
+	return fmt.Sprintf("fedora:%s", c.GetBaseImageVersion())
+}
+
+func (c *HcConfig) GetBaseImageVersion() string {
+	if len(c.BaseImageVersion) == 0 {
+		return defaultBaseImageVersion
+	}
+	return c.BaseImageVersion
+}
+
+// Gets the hc image tag derived from the configured tool versions and the
+// hc build, whose binary the image runs, so that images built from the same
+// versions are interchangeable.
+func (c *HcConfig) GetImageTag() string {
+	tag := fmt.Sprintf(
+		"%s-ocm%s-bp%s-hc%s",
+		c.GetBaseImageVersion(),
+		c.OCMCLIVersion,
+		c.BackplaneCLIVersion,
+		Version,
+	)
+	if sha := GetBuildSHA(); len(sha) > 0 {
+		tag += "-" + shortenBuildSHA(sha)
+	}
+	return tag
+}
+
+// Shortens a commit id to 12 characters, keeping a "-dirty" suffix.
+func shortenBuildSHA(sha string) string {
+	revision, dirty := strings.CutSuffix(sha, "-dirty")
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if dirty {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// Gets the labels that the hc image of the config is built with, which tell
+// the versions and the hc build it contains.
+func (c *HcConfig) GetImageLabels() map[string]string {
+	return map[string]string{
+		"hc.version":               Version,
+		"hc.build-sha":             GetBuildSHA(),
+		"hc.base-image-version":    c.GetBaseImageVersion(),
+		"hc.ocm-cli-version":       c.OCMCLIVersion,
+		"hc.backplane-cli-version": c.BackplaneCLIVersion,
+	}
+}
+
+// Gets the name of the locally built hc image.
+func (c *HcConfig) GetLocalImage() string {
+	return fmt.Sprintf("hc:%s", c.GetImageTag())
+}
+
+// Gets the name of the prebuilt hc image in the configured registry or an
+// empty string if no registry is configured.
+func (c *HcConfig) GetRemoteImage() string {
+	return c.GetRemoteImageWithTag(c.GetImageTag())
+}
+
+func (c *HcConfig) GetRemoteImageWithTag(tag string) string {
+	registry := strings.TrimRight(strings.TrimSpace(c.Image.Registry), "/")
+	if len(registry) == 0 {
+		return ""
+	}
+	repository := strings.Trim(strings.TrimSpace(c.Image.Repository), "/")
+	if len(repository) == 0 {
+		repository = defaultImageRepository
+	}
+	return fmt.Sprintf("%s/%s:%s", registry, repository, tag)
+}
+
+// Gets the host directory where workspace sessions are recorded.
+func (c *HcConfig) GetSessionRecordingDir() string {
+	if len(c.SessionRecording.Dir) > 0 {
+		return c.SessionRecording.Dir
+	}
+	return filepath.Join(c.UserHome, defaultSessionRecordingDir)
+}
+
+// Gets the persistent state directory of an OCM environment.
+func (c *HcConfig) GetPersistentStateDir(environment string) string {
+	dir := c.PersistentState.Dir
+	if len(dir) == 0 {
+		dir = filepath.Join(c.UserHome, defaultPersistentStateDir)
+	}
+	return filepath.Join(dir, environment)
+}
+
+// Gets how long hc elevate keeps a context elevated.
+func (c *HcConfig) GetElevationTTL() (time.Duration, error) {
+	if len(c.Elevation.TTL) == 0 {
+		return defaultElevationTTL, nil
+	}
+	ttl, err := time.ParseDuration(c.Elevation.TTL)
+	if err != nil {
+		return 0, fmt.Errorf("invalid elevation.ttl: %w", err)
+	}
+	return ttl, nil
 }
 
 func (c *HcConfig) GetOcmCLIVersion() string {
@@ -0,0 +1,24 @@
+package internal
+
+import "testing"
+
+func TestGetImageTag(t *testing.T) {
+	config := &HcConfig{BaseImageVersion: "38", OCMCLIVersion: "0.1.70", BackplaneCLIVersion: "0.1.20"}
+	// Test binaries are not built with vcs information
+	if tag := config.GetImageTag(); tag != "38-ocm0.1.70-bp0.1.20-hc"+Version {
+		t.Errorf("tag %s, expected 38-ocm0.1.70-bp0.1.20-hc%s", tag, Version)
+	}
+	if labels := config.GetImageLabels(); labels["hc.version"] != Version || labels["hc.ocm-cli-version"] != "0.1.70" {
+		t.Errorf("unexpected labels %v", labels)
+	}
+
+	for sha, expected := range map[string]string{
+		"0123456789abcdef0123456789abcdef01234567":       "0123456789ab",
+		"0123456789abcdef0123456789abcdef01234567-dirty": "0123456789ab-dirty",
+		"0123456": "0123456",
+	} {
+		if short := shortenBuildSHA(sha); short != expected {
+			t.Errorf("shortened %s to %s, expected %s", sha, short, expected)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	pkgIntHelper "hc/internal/helpers"
+
+	"gopkg.in/yaml.v3"
+)
+
+const maskedSecret = "******"
+
+var secretKeyRegex = regexp.MustCompile(`(?i)(token|secret|passw(or)?d|credential|api_?key|private_?key)`)
+
+// Checks whether a config key or environment variable name holds a secret.
+// Keys holding paths to secrets, e.g. ocmLongLivedTokenPath, are not secrets.
+func IsSecretConfigKey(name string) bool {
+	return secretKeyRegex.MatchString(name) && !strings.HasSuffix(strings.ToLower(name), "path")
+}
+
+// Loads a config file as a yaml document preserving comments and key order.
+// A missing file is loaded as an empty document.
+func LoadConfigDocument(file string) (*yaml.Node, error) {
+	doc := &yaml.Node{
+		Kind:    yaml.DocumentNode,
+		Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}},
+	}
+
+	content, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return doc, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed yaml.Node
+	if err := yaml.Unmarshal(content, &parsed); err != nil {
+		return nil, err
+	}
+	if len(parsed.Content) == 0 {
+		return doc, nil
+	}
+	if parsed.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: expected a mapping at the top level", file)
+	}
+	return &parsed, nil
+}
+
+func EncodeConfigDocument(doc *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Writes a config document to a file. The file is replaced atomically so
+// that a failed write never leaves a truncated config behind.
+func WriteConfigDocument(file string, doc *yaml.Node) error {
+	content, err := EncodeConfigDocument(doc)
+	if err != nil {
+		return err
+	}
+	return pkgIntHelper.WriteFileAtomic(file, content, 0600)
+}
+
+// Finds the value node of a dotted key path in a mapping node. Keys are
+// matched case-insensitively, as viper does.
+func findConfigNode(mapping *yaml.Node, keyPath string) *yaml.Node {
+	node := mapping
+	for _, name := range strings.Split(keyPath, ".") {
+		if node == nil || node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for idx := 0; idx+1 < len(node.Content); idx += 2 {
+			if strings.EqualFold(node.Content[idx].Value, name) {
+				next = node.Content[idx+1]
+			}
+		}
+		node = next
+	}
+	return node
+}
+
+// Looks up the schema of a dotted config key path.
+func lookupConfigKey(keyPath string) (*configKey, error) {
+	schema := &configSchema
+	names := strings.Split(keyPath, ".")
+	for idx, name := range names {
+		if schema.Type != schemaObject {
+			return nil, fmt.Errorf("unknown config key %q", keyPath)
+		}
+		child := schema.findKey(name)
+		if child == nil {
+			if suggestion := schema.suggestKey(name); len(suggestion) > 0 {
+				suggestion = strings.Join(append(names[:idx:idx], suggestion), ".")
+				return nil, fmt.Errorf("unknown config key %q, did you mean %q?", keyPath, suggestion)
+			}
+			return nil, fmt.Errorf("unknown config key %q", keyPath)
+		}
+		schema = child
+	}
+	return schema, nil
+}
+
+// Checks whether a dotted key path is a known config key.
+func ValidateConfigKey(keyPath string) error {
+	_, err := lookupConfigKey(keyPath)
+	return err
+}
+
+// Parses a command-line value into a yaml node of the key's schema type.
+// Lists and mappings are given in yaml syntax, e.g. "[a, b]".
+func newConfigValueNode(schema *configKey, value string) (*yaml.Node, error) {
+	switch schema.Type {
+	case schemaString:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}, nil
+	case schemaBoolean:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("expected a boolean, got %q", value)
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(b)}, nil
+	}
+
+	var parsed yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &parsed); err != nil || len(parsed.Content) == 0 {
+		return nil, fmt.Errorf("expected a yaml %s, got %q", schema.Type, value)
+	}
+	node := parsed.Content[0]
+	if schema.Type == schemaArray && node.Kind != yaml.SequenceNode ||
+		schema.Type == schemaObject && node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("expected a yaml %s, got %q", schema.Type, value)
+	}
+	// Re-encode in block style to match the rest of the file
+	resetConfigNodeStyle(node)
+	return node, nil
+}
+
+// Resets the style of a node and of the nodes it contains, e.g. to write flow
+// style yaml in block style.
+func resetConfigNodeStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetConfigNodeStyle(child)
+	}
+}
+
+// Sets the value of a dotted config key path, creating parent keys as needed.
+func SetConfigValue(doc *yaml.Node, keyPath string, value string) error {
+	schema, err := lookupConfigKey(keyPath)
+	if err != nil {
+		return err
+	}
+	valueNode, err := newConfigValueNode(schema, value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", keyPath, err)
+	}
+
+	node := doc.Content[0]
+	names := strings.Split(keyPath, ".")
+	for idx, name := range names {
+		pos := -1
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if strings.EqualFold(node.Content[i].Value, name) {
+				pos = i + 1
+			}
+		}
+
+		child := valueNode
+		if idx < len(names)-1 {
+			if pos >= 0 && node.Content[pos].Kind == yaml.MappingNode {
+				node = node.Content[pos]
+				continue
+			}
+			child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+
+		if pos >= 0 {
+			child.HeadComment = node.Content[pos].HeadComment
+			child.LineComment = node.Content[pos].LineComment
+			node.Content[pos] = child
+		} else {
+			// Use the key's canonical name from the schema
+			key, _ := lookupConfigKey(strings.Join(names[:idx+1], "."))
+			node.Content = append(
+				node.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key.Name},
+				child,
+			)
+		}
+		node = child
+	}
+	return nil
+}
+
+// Masks secret values in a config document, including secret NAME=value
+// entries in lists such as exportEnvVars.
+func MaskConfigSecrets(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			MaskConfigSecrets(child)
+		}
+	case yaml.MappingNode:
+		for idx := 0; idx+1 < len(node.Content); idx += 2 {
+			value := node.Content[idx+1]
+			if value.Kind == yaml.ScalarNode && IsSecretConfigKey(node.Content[idx].Value) {
+				value.Value = maskedSecret
+				value.Tag = "!!str"
+				value.Style = 0
+				continue
+			}
+			MaskConfigSecrets(value)
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			if item.Kind == yaml.ScalarNode {
+				name, _, found := strings.Cut(item.Value, "=")
+				if found && IsSecretConfigKey(name) {
+					item.Value = fmt.Sprintf("%s=%s", name, maskedSecret)
+				}
+				continue
+			}
+			MaskConfigSecrets(item)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// Values given in flow style are written in block style, like the rest of
+// the file.
+func TestSetConfigValueBlockStyle(t *testing.T) {
+	doc, err := LoadConfigDocument(writeConfigFile(t, "hostUser: me\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SetConfigValue(doc, "customDirMaps", "[{hostDir: /tmp, containerDir: /mnt/tmp, fileAttrs: ro}]"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetConfigValue(doc, "customPortMaps", `[{hostPort: 8080, containerPort: "80"}]`); err != nil {
+		t.Fatal(err)
+	}
+	out, err := EncodeConfigDocument(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "hostUser: me\ncustomDirMaps:\n  - hostDir: /tmp\n    containerDir: /mnt/tmp\n    fileAttrs: ro\ncustomPortMaps:\n  - hostPort: 8080\n    containerPort: \"80\"\n"
+	if string(out) != expected {
+		t.Errorf("config:\n%s\nexpected:\n%s", out, expected)
+	}
+}
+
+func TestGetConfigValueNode(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	chdir(t, t.TempDir())
+	file := writeConfigFile(t, "customDirMaps:\n  - fileAttrs: ro\n    hostDir: /tmp\n    containerDir: /mnt/tmp\nprompt:\n  colors: true\n")
+	if err := LoadConfigLayers(file, true); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, expected := range map[string]string{
+		"customDirMaps": "- hostDir: /tmp\n  containerDir: /mnt/tmp\n  fileAttrs: ro\n",
+		"prompt":        "colors: true\n",
+	} {
+		node, err := GetConfigValueNode(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := EncodeConfigDocument(node)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != expected {
+			t.Errorf("%s:\n%s\nexpected:\n%s", key, out, expected)
+		}
+	}
+	if _, err := GetConfigValueNode("customDirMap"); err == nil {
+		t.Error("got the value of an unknown key")
+	}
+}
@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Running workspace containers are kept in the cache under this name
+const workspaceContainersCacheName = "containers"
+
+// A workspace container started by hc login.
+type WorkspaceContainer struct {
+	Name           string    `json:"name"`
+	Cluster        string    `json:"cluster"`
+	OcmEnvironment string    `json:"ocmEnvironment"`
+	ConsolePort    string    `json:"consolePort"`
+	StartedAt      time.Time `json:"startedAt"`
+	// Pid of the hc login process that runs the container
+	Pid int `json:"pid"`
+}
+
+func getWorkspaceContainerCacheName(name string) string {
+	return workspaceContainersCacheName + "/" + name
+}
+
+// Checks whether the hc login process of a container is still running.
+func (c *WorkspaceContainer) isRunning() bool {
+	if c.Pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(c.Pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// Records a workspace container until it is unregistered, so that it can be
+// listed without asking the container engine.
+func RegisterWorkspaceContainer(container *WorkspaceContainer) error {
+	return WriteCache(getWorkspaceContainerCacheName(container.Name), container)
+}
+
+func UnregisterWorkspaceContainer(name string) error {
+	file, err := GetCacheFile(getWorkspaceContainerCacheName(name))
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Lists the registered workspace containers whose hc login process is still
+// running, most recent first.
+func ListWorkspaceContainers() []WorkspaceContainer {
+	containers := []WorkspaceContainer{}
+	dir, err := GetCacheDir()
+	if err != nil {
+		return containers
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, workspaceContainersCacheName))
+	if err != nil {
+		return containers
+	}
+
+	for _, entry := range entries {
+		name, found := strings.CutSuffix(entry.Name(), ".json")
+		if !found {
+			continue
+		}
+		var container WorkspaceContainer
+		if !ReadCache(getWorkspaceContainerCacheName(name), 0, &container) {
+			continue
+		}
+		if !container.isRunning() {
+			// Left behind by a login that did not exit cleanly
+			UnregisterWorkspaceContainer(name)
+			continue
+		}
+		containers = append(containers, container)
+	}
+	sort.Slice(containers, func(i, j int) bool {
+		return containers[i].StartedAt.After(containers[j].StartedAt)
+	})
+	return containers
+}
@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+const (
+	// User that backplane impersonates in elevated contexts
+	ElevatedUser = "backplane-cluster-admin"
+
+	elevationCacheName = "elevation"
+)
+
+// An elevation of a kubeconfig context with hc elevate.
+type Elevation struct {
+	Context   string    `json:"context"`
+	User      string    `json:"user"`
+	Reason    string    `json:"reason"`
+	StartedAt time.Time `json:"startedAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Gets the current elevation, if any.
+func GetElevation() (*Elevation, bool) {
+	var elevation Elevation
+	if !ReadCache(elevationCacheName, 0, &elevation) {
+		return nil, false
+	}
+	return &elevation, true
+}
+
+func SaveElevation(elevation *Elevation) error {
+	return WriteCache(elevationCacheName, elevation)
+}
+
+func ClearElevation() error {
+	file, err := GetCacheFile(elevationCacheName)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Statuses of a cluster in a fleet run.
+const (
+	FleetStatusSucceeded = "succeeded"
+	// The command exited with a non-zero exit code
+	FleetStatusFailed  = "failed"
+	FleetStatusTimeout = "timeout"
+	// The workspace could not be run, e.g. the cluster was not found
+	FleetStatusError = "error"
+	// The run was interrupted before the cluster's turn
+	FleetStatusSkipped = "skipped"
+)
+
+// Result of a fleet run on a cluster.
+type FleetResult struct {
+	Cluster string `json:"cluster"`
+	ID      string `json:"id,omitempty"`
+	Status  string `json:"status"`
+	// -1 unless the command exited
+	ExitCode  int        `json:"exitCode"`
+	StartedAt *time.Time `json:"startedAt,omitempty"`
+	// Rounded to tenths of a second
+	DurationSeconds float64 `json:"durationSeconds"`
+	// Output of the workspace
+	LogFile      string `json:"logFile,omitempty"`
+	ArtifactsDir string `json:"artifactsDir,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// Report of a fleet run.
+type FleetReport struct {
+	Command         []string      `json:"command"`
+	StartedAt       time.Time     `json:"startedAt"`
+	DurationSeconds float64       `json:"durationSeconds"`
+	Results         []FleetResult `json:"results"`
+}
+
+// Gets a duration in seconds, rounded to tenths of a second.
+func GetDurationSeconds(duration time.Duration) float64 {
+	return duration.Round(100 * time.Millisecond).Seconds()
+}
+
+// Counts the results by status.
+func (r *FleetReport) CountStatuses() map[string]int {
+	counts := map[string]int{}
+	for _, result := range r.Results {
+		counts[result.Status]++
+	}
+	return counts
+}
+
+var fleetReportCsvHeader = []string{
+	"cluster",
+	"id",
+	"status",
+	"exitCode",
+	"startedAt",
+	"durationSeconds",
+	"logFile",
+	"artifactsDir",
+	"error",
+}
+
+// Writes a fleet report as json or as csv with a row per cluster.
+func WriteFleetReport(w io.Writer, report *FleetReport, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	case "csv":
+		writer := csv.NewWriter(w)
+		writer.Write(fleetReportCsvHeader)
+		for _, result := range report.Results {
+			startedAt := ""
+			if result.StartedAt != nil {
+				startedAt = result.StartedAt.Format(time.RFC3339)
+			}
+			writer.Write([]string{
+				result.Cluster,
+				result.ID,
+				result.Status,
+				strconv.Itoa(result.ExitCode),
+				startedAt,
+				strconv.FormatFloat(result.DurationSeconds, 'f', 1, 64),
+				result.LogFile,
+				result.ArtifactsDir,
+				result.Error,
+			})
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+	return fmt.Errorf("unknown report format: %s", format)
+}
+
+// Reads a list of clusters, one name, id or external id per line. Empty lines
+// and lines starting with # are ignored.
+func ReadClusterList(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	clusters := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		clusters = append(clusters, line)
+	}
+	return clusters, scanner.Err()
+}
@@ -0,0 +1,38 @@
+package internal
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestWriteFleetReport(t *testing.T) {
+	startedAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	report := &FleetReport{
+		Command:         []string{"oc", "get", "co"},
+		StartedAt:       startedAt,
+		DurationSeconds: 61.3,
+		Results: []FleetResult{
+			{Cluster: "cluster-1", ID: "c1", Status: FleetStatusSucceeded, StartedAt: &startedAt, DurationSeconds: 12.5, LogFile: "/out/cluster-1/output.log", ArtifactsDir: "/out/cluster-1/artifacts"},
+			{Cluster: "cluster-2", ID: "c2", Status: FleetStatusFailed, ExitCode: 2, StartedAt: &startedAt, DurationSeconds: 3, LogFile: "/out/cluster-2/output.log", ArtifactsDir: "/out/cluster-2/artifacts"},
+			{Cluster: "cluster-3", ID: "c3", Status: FleetStatusTimeout, ExitCode: -1, StartedAt: &startedAt, DurationSeconds: 60, Error: "timed out after 1m0s"},
+			{Cluster: "unknown", Status: FleetStatusError, ExitCode: -1, Error: "cluster unknown not found, \"unknown\""},
+			{Cluster: "cluster-4", ID: "c4", Status: FleetStatusSkipped, ExitCode: -1},
+		},
+	}
+	for _, format := range []string{"json", "csv"} {
+		var out bytes.Buffer
+		if err := WriteFleetReport(&out, report, format); err != nil {
+			t.Fatal(err)
+		}
+		checkGoldenArgs(t, "fleet-report-"+format, []string{string(bytes.TrimSuffix(out.Bytes(), []byte("\n")))})
+	}
+	if err := WriteFleetReport(&bytes.Buffer{}, report, "yaml"); err == nil {
+		t.Error("wrote a report in an unknown format")
+	}
+
+	counts := report.CountStatuses()
+	if counts[FleetStatusSucceeded] != 1 || counts[FleetStatusSkipped] != 1 || len(counts) != 5 {
+		t.Errorf("unexpected counts %v", counts)
+	}
+}
@@ -1,106 +1,85 @@
 package internal
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
 	"os"
-	"os/exec"
+	"os/user"
+	"path/filepath"
 	"strings"
-)
-
-type ocClusterUrls struct {
-	Server   string `json:"server"`
-	ProxyUrl string `json:"proxy-url"`
-}
-
-type ocCluster struct {
-	Name        string        `json:"name"`
-	ClusterUrls ocClusterUrls `json:"cluster"`
-}
-
-type ocContext struct {
-	Name    string            `json:"name"`
-	Context map[string]string `json:"context"`
-}
+	"time"
 
-type OcConfig struct {
-	Contexts       []ocContext `json:"contexts"`
-	CurrentContext string      `json:"current-context"`
-	Clusters       []ocCluster `json:"clusters"`
-}
+	pkgIntOcm "hc/internal/ocm"
+)
 
-// Gets the current OpenShift cluster that a user is logged in.
+// Gets the cluster that the current kubeconfig context is logged in to
+// through backplane, by the name it was added to the workspace with. Falls
+// back to the cluster that hc login logged in to.
 func OcGetCurrentOcmCluster() (string, error) {
+	kubeconfig, err := LoadKubeconfig()
+	if err == nil {
+		if cluster, err := kubeconfig.GetCurrentCluster(); err == nil {
+			if id := GetBackplaneClusterID(cluster.Cluster.Server); len(id) > 0 {
+				return GetWorkspaceClusterName(id), nil
+			}
+		}
+	}
 	ocmCluster := strings.TrimSpace(os.Getenv("OCM_CLUSTER"))
 	return ocmCluster, nil
 }
 
-// Gets the current OpenShift namespace.
+// Gets the current OpenShift namespace of a user, by default the current user.
 func OcGetCurrentNamespace(runAsOcUser string) (string, error) {
-	config, err := OcGetConfig(runAsOcUser)
+	kubeconfig, err := LoadUserKubeconfig(runAsOcUser)
 	if err != nil {
 		return "", err
 	}
-
-	currentContext := config.CurrentContext
-
-	for _, context := range config.Contexts {
-		if context.Name == currentContext {
-			return context.Context["namespace"], nil
-		}
-	}
-
-	err = fmt.Errorf("current context not found: %s", currentContext)
-	return "", err
+	return kubeconfig.GetCurrentNamespace()
 }
 
-func OcGetConfig(runAsOcUser string) (*OcConfig, error) {
-	commandName := "oc"
-	var commandArgs []string
-
-	if len(runAsOcUser) > 0 {
-		commandName = "sudo"
-		commandArgs = []string{"-Eu", runAsOcUser, "oc", "config", "view", "-o", "json"}
-	} else {
-		commandArgs = []string{"config", "view", "-o", "json"}
-	}
-
-	bytes, err := exec.Command(
-		commandName,
-		commandArgs...).Output()
+func isCurrentUser(name string) bool {
+	current, err := user.Current()
+	return err == nil && current.Username == name
+}
 
-	if err != nil {
-		return nil, err
+// Loads the kubeconfig of a user. The kubeconfig of the current user honours
+// $KUBECONFIG, that of another user is read from ~/.kube/config in their home.
+func LoadUserKubeconfig(name string) (*Kubeconfig, error) {
+	if len(name) == 0 || isCurrentUser(name) {
+		return LoadKubeconfig()
 	}
-
-	var config OcConfig
-	err = json.Unmarshal(bytes, &config)
+	ocUser, err := user.Lookup(name)
 	if err != nil {
 		return nil, err
 	}
-	return &config, nil
+	return LoadKubeconfig(filepath.Join(ocUser.HomeDir, ".kube", "config"))
 }
 
 func OcmGetOCMToken(
+	ctx context.Context,
 	ocmEnv string,
 	ocmCliProd string,
 	ocmCliStage string,
 ) (string, error) {
-	var ocmToken string
-	var err error
-	var out []byte
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	var alias string
 	if ocmEnv == "production" && len(ocmCliProd) > 0 {
-		out, err = exec.Command("sh", ocmCliProd, "token").CombinedOutput()
+		alias = ocmCliProd
 	} else if ocmEnv == "staging" && len(ocmCliStage) > 0 {
-		out, err = exec.Command("sh", ocmCliStage, "token").CombinedOutput()
+		alias = ocmCliStage
 	} else {
-		out, err = exec.Command("ocm", "token").CombinedOutput()
+		// Exchanges the refresh token of the ocm CLI as "ocm token" does
+		client, err := pkgIntOcm.NewClientFromConfig(pkgIntOcm.GetConfigFile(), "")
+		if err != nil {
+			return "", err
+		}
+		return client.Token(ctx)
 	}
 
+	out, err := RunOutput(ctx, "sh", alias, "token")
 	if err != nil {
-		return "", fmt.Errorf("%s: %w", out, err)
+		return "", err
 	}
-
-	ocmToken = string(out)
-	return ocmToken, err
+	return strings.TrimSpace(string(out)), nil
 }
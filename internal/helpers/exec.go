@@ -1,124 +1,338 @@
 package internal
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"strings"
+	"syscall"
+	"time"
 
-	gocmd "github.com/go-cmd/cmd"
 	logger "github.com/sirupsen/logrus"
 )
 
-func RunCommandBackground(
-	cmdName string,
-	cmdArgs []string,
-	envVars [][]string,
-) error {
-	cmd := exec.Command(cmdName, cmdArgs...)
-	for _, env := range envVars {
-		envVar := fmt.Sprintf("%s=%s", env[0], env[1])
-		cmd.Env = append(cmd.Env, envVar)
+const (
+	// Time a stopped command has to exit after SIGTERM before it is killed
+	defaultGracePeriod = 5 * time.Second
+	// Number of lines of the standard error kept in results
+	stderrTailLines = 10
+	// Bytes of the standard error kept to get its last lines
+	stderrTailSize = 4096
+
+	redactedSecret = "******"
+)
+
+// A command to run with a Runner.
+type Command struct {
+	Name string
+	Args []string
+	// Environment variables added to the environment of hc, as KEY=value
+	Env []string
+	// Working directory, the current directory if empty
+	Dir string
+	// The command is stopped once it runs longer, unless zero
+	Timeout time.Duration
+	// Time a stopped command has to exit after SIGTERM before it is killed
+	Grace time.Duration
+	// Whether the command runs in the terminal of hc with its standard
+	// streams, e.g. a shell or an editor. Its output is not captured. SIGTERM
+	// and SIGHUP received by hc are forwarded to it, SIGINT is ignored since
+	// the terminal sends it to the command too.
+	Interactive bool
+	// Whether SIGINT, SIGTERM and SIGHUP received by hc are forwarded to the
+	// command, e.g. when the terminal does not send them to it
+	ForwardSignals bool
+	// Standard input, empty if nil
+	Stdin io.Reader
+	// The output is streamed to these writers as well as captured
+	Stdout io.Writer
+	Stderr io.Writer
+	// Values masked in logs and errors, e.g. tokens given as arguments
+	Secrets []string
+}
+
+func NewCommand(name string, args ...string) *Command {
+	return &Command{Name: name, Args: args}
+}
+
+// Streams the output of the command to the output of hc.
+func (c *Command) Streamed() *Command {
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	return c
+}
+
+// Masks the secrets of the command in a text.
+func (c *Command) Redact(text string) string {
+	for _, secret := range c.Secrets {
+		if len(secret) > 0 {
+			text = strings.ReplaceAll(text, secret, redactedSecret)
+		}
+	}
+	return text
+}
+
+// Formats the command line with its secrets masked.
+func (c *Command) String() string {
+	return c.Redact(strings.TrimSpace(c.Name + " " + strings.Join(c.Args, " ")))
+}
+
+// Result of a command.
+type Result struct {
+	// -1 if the command did not exit, 128 + the signal if it was killed by a
+	// signal
+	ExitCode int
+	Duration time.Duration
+	// Captured standard output, empty for interactive commands
+	Stdout []byte
+	// Last lines of the standard error, with the secrets masked
+	StderrTail string
+	// Whether the command was stopped after its timeout
+	TimedOut bool
+}
+
+// Returned when a command cannot be run or does not exit with 0.
+type CommandError struct {
+	// Command line with its secrets masked
+	Command string
+	Result  *Result
+	Err     error
+}
+
+func (e *CommandError) Error() string {
+	var msg string
+	switch {
+	case e.Result != nil && e.Result.TimedOut:
+		msg = fmt.Sprintf("%s: timed out after %s", e.Command, e.Result.Duration.Round(time.Second))
+	case e.Err != nil:
+		msg = fmt.Sprintf("%s: %v", e.Command, e.Err)
+	default:
+		msg = fmt.Sprintf("%s: exit code %d", e.Command, e.Result.ExitCode)
+	}
+	if e.Result != nil && len(e.Result.StderrTail) > 0 {
+		msg = fmt.Sprintf("%s: %s", msg, e.Result.StderrTail)
 	}
+	return msg
+}
 
-	err := cmd.Start()
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
 
-	if err != nil {
-		return err
+// Gets the exit code of a failed command, -1 if it did not exit.
+func GetExitCode(err error) int {
+	var commandErr *CommandError
+	if errors.As(err, &commandErr) && commandErr.Result != nil {
+		return commandErr.Result.ExitCode
 	}
+	if err == nil {
+		return 0
+	}
+	return -1
+}
 
-	go func() {
-		err = cmd.Wait()
-		if err != nil {
-			logger.Errorf("Command finished with error: %v", err)
-		}
-	}()
+// Runs commands.
+type Runner interface {
+	// Runs a command until it exits, its timeout expires or the context is
+	// cancelled. Returns a CommandError unless the command exits with 0. The
+	// result is returned whenever the command was started.
+	Run(ctx context.Context, command *Command) (*Result, error)
+}
 
-	return nil
+var defaultRunner Runner = &ExecRunner{}
+
+// Replaces the runner of Run, e.g. with a RecordingRunner. Returns a function
+// that restores the previous runner.
+func SetRunner(runner Runner) func() {
+	previous := defaultRunner
+	defaultRunner = runner
+	return func() {
+		defaultRunner = previous
+	}
 }
 
-func RunCommandOutput(cmdName string, cmdArgs ...string) ([]byte, error) {
-	logger.Debugf("Running command: %s %s\n", cmdName, cmdArgs)
-	cmd := exec.Command(cmdName, cmdArgs...)
-	return cmd.Output()
+// Runs a command with the default runner.
+func Run(ctx context.Context, command *Command) (*Result, error) {
+	return defaultRunner.Run(ctx, command)
 }
 
-func RunCommandPipeStdin(cmdName string, cmdArgs ...string) ([]byte, error) {
-	logger.Debugf("Running command: %s %s\n", cmdName, cmdArgs)
-	cmd := exec.Command(cmdName, cmdArgs...)
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
+// Runs a command and returns its standard output.
+func RunOutput(ctx context.Context, name string, args ...string) ([]byte, error) {
+	result, err := Run(ctx, NewCommand(name, args...))
+	if result == nil {
 		return nil, err
 	}
+	return result.Stdout, err
+}
 
-	go func() {
-		defer stdin.Close()
-		io.WriteString(stdin, "")
-	}()
-
-	return cmd.Output()
+// Runs a command streaming its output to the output of hc.
+func RunStreamed(ctx context.Context, name string, args ...string) error {
+	_, err := Run(ctx, NewCommand(name, args...).Streamed())
+	return err
 }
 
-func RunCommandWithOsFiles(cmdName string, stdout *os.File, stderr *os.File, stdin *os.File, cmdArgs ...string) error {
-	// log.Printf("Running command: %s %s\n", cmdName, cmdArgs)
-	cmd := exec.Command(cmdName, cmdArgs...)
-	cmd.Stdout = stdout
-	cmd.Stdin = stdin
-	cmd.Stderr = stderr
-	err := cmd.Run()
+// Runs a command in the terminal of hc.
+func RunInteractive(ctx context.Context, name string, args ...string) error {
+	command := NewCommand(name, args...)
+	command.Interactive = true
+	_, err := Run(ctx, command)
 	return err
 }
 
-func RunCommandListStreamOutput(commandList [][]string) []error {
+// Runs commands streaming their output, whether the previous ones failed or
+// not. Returns the errors of the failed commands.
+func RunStreamedList(ctx context.Context, commandList [][]string) []error {
 	errors := []error{}
 	for _, command := range commandList {
-		status := RunCommandStreamOutput(command[0], command[1:]...)
-		if status.Error != nil {
-			logger.Errorf("Failed to run command \"command\": %v", status.Error)
-			errors = append(errors, status.Error)
+		if err := RunStreamed(ctx, command[0], command[1:]...); err != nil {
+			logger.Errorf("Failed to run command: %v", err)
+			errors = append(errors, err)
 		}
 	}
 	return errors
 }
 
-// Runs a blocking command (go-cmd) and streams its output.
-// https://github.com/go-cmd/cmd/blob/master/examples/blocking-streaming/main.go
-func RunCommandStreamOutput(cmdName string, args ...string) gocmd.Status {
-	logger.Debugf("Running command: %s %s\n", cmdName, args)
-
-	cmdOptions := gocmd.Options{
-		Buffered:  false,
-		Streaming: true,
-	}
-	command := gocmd.NewCmdOptions(cmdOptions, cmdName, args...)
-
-	doneChan := make(chan struct{})
-
-	go func() {
-		defer close(doneChan)
-		// Done when both channels have been closed
-		// https://dave.cheney.net/2013/04/30/curious-channels
-		for command.Stdout != nil || command.Stderr != nil {
-			select {
-			case line, open := <-command.Stdout:
-				if !open {
-					command.Stdout = nil
-					continue
-				}
-				fmt.Println(line)
-			case line, open := <-command.Stderr:
-				if !open {
-					command.Stderr = nil
-					continue
-				}
-				fmt.Fprintln(os.Stderr, line)
+// Runs commands with os/exec.
+type ExecRunner struct{}
+
+// Keeps the last bytes written to it.
+type tailBuffer struct {
+	buf []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > stderrTailSize {
+		t.buf = t.buf[len(t.buf)-stderrTailSize:]
+	}
+	return len(p), nil
+}
+
+// Gets the last lines written.
+func (t *tailBuffer) lines(count int) string {
+	lines := strings.Split(strings.TrimRight(string(t.buf), "\n"), "\n")
+	if len(lines) > count {
+		lines = lines[len(lines)-count:]
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func teeWriter(capture io.Writer, stream io.Writer) io.Writer {
+	if stream == nil {
+		return capture
+	}
+	return io.MultiWriter(capture, stream)
+}
+
+// Gets the exit code of a command that was waited for, as the shells report it.
+func getExitCode(state *os.ProcessState) int {
+	if state == nil {
+		return -1
+	}
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return state.ExitCode()
+}
+
+func (r *ExecRunner) Run(ctx context.Context, command *Command) (*Result, error) {
+	if command.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, command.Timeout)
+		defer cancel()
+	}
+	grace := command.Grace
+	if grace == 0 {
+		grace = defaultGracePeriod
+	}
+
+	cmd := exec.CommandContext(ctx, command.Name, command.Args...)
+	// Stopped commands get a chance to clean up before they are killed
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = grace
+	cmd.Dir = command.Dir
+	if len(command.Env) > 0 {
+		cmd.Env = append(os.Environ(), command.Env...)
+	}
+
+	var stdout bytes.Buffer
+	var stderr tailBuffer
+	if command.Interactive {
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+	} else {
+		cmd.Stdin = command.Stdin
+		cmd.Stdout = teeWriter(&stdout, command.Stdout)
+		cmd.Stderr = teeWriter(&stderr, command.Stderr)
+	}
+
+	logger.Debugf("Running command: %s", command)
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, &CommandError{Command: command.String(), Err: err}
+	}
+
+	if command.Interactive || command.ForwardSignals {
+		signals, stop := InterceptSignals()
+		done := make(chan struct{})
+		defer func() {
+			stop()
+			close(done)
+		}()
+		go forwardSignals(cmd, command, signals, done, grace)
+	}
+
+	err := cmd.Wait()
+	result := &Result{
+		ExitCode:   getExitCode(cmd.ProcessState),
+		Duration:   time.Since(start),
+		Stdout:     stdout.Bytes(),
+		StderrTail: command.Redact(stderr.lines(stderrTailLines)),
+		TimedOut:   errors.Is(ctx.Err(), context.DeadlineExceeded),
+	}
+	logger.Debugf("Command exited with %d in %s: %s", result.ExitCode, result.Duration, command)
+
+	if err == nil && ctx.Err() == nil {
+		return result, nil
+	}
+	commandErr := &CommandError{Command: command.String(), Result: result}
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		commandErr.Err = ctx.Err()
+	case !errors.As(err, &exitErr):
+		commandErr.Err = err
+	}
+	return result, commandErr
+}
+
+// Forwards signals to a command until it exits. The command is killed if it
+// has not exited within the grace period after the first signal.
+func forwardSignals(cmd *exec.Cmd, command *Command, signals <-chan os.Signal, done chan struct{}, grace time.Duration) {
+	var kill <-chan time.Time
+	for {
+		select {
+		case sig := <-signals:
+			if sig == syscall.SIGINT && !command.ForwardSignals {
+				continue
+			}
+			logger.Debugf("Forwarding %v to %s", sig, command.Name)
+			cmd.Process.Signal(sig)
+			if kill == nil {
+				kill = time.After(grace)
 			}
+		case <-kill:
+			logger.Warnf("%s did not exit within %s, killing it", command.Name, grace)
+			cmd.Process.Kill()
+		case <-done:
+			return
 		}
-	}()
-
-	// Run and wait for Cmd to return, discard Status
-	<-command.Start()
-	// Wait for goroutine to print everything
-	<-doneChan
-	return command.Status()
+	}
 }
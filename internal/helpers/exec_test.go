@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunOutput(t *testing.T) {
+	stdout, err := RunOutput(context.Background(), "sh", "-c", "echo hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(stdout) != "hello\n" {
+		t.Errorf("output %q, expected %q", stdout, "hello\n")
+	}
+}
+
+func TestRunExitCode(t *testing.T) {
+	lines := []string{}
+	for i := 1; i <= 20; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	command := NewCommand("sh", "-c", "for i in $(seq 1 20); do echo line $i >&2; done; exit 3")
+
+	result, err := (&ExecRunner{}).Run(context.Background(), command)
+	if err == nil {
+		t.Fatal("no error for a non-zero exit code")
+	}
+	if code := GetExitCode(err); code != 3 || result.ExitCode != 3 {
+		t.Errorf("exit code %d (%d in the result), expected 3", code, result.ExitCode)
+	}
+	expected := strings.Join(lines[20-stderrTailLines:], "\n")
+	if result.StderrTail != expected {
+		t.Errorf("stderr tail %q, expected %q", result.StderrTail, expected)
+	}
+	if !strings.HasSuffix(err.Error(), "exit code 3: "+expected) {
+		t.Errorf("error %q does not report the exit code and the stderr tail", err)
+	}
+}
+
+func TestRunNotFound(t *testing.T) {
+	result, err := (&ExecRunner{}).Run(context.Background(), NewCommand("hc-command-not-found"))
+	if err == nil || result != nil {
+		t.Fatalf("result %v and error %v, expected an error only", result, err)
+	}
+	if code := GetExitCode(err); code != -1 {
+		t.Errorf("exit code %d, expected -1", code)
+	}
+}
+
+func TestGetExitCode(t *testing.T) {
+	if code := GetExitCode(nil); code != 0 {
+		t.Errorf("exit code %d without error, expected 0", code)
+	}
+	if code := GetExitCode(errors.New("failed")); code != -1 {
+		t.Errorf("exit code %d for another error, expected -1", code)
+	}
+	err := fmt.Errorf("wrapped: %w", &CommandError{Result: &Result{ExitCode: 42}})
+	if code := GetExitCode(err); code != 42 {
+		t.Errorf("exit code %d for a wrapped error, expected 42", code)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	command := NewCommand("sh", "-c", "sleep 5")
+	command.Timeout = 100 * time.Millisecond
+	command.Grace = 200 * time.Millisecond
+
+	result, err := (&ExecRunner{}).Run(context.Background(), command)
+	if err == nil {
+		t.Fatal("no error for a timed out command")
+	}
+	if !result.TimedOut || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("result %+v and error %v, expected a timeout", result, err)
+	}
+	if result.Duration > 2*time.Second {
+		t.Errorf("command stopped after %s, expected %s", result.Duration, command.Timeout)
+	}
+	if !strings.Contains(err.Error(), "timed out after") {
+		t.Errorf("error %q does not report the timeout", err)
+	}
+}
+
+func TestRunCancelGrace(t *testing.T) {
+	// The command ignores SIGTERM, so it is killed after the grace period
+	command := NewCommand("sh", "-c", "trap '' TERM; exec sleep 5")
+	command.Grace = 200 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	result, err := (&ExecRunner{}).Run(ctx, command)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error %v, expected the command to be cancelled", err)
+	}
+	if result.TimedOut {
+		t.Error("cancelled command reported as timed out")
+	}
+	if result.ExitCode != 137 {
+		t.Errorf("exit code %d, expected the command to be killed", result.ExitCode)
+	}
+	if result.Duration < command.Grace || result.Duration > 2*time.Second {
+		t.Errorf("command stopped after %s, expected the grace period of %s", result.Duration, command.Grace)
+	}
+}
+
+func TestCommandSecrets(t *testing.T) {
+	command := NewCommand("sh", "-c", "echo token my-token >&2; exit 1", "my-token")
+	command.Secrets = []string{"my-token", ""}
+
+	if s := command.String(); s != "sh -c echo token ****** >&2; exit 1 ******" {
+		t.Errorf("command %q, expected the token to be masked", s)
+	}
+	result, err := (&ExecRunner{}).Run(context.Background(), command)
+	if err == nil {
+		t.Fatal("no error for a non-zero exit code")
+	}
+	if result.StderrTail != "token ******" {
+		t.Errorf("stderr tail %q, expected the token to be masked", result.StderrTail)
+	}
+	if strings.Contains(err.Error(), "my-token") {
+		t.Errorf("error %q, expected the token to be masked", err)
+	}
+}
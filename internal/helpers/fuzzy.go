@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"sort"
+	"strings"
+)
+
+// Scores how well a query matches a candidate, case-insensitively. Exact
+// matches score highest, followed by prefixes, substrings and finally
+// subsequences with fewer gaps. Returns -1 if the query does not match.
+func FuzzyScore(query string, candidate string) int {
+	query = strings.ToLower(query)
+	candidate = strings.ToLower(candidate)
+
+	switch {
+	case len(query) == 0:
+		return 0
+	case query == candidate:
+		return 4000
+	case strings.HasPrefix(candidate, query):
+		return 3000 - len(candidate)
+	case strings.Contains(candidate, query):
+		return 2000 - strings.Index(candidate, query) - len(candidate)
+	}
+
+	// Subsequence match
+	gaps := 0
+	pos := 0
+	for _, r := range query {
+		idx := strings.IndexRune(candidate[pos:], r)
+		if idx < 0 {
+			return -1
+		}
+		if pos > 0 {
+			gaps += idx
+		}
+		pos += idx + len(string(r))
+	}
+	return 1000 - gaps - len(candidate)
+}
+
+// Filters candidates that match a query, best matches first.
+func FuzzyFilter(query string, candidates []string) []string {
+	type match struct {
+		candidate string
+		score     int
+	}
+	matches := []match{}
+	for _, candidate := range candidates {
+		if score := FuzzyScore(query, candidate); score >= 0 {
+			matches = append(matches, match{candidate, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	filtered := []string{}
+	for _, m := range matches {
+		filtered = append(filtered, m.candidate)
+	}
+	return filtered
+}
@@ -0,0 +1,496 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Kubeconfig types follow k8s.io/client-go/tools/clientcmd/api/v1. Fields
+// that are not modelled are kept in Extra so that edited files keep them.
+
+type KubeconfigExtension struct {
+	Name      string    `yaml:"name"`
+	Extension yaml.Node `yaml:"extension"`
+}
+
+type KubeconfigCluster struct {
+	Server                   string                 `yaml:"server"`
+	TLSServerName            string                 `yaml:"tls-server-name,omitempty"`
+	InsecureSkipTLSVerify    bool                   `yaml:"insecure-skip-tls-verify,omitempty"`
+	CertificateAuthority     string                 `yaml:"certificate-authority,omitempty"`
+	CertificateAuthorityData string                 `yaml:"certificate-authority-data,omitempty"`
+	ProxyURL                 string                 `yaml:"proxy-url,omitempty"`
+	Extensions               []KubeconfigExtension  `yaml:"extensions,omitempty"`
+	Extra                    map[string]interface{} `yaml:",inline"`
+}
+
+type KubeconfigUser struct {
+	ClientCertificate     string `yaml:"client-certificate,omitempty"`
+	ClientCertificateData string `yaml:"client-certificate-data,omitempty"`
+	ClientKey             string `yaml:"client-key,omitempty"`
+	ClientKeyData         string `yaml:"client-key-data,omitempty"`
+	Token                 string `yaml:"token,omitempty"`
+	TokenFile             string `yaml:"tokenFile,omitempty"`
+	// User to impersonate
+	As         string                 `yaml:"as,omitempty"`
+	AsGroups   []string               `yaml:"as-groups,omitempty"`
+	Username   string                 `yaml:"username,omitempty"`
+	Password   string                 `yaml:"password,omitempty"`
+	Extensions []KubeconfigExtension  `yaml:"extensions,omitempty"`
+	Extra      map[string]interface{} `yaml:",inline"`
+}
+
+type KubeconfigContext struct {
+	Cluster    string                 `yaml:"cluster"`
+	User       string                 `yaml:"user"`
+	Namespace  string                 `yaml:"namespace,omitempty"`
+	Extensions []KubeconfigExtension  `yaml:"extensions,omitempty"`
+	Extra      map[string]interface{} `yaml:",inline"`
+}
+
+type KubeconfigNamedCluster struct {
+	Name    string            `yaml:"name"`
+	Cluster KubeconfigCluster `yaml:"cluster"`
+	// File the cluster is defined in
+	File string `yaml:"-"`
+}
+
+type KubeconfigNamedUser struct {
+	Name string         `yaml:"name"`
+	User KubeconfigUser `yaml:"user"`
+	// File the user is defined in
+	File string `yaml:"-"`
+}
+
+type KubeconfigNamedContext struct {
+	Name    string            `yaml:"name"`
+	Context KubeconfigContext `yaml:"context"`
+	// File the context is defined in
+	File string `yaml:"-"`
+}
+
+type Kubeconfig struct {
+	APIVersion     string                   `yaml:"apiVersion"`
+	Kind           string                   `yaml:"kind"`
+	Preferences    map[string]interface{}   `yaml:"preferences"`
+	Clusters       []KubeconfigNamedCluster `yaml:"clusters"`
+	Users          []KubeconfigNamedUser    `yaml:"users"`
+	Contexts       []KubeconfigNamedContext `yaml:"contexts"`
+	CurrentContext string                   `yaml:"current-context"`
+	Extensions     []KubeconfigExtension    `yaml:"extensions,omitempty"`
+	Extra          map[string]interface{}   `yaml:",inline"`
+	// Files the kubeconfig was loaded from, in precedence order
+	Files []string `yaml:"-"`
+	// File that sets the current context
+	CurrentContextFile string `yaml:"-"`
+}
+
+// Gets the default kubeconfig file, ~/.kube/config.
+func GetDefaultKubeconfigFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".kube", "config")
+}
+
+// Gets the kubeconfig files in precedence order, the files listed in
+// $KUBECONFIG or ~/.kube/config.
+func GetKubeconfigFiles() []string {
+	files := []string{}
+	for _, file := range filepath.SplitList(os.Getenv("KUBECONFIG")) {
+		if len(file) > 0 {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		files = append(files, GetDefaultKubeconfigFile())
+	}
+	return files
+}
+
+func ParseKubeconfig(content []byte) (*Kubeconfig, error) {
+	var kubeconfig Kubeconfig
+	if err := yaml.Unmarshal(content, &kubeconfig); err != nil {
+		return nil, err
+	}
+	return &kubeconfig, nil
+}
+
+// Loads a single kubeconfig file.
+func LoadKubeconfigFile(file string) (*Kubeconfig, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	kubeconfig, err := ParseKubeconfig(content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig %s: %w", file, err)
+	}
+
+	kubeconfig.Files = []string{file}
+	if len(kubeconfig.CurrentContext) > 0 {
+		kubeconfig.CurrentContextFile = file
+	}
+	for idx := range kubeconfig.Clusters {
+		kubeconfig.Clusters[idx].File = file
+	}
+	for idx := range kubeconfig.Users {
+		kubeconfig.Users[idx].File = file
+	}
+	for idx := range kubeconfig.Contexts {
+		kubeconfig.Contexts[idx].File = file
+	}
+	return kubeconfig, nil
+}
+
+// Loads and merges kubeconfig files following the kubectl rules: missing
+// files are skipped, the first file that sets the current context wins and
+// the first file that defines a cluster, user, context or extension name
+// wins. Without files, the files of GetKubeconfigFiles are loaded.
+func LoadKubeconfig(files ...string) (*Kubeconfig, error) {
+	if len(files) == 0 {
+		files = GetKubeconfigFiles()
+	}
+
+	merged := &Kubeconfig{
+		APIVersion: "v1",
+		Kind:       "Config",
+	}
+	clusters := map[string]bool{}
+	users := map[string]bool{}
+	contexts := map[string]bool{}
+	extensions := map[string]bool{}
+
+	for _, file := range files {
+		kubeconfig, err := LoadKubeconfigFile(file)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		merged.Files = append(merged.Files, file)
+
+		if len(merged.CurrentContext) == 0 && len(kubeconfig.CurrentContext) > 0 {
+			merged.CurrentContext = kubeconfig.CurrentContext
+			merged.CurrentContextFile = file
+		}
+		if merged.Preferences == nil {
+			merged.Preferences = kubeconfig.Preferences
+		}
+		for _, cluster := range kubeconfig.Clusters {
+			if !clusters[cluster.Name] {
+				clusters[cluster.Name] = true
+				merged.Clusters = append(merged.Clusters, cluster)
+			}
+		}
+		for _, user := range kubeconfig.Users {
+			if !users[user.Name] {
+				users[user.Name] = true
+				merged.Users = append(merged.Users, user)
+			}
+		}
+		for _, context := range kubeconfig.Contexts {
+			if !contexts[context.Name] {
+				contexts[context.Name] = true
+				merged.Contexts = append(merged.Contexts, context)
+			}
+		}
+		for _, extension := range kubeconfig.Extensions {
+			if !extensions[extension.Name] {
+				extensions[extension.Name] = true
+				merged.Extensions = append(merged.Extensions, extension)
+			}
+		}
+	}
+	return merged, nil
+}
+
+func (k *Kubeconfig) GetCluster(name string) *KubeconfigNamedCluster {
+	for idx := range k.Clusters {
+		if k.Clusters[idx].Name == name {
+			return &k.Clusters[idx]
+		}
+	}
+	return nil
+}
+
+func (k *Kubeconfig) GetUser(name string) *KubeconfigNamedUser {
+	for idx := range k.Users {
+		if k.Users[idx].Name == name {
+			return &k.Users[idx]
+		}
+	}
+	return nil
+}
+
+func (k *Kubeconfig) GetContext(name string) *KubeconfigNamedContext {
+	for idx := range k.Contexts {
+		if k.Contexts[idx].Name == name {
+			return &k.Contexts[idx]
+		}
+	}
+	return nil
+}
+
+func (k *Kubeconfig) GetCurrentContext() (*KubeconfigNamedContext, error) {
+	if len(k.CurrentContext) == 0 {
+		return nil, errors.New("current context is not set")
+	}
+	context := k.GetContext(k.CurrentContext)
+	if context == nil {
+		return nil, fmt.Errorf("current context not found: %s", k.CurrentContext)
+	}
+	return context, nil
+}
+
+// Gets the cluster of the current context.
+func (k *Kubeconfig) GetCurrentCluster() (*KubeconfigNamedCluster, error) {
+	context, err := k.GetCurrentContext()
+	if err != nil {
+		return nil, err
+	}
+	cluster := k.GetCluster(context.Context.Cluster)
+	if cluster == nil {
+		return nil, fmt.Errorf("cluster of context %s not found: %s", context.Name, context.Context.Cluster)
+	}
+	return cluster, nil
+}
+
+// Gets the user of the current context.
+func (k *Kubeconfig) GetCurrentUser() (*KubeconfigNamedUser, error) {
+	context, err := k.GetCurrentContext()
+	if err != nil {
+		return nil, err
+	}
+	user := k.GetUser(context.Context.User)
+	if user == nil {
+		return nil, fmt.Errorf("user of context %s not found: %s", context.Name, context.Context.User)
+	}
+	return user, nil
+}
+
+// Gets the namespace of the current context, empty if it is not set.
+func (k *Kubeconfig) GetCurrentNamespace() (string, error) {
+	context, err := k.GetCurrentContext()
+	if err != nil {
+		return "", err
+	}
+	return context.Context.Namespace, nil
+}
+
+// Gets the file that the current context is written to: the file that sets
+// it, otherwise the first loaded file, as kubectl does.
+func (k *Kubeconfig) GetCurrentContextFile() (string, error) {
+	if len(k.CurrentContextFile) > 0 {
+		return k.CurrentContextFile, nil
+	}
+	if len(k.Files) > 0 {
+		return k.Files[0], nil
+	}
+	return "", errors.New("no kubeconfig file found")
+}
+
+// Switches the current context in the kubeconfig file that sets it.
+func (k *Kubeconfig) SetCurrentContext(name string) error {
+	if k.GetContext(name) == nil {
+		return fmt.Errorf("context not found: %s", name)
+	}
+	file, err := k.GetCurrentContextFile()
+	if err != nil {
+		return err
+	}
+
+	err = editKubeconfigFile(file, func(root *yaml.Node) error {
+		setYamlMappingValue(root, "current-context", name)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	k.CurrentContext = name
+	k.CurrentContextFile = file
+	return nil
+}
+
+// Sets the namespace of a context in the kubeconfig file that defines it.
+func (k *Kubeconfig) SetContextNamespace(contextName string, namespace string) error {
+	context := k.GetContext(contextName)
+	if context == nil {
+		return fmt.Errorf("context not found: %s", contextName)
+	}
+
+	err := editKubeconfigFile(context.File, func(root *yaml.Node) error {
+		contexts := getYamlMappingValue(root, "contexts")
+		if contexts == nil || contexts.Kind != yaml.SequenceNode {
+			return fmt.Errorf("%s: contexts not found", context.File)
+		}
+		for _, item := range contexts.Content {
+			name := getYamlMappingValue(item, "name")
+			if name == nil || name.Value != contextName {
+				continue
+			}
+			contextNode := getYamlMappingValue(item, "context")
+			if contextNode == nil {
+				contextNode = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+				setYamlMappingNode(item, "context", contextNode)
+			}
+			setYamlMappingValue(contextNode, "namespace", namespace)
+			return nil
+		}
+		return fmt.Errorf("%s: context not found: %s", context.File, contextName)
+	})
+	if err != nil {
+		return err
+	}
+	context.Context.Namespace = namespace
+	return nil
+}
+
+// Edits a user in the kubeconfig file that defines it.
+func (k *Kubeconfig) editUser(userName string, edit func(userNode *yaml.Node)) error {
+	user := k.GetUser(userName)
+	if user == nil {
+		return fmt.Errorf("user not found: %s", userName)
+	}
+
+	return editKubeconfigFile(user.File, func(root *yaml.Node) error {
+		users := getYamlMappingValue(root, "users")
+		if users == nil || users.Kind != yaml.SequenceNode {
+			return fmt.Errorf("%s: users not found", user.File)
+		}
+		for _, item := range users.Content {
+			name := getYamlMappingValue(item, "name")
+			if name == nil || name.Value != userName {
+				continue
+			}
+			userNode := getYamlMappingValue(item, "user")
+			if userNode == nil {
+				userNode = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+				setYamlMappingNode(item, "user", userNode)
+			}
+			edit(userNode)
+			return nil
+		}
+		return fmt.Errorf("%s: user not found: %s", user.File, userName)
+	})
+}
+
+// Makes a kubeconfig user impersonate another user, with extra values such as
+// the reason of a backplane elevation.
+func (k *Kubeconfig) SetUserImpersonation(userName string, as string, extra map[string][]string) error {
+	err := k.editUser(userName, func(userNode *yaml.Node) {
+		setYamlMappingValue(userNode, "as", as)
+		if len(extra) == 0 {
+			return
+		}
+		extraNode := &yaml.Node{}
+		extraNode.Encode(extra)
+		setYamlMappingNode(userNode, "as-user-extra", extraNode)
+	})
+	if err != nil {
+		return err
+	}
+	k.GetUser(userName).User.As = as
+	return nil
+}
+
+// Stops a kubeconfig user from impersonating another user.
+func (k *Kubeconfig) ClearUserImpersonation(userName string) error {
+	err := k.editUser(userName, func(userNode *yaml.Node) {
+		for _, key := range []string{"as", "as-uid", "as-groups", "as-user-extra"} {
+			deleteYamlMappingKey(userNode, key)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	user := k.GetUser(userName)
+	user.User.As = ""
+	user.User.AsGroups = nil
+	delete(user.User.Extra, "as-uid")
+	delete(user.User.Extra, "as-user-extra")
+	return nil
+}
+
+// Edits a kubeconfig file as a yaml document so that fields that are not
+// modelled, and comments, are kept.
+func editKubeconfigFile(file string, edit func(root *yaml.Node) error) error {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return fmt.Errorf("invalid kubeconfig %s: %w", file, err)
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{
+			Kind:    yaml.DocumentNode,
+			Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}},
+		}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("invalid kubeconfig %s: not a mapping", file)
+	}
+	if err := edit(root); err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	return WriteFileAtomic(file, out.Bytes(), 0600)
+}
+
+func getYamlMappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+	for idx := 0; idx+1 < len(mapping.Content); idx += 2 {
+		if mapping.Content[idx].Value == key {
+			return mapping.Content[idx+1]
+		}
+	}
+	return nil
+}
+
+func setYamlMappingNode(mapping *yaml.Node, key string, value *yaml.Node) {
+	for idx := 0; idx+1 < len(mapping.Content); idx += 2 {
+		if mapping.Content[idx].Value == key {
+			mapping.Content[idx+1] = value
+			return
+		}
+	}
+	mapping.Content = append(
+		mapping.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		value,
+	)
+}
+
+func setYamlMappingValue(mapping *yaml.Node, key string, value string) {
+	setYamlMappingNode(mapping, key, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value})
+}
+
+func deleteYamlMappingKey(mapping *yaml.Node, key string) {
+	for idx := 0; idx+1 < len(mapping.Content); idx += 2 {
+		if mapping.Content[idx].Value == key {
+			mapping.Content = append(mapping.Content[:idx], mapping.Content[idx+2:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGetCurrentCluster(t *testing.T) {
+	tests := []struct {
+		file      string
+		cluster   string
+		server    string
+		namespace string
+		user      string
+		// Id of the backplane cluster, empty unless the server is backplane
+		backplaneID string
+		// Error of the current cluster, empty if it is found
+		err string
+	}{
+		{
+			file: "kubeconfig-no-current-context.yaml",
+			err:  "current context is not set",
+		},
+		{
+			file: "kubeconfig-dangling-context.yaml",
+			err:  "current context not found: removed-context",
+		},
+		{
+			file:      "kubeconfig-dangling-cluster.yaml",
+			namespace: "default",
+			err:       "cluster of context default/api-removed-cluster-example-com:6443/me not found: api-removed-cluster-example-com:6443",
+		},
+		{
+			file:      "kubeconfig-multiple-clusters.yaml",
+			cluster:   "api-my-other-cluster-example-com:6443",
+			server:    "https://api.my-other-cluster.example.com:6443",
+			namespace: "openshift-monitoring",
+			user:      "me/api-my-other-cluster-example-com:6443",
+		},
+		{
+			file:        "kubeconfig-backplane.yaml",
+			cluster:     "my-cluster",
+			server:      "https://api.backplane.example.com/backplane/cluster/1a2b3c4d5e6f/",
+			namespace:   "openshift-ingress",
+			user:        "my-cluster",
+			backplaneID: "1a2b3c4d5e6f",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			content, err := os.ReadFile(filepath.Join("testdata", test.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			kubeconfig, err := ParseKubeconfig(content)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cluster, err := kubeconfig.GetCurrentCluster()
+			if len(test.err) > 0 {
+				if err == nil || err.Error() != test.err {
+					t.Errorf("error %v, expected %q", err, test.err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else {
+				if cluster.Name != test.cluster || cluster.Cluster.Server != test.server {
+					t.Errorf("cluster %s at %s, expected %s at %s", cluster.Name, cluster.Cluster.Server, test.cluster, test.server)
+				}
+				if id := GetBackplaneClusterID(cluster.Cluster.Server); id != test.backplaneID {
+					t.Errorf("backplane cluster %q, expected %q", id, test.backplaneID)
+				}
+				user, err := kubeconfig.GetCurrentUser()
+				if err != nil || user.Name != test.user {
+					t.Errorf("user %v (%v), expected %s", user, err, test.user)
+				}
+			}
+
+			// The namespace is known as long as the current context is
+			namespace, err := kubeconfig.GetCurrentNamespace()
+			if len(test.namespace) > 0 && (err != nil || namespace != test.namespace) {
+				t.Errorf("namespace %q (%v), expected %q", namespace, err, test.namespace)
+			}
+			if len(test.namespace) == 0 && err == nil {
+				t.Errorf("namespace %q of a missing current context", namespace)
+			}
+		})
+	}
+}
+
+// Values that are not modelled are kept.
+func TestParseKubeconfig(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("testdata", "kubeconfig-backplane.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kubeconfig, err := ParseKubeconfig(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proxy := kubeconfig.GetCluster("my-cluster").Cluster.ProxyURL; proxy != "http://proxy.example.com:3128" {
+		t.Errorf("proxy %q, expected http://proxy.example.com:3128", proxy)
+	}
+	exec, ok := kubeconfig.GetUser("my-cluster").User.Extra["exec"].(map[string]interface{})
+	if !ok || exec["command"] != "ocm" || !reflect.DeepEqual(exec["args"], []interface{}{"token"}) {
+		t.Errorf("exec of the user not kept: %v", kubeconfig.GetUser("my-cluster").User.Extra)
+	}
+
+	if _, err := ParseKubeconfig([]byte("clusters: {")); err == nil {
+		t.Error("parsed an invalid kubeconfig")
+	}
+}
+
+// The first file that sets the current context, or defines a name, wins.
+func TestLoadKubeconfig(t *testing.T) {
+	files := []string{
+		filepath.Join("testdata", "kubeconfig-no-current-context.yaml"),
+		filepath.Join("testdata", "missing.yaml"),
+		filepath.Join("testdata", "kubeconfig-multiple-clusters.yaml"),
+		filepath.Join("testdata", "kubeconfig-backplane.yaml"),
+	}
+	kubeconfig, err := LoadKubeconfig(files...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(kubeconfig.Files, []string{files[0], files[2], files[3]}) {
+		t.Errorf("loaded %v, expected the existing files", kubeconfig.Files)
+	}
+	if kubeconfig.CurrentContextFile != files[2] {
+		t.Errorf("current context set by %s, expected %s", kubeconfig.CurrentContextFile, files[2])
+	}
+	if file, err := kubeconfig.GetCurrentContextFile(); err != nil || file != files[2] {
+		t.Errorf("current context written to %s (%v), expected %s", file, err, files[2])
+	}
+	if len(kubeconfig.Clusters) != 3 || len(kubeconfig.Contexts) != 3 {
+		t.Errorf("%d clusters and %d contexts, expected 3 and 3", len(kubeconfig.Clusters), len(kubeconfig.Contexts))
+	}
+	if cluster := kubeconfig.GetCluster("api-my-cluster-example-com:6443"); cluster == nil || cluster.File != files[0] {
+		t.Errorf("cluster defined twice not taken from the first file: %v", cluster)
+	}
+	if context := kubeconfig.GetContext("default/api-my-cluster-example-com:6443/me"); context == nil || context.Context.User != "me" {
+		t.Errorf("context defined twice not taken from the first file: %v", context)
+	}
+	if cluster, err := kubeconfig.GetCurrentCluster(); err != nil || !strings.Contains(cluster.Cluster.Server, "my-other-cluster") {
+		t.Errorf("current cluster %v (%v), expected my-other-cluster", cluster, err)
+	}
+}
@@ -0,0 +1,163 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	logger "github.com/sirupsen/logrus"
+)
+
+// Time hc has to stop after a signal before it exits anyway
+const signalExitDelay = 15 * time.Second
+
+// Signals that stop hc
+var stopSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP}
+
+type cleanup struct {
+	name string
+	run  func()
+}
+
+var lifecycle struct {
+	mutex    sync.Mutex
+	cleanups []*cleanup
+	// Receive the signals instead of the default handling, see InterceptSignals
+	interceptors []chan os.Signal
+}
+
+func init() {
+	// Fatal errors exit through logrus
+	logger.RegisterExitHandler(RunCleanups)
+}
+
+// Registers a cleanup that runs when hc exits, including on fatal errors and
+// signals. Returns a function that runs it right away instead, at most once.
+func AddCleanup(name string, run func()) func() {
+	entry := &cleanup{name: name, run: run}
+	lifecycle.mutex.Lock()
+	lifecycle.cleanups = append(lifecycle.cleanups, entry)
+	lifecycle.mutex.Unlock()
+
+	return func() {
+		if removeCleanup(entry) {
+			runCleanup(entry)
+		}
+	}
+}
+
+func removeCleanup(entry *cleanup) bool {
+	lifecycle.mutex.Lock()
+	defer lifecycle.mutex.Unlock()
+	for idx, registered := range lifecycle.cleanups {
+		if registered == entry {
+			lifecycle.cleanups = append(lifecycle.cleanups[:idx], lifecycle.cleanups[idx+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func runCleanup(entry *cleanup) {
+	logger.Debugf("Cleaning up: %s", entry.name)
+	entry.run()
+}
+
+// Runs the registered cleanups, the last registered first.
+func RunCleanups() {
+	lifecycle.mutex.Lock()
+	cleanups := lifecycle.cleanups
+	lifecycle.cleanups = nil
+	lifecycle.mutex.Unlock()
+
+	for idx := len(cleanups) - 1; idx >= 0; idx-- {
+		runCleanup(cleanups[idx])
+	}
+}
+
+// Runs the registered cleanups and exits.
+func Exit(code int) {
+	RunCleanups()
+	os.Exit(code)
+}
+
+// Receives SIGINT, SIGTERM and SIGHUP instead of the default handling of
+// HandleSignals until the returned function is called.
+func InterceptSignals() (<-chan os.Signal, func()) {
+	signals := make(chan os.Signal, 1)
+	lifecycle.mutex.Lock()
+	lifecycle.interceptors = append(lifecycle.interceptors, signals)
+	lifecycle.mutex.Unlock()
+
+	return signals, func() {
+		lifecycle.mutex.Lock()
+		defer lifecycle.mutex.Unlock()
+		for idx, interceptor := range lifecycle.interceptors {
+			if interceptor == signals {
+				lifecycle.interceptors = append(lifecycle.interceptors[:idx], lifecycle.interceptors[idx+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Passes a signal to the interceptors. Returns false if there are none.
+func interceptSignal(sig os.Signal) bool {
+	lifecycle.mutex.Lock()
+	defer lifecycle.mutex.Unlock()
+	for _, interceptor := range lifecycle.interceptors {
+		select {
+		case interceptor <- sig:
+		default:
+		}
+	}
+	return len(lifecycle.interceptors) > 0
+}
+
+// Handles SIGINT, SIGTERM and SIGHUP until the returned function is called.
+// Signals go to the interceptors if there are any, e.g. the interactive
+// commands being run. Otherwise the returned context is cancelled, which
+// stops the commands being run, and hc exits with 128 + the signal after
+// running the cleanups on a second signal or if it has not exited within
+// signalExitDelay.
+func HandleSignals(parent context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(parent)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, stopSignals...)
+	done := make(chan struct{})
+
+	go func() {
+		var exitCode int
+		var exitTimer <-chan time.Time
+		for {
+			select {
+			case sig := <-signals:
+				if interceptSignal(sig) {
+					continue
+				}
+				if exitTimer != nil {
+					logger.Warnf("Received %v again, exiting", sig)
+					Exit(exitCode)
+				}
+				logger.Warnf("Received %v, stopping", sig)
+				exitCode = 128 + int(sig.(syscall.Signal))
+				exitTimer = time.After(signalExitDelay)
+				cancel()
+			case <-exitTimer:
+				logger.Warnf("Did not stop within %s, exiting", signalExitDelay)
+				Exit(exitCode)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		close(done)
+		cancel()
+	}
+}
@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+var _ Runner = &RecordingRunner{}
+
+// A runner that records the commands instead of running them, for testing
+// commands without podman, oc or ocm.
+type RecordingRunner struct {
+	// Gets the result of a command, the command succeeds with no output if
+	// not set. The context is done once the command times out or is stopped,
+	// after which the command is reported as stopped whatever the result.
+	Respond func(ctx context.Context, command *Command) *Result
+
+	mutex    sync.Mutex
+	commands []*Command
+}
+
+// Gets the recorded commands.
+func (r *RecordingRunner) Commands() []*Command {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]*Command{}, r.commands...)
+}
+
+// Formats the recorded commands with their secrets masked, one per line, e.g.
+// to compare them to golden files.
+func (r *RecordingRunner) String() string {
+	lines := []string{}
+	for _, command := range r.Commands() {
+		lines = append(lines, command.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r *RecordingRunner) Run(ctx context.Context, command *Command) (*Result, error) {
+	r.mutex.Lock()
+	r.commands = append(r.commands, command)
+	r.mutex.Unlock()
+
+	if command.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, command.Timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	result := &Result{}
+	if r.Respond != nil {
+		result = r.Respond(ctx, command)
+	}
+	result.Duration = time.Since(start)
+	if command.Stdout != nil {
+		command.Stdout.Write(result.Stdout)
+	}
+	if err := ctx.Err(); err != nil {
+		result.TimedOut = errors.Is(err, context.DeadlineExceeded)
+		return result, &CommandError{Command: command.String(), Result: result, Err: err}
+	}
+	if result.ExitCode != 0 || result.TimedOut {
+		return result, &CommandError{Command: command.String(), Result: result}
+	}
+	return result, nil
+}
@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+)
+
+func runProvisioningCommand(ctx context.Context, name string, args ...string) error {
+	_, err := Run(ctx, NewCommand(name, args...))
+	return err
+}
+
+// Ensures that a group with the given gid exists and returns its name. An
+// existing group with the gid is reused whatever its name.
+func EnsureGroup(ctx context.Context, name string, gid string) (string, error) {
+	if group, err := user.LookupGroupId(gid); err == nil {
+		return group.Name, nil
+	}
+
+	if _, err := user.LookupGroup(name); err == nil {
+		return name, runProvisioningCommand(ctx, "groupmod", "-g", gid, name)
+	}
+	return name, runProvisioningCommand(ctx, "groupadd", "-g", gid, name)
+}
+
+// Ensures that a user exists with the given uid, primary gid and home
+// directory. Running it again, e.g. when a stopped container is restarted,
+// leaves an already provisioned user untouched.
+func EnsureUser(ctx context.Context, name string, uid string, gid string, home string) error {
+	existing, err := user.Lookup(name)
+	if err == nil {
+		if existing.Uid != uid || existing.Gid != gid {
+			if err := runProvisioningCommand(ctx, "usermod", "-o", "-u", uid, "-g", gid, name); err != nil {
+				return err
+			}
+		}
+		if err := os.MkdirAll(home, 0755); err != nil {
+			return err
+		}
+		return runProvisioningCommand(ctx, "chown", fmt.Sprintf("%s:%s", uid, gid), home)
+	}
+
+	// The uid may already be taken, e.g. by a user of the base image, and
+	// is shared in that case.
+	return runProvisioningCommand(
+		ctx,
+		"useradd",
+		"-m",
+		"-o",
+		"-u",
+		uid,
+		"-g",
+		gid,
+		"-d",
+		home,
+		name,
+	)
+}
+
+// Installs a sudoers drop-in file. The content is checked with visudo before
+// it is installed, since an invalid sudoers file disables sudo altogether.
+func InstallSudoersDropIn(ctx context.Context, path string, content string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0440); err != nil {
+		return err
+	}
+	if err := runProvisioningCommand(ctx, "visudo", "-cf", tmp.Name()); err != nil {
+		return fmt.Errorf("invalid sudoers drop-in: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// A cluster logged in to through backplane in the workspace.
+type WorkspaceCluster struct {
+	// Name of the cluster in OCM, or the name or id it was added with
+	Name string `json:"name"`
+	ID   string `json:"id"`
+	// Kubeconfig context of the backplane login
+	Context string `json:"context"`
+}
+
+var backplaneClusterURLRegex = regexp.MustCompile(`/backplane/cluster/([^/]+)/?$`)
+
+// Gets the id of the cluster behind a backplane API server URL, empty if the
+// URL is not a backplane URL.
+func GetBackplaneClusterID(server string) string {
+	matches := backplaneClusterURLRegex.FindStringSubmatch(server)
+	if matches == nil {
+		return ""
+	}
+	return matches[1]
+}
+
+// Gets the file where the clusters of the workspace are kept.
+func GetWorkspaceClustersFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "hc", "clusters.json")
+}
+
+func LoadWorkspaceClusters() ([]WorkspaceCluster, error) {
+	content, err := os.ReadFile(GetWorkspaceClustersFile())
+	if errors.Is(err, os.ErrNotExist) {
+		return []WorkspaceCluster{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	clusters := []WorkspaceCluster{}
+	if err := json.Unmarshal(content, &clusters); err != nil {
+		return nil, err
+	}
+	return clusters, nil
+}
+
+func SaveWorkspaceClusters(clusters []WorkspaceCluster) error {
+	file := GetWorkspaceClustersFile()
+	content, err := json.MarshalIndent(clusters, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	return WriteFileAtomic(file, append(content, '\n'), 0600)
+}
+
+// Adds a cluster to the workspace or updates the cluster with the same id.
+func AddWorkspaceCluster(cluster WorkspaceCluster) error {
+	clusters, err := LoadWorkspaceClusters()
+	if err != nil {
+		return err
+	}
+	for idx := range clusters {
+		if clusters[idx].ID == cluster.ID {
+			clusters[idx] = cluster
+			return SaveWorkspaceClusters(clusters)
+		}
+	}
+	return SaveWorkspaceClusters(append(clusters, cluster))
+}
+
+// Gets the name a cluster was added to the workspace with, or its id if it
+// was not added with hc.
+func GetWorkspaceClusterName(id string) string {
+	clusters, err := LoadWorkspaceClusters()
+	if err != nil {
+		return id
+	}
+	for _, cluster := range clusters {
+		if cluster.ID == id {
+			return cluster.Name
+		}
+	}
+	return id
+}
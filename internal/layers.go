@@ -0,0 +1,307 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	SystemConfigFile      = "/etc/hc/config.yaml"
+	ProjectConfigFileName = ".hc.yaml"
+	ConfigEnvPrefix       = "HC"
+
+	ConfigLayerSystem  = "system"
+	ConfigLayerUser    = "user"
+	ConfigLayerProject = "project"
+
+	// Origin of config values that are not set
+	ConfigOriginDefault = "default"
+
+	// Set to true to let the project config set the keys that give access to
+	// the host or to the OCM token
+	TrustProjectConfigEnvVar = "HC_TRUST_PROJECT_CONFIG"
+)
+
+// A config file merged into the effective config.
+type ConfigLayer struct {
+	Name string
+	File string
+}
+
+var (
+	// Config layers that were loaded, in merge order
+	configLayers []ConfigLayer
+	// Files that set each config key, keyed by lowercased dotted key
+	configOrigins = map[string]string{}
+	// Keys of the project config that were ignored since it is not trusted
+	ignoredProjectKeys []string
+)
+
+func GetConfigLayers() []ConfigLayer {
+	return configLayers
+}
+
+// Gets the files of the loaded config layers, in merge order.
+func GetConfigFiles() []string {
+	files := []string{}
+	for _, layer := range configLayers {
+		files = append(files, layer.File)
+	}
+	return files
+}
+
+// Gets the keys of the project config that were ignored since it is not
+// trusted, see TrustProjectConfigEnvVar.
+func GetIgnoredProjectKeys() []string {
+	return ignoredProjectKeys
+}
+
+func isProjectConfigTrusted() bool {
+	trusted, _ := strconv.ParseBool(os.Getenv(TrustProjectConfigEnvVar))
+	return trusted
+}
+
+// Removes the keys that only a trusted config may set from config values.
+// Returns the removed keys.
+func removeTrustedOnlyKeys(schema *configKey, values map[string]interface{}, prefix string) []string {
+	removed := []string{}
+	for name, value := range values {
+		key := schema.findKey(name)
+		if key == nil {
+			continue
+		}
+		if key.TrustedOnly {
+			removed = append(removed, joinKey(prefix, key.Name))
+			delete(values, name)
+			continue
+		}
+		if nested, ok := value.(map[string]interface{}); ok && key.Type == schemaObject {
+			removed = append(removed, removeTrustedOnlyKeys(key, nested, joinKey(prefix, key.Name))...)
+		}
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+// Finds the closest project config walking up from a directory.
+func FindProjectConfig(dir string) string {
+	for {
+		path := filepath.Join(dir, ProjectConfigFileName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func isSameFile(a string, b string) bool {
+	aInfo, aErr := os.Stat(a)
+	bInfo, bErr := os.Stat(b)
+	return aErr == nil && bErr == nil && os.SameFile(aInfo, bInfo)
+}
+
+// Binds every config key to its HC_ prefixed environment variable, e.g.
+// image.registry to HC_IMAGE_REGISTRY, so that keys only set in the
+// environment are also unmarshalled.
+func bindConfigEnv(schema *configKey, prefix string) {
+	for _, key := range schema.Keys {
+		name := joinKey(prefix, key.Name)
+		if key.Type == schemaObject {
+			bindConfigEnv(&key, name)
+			continue
+		}
+		viper.BindEnv(name)
+	}
+}
+
+// Gets the environment variable that overrides a config key.
+func GetConfigEnvVar(key string) string {
+	return ConfigEnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+func recordConfigOrigins(values map[string]interface{}, prefix string, file string) {
+	for name, value := range values {
+		key := strings.ToLower(joinKey(prefix, name))
+		if nested, ok := value.(map[string]interface{}); ok {
+			recordConfigOrigins(nested, key, file)
+			continue
+		}
+		configOrigins[key] = file
+	}
+}
+
+// Gets where the effective value of a config key comes from: an environment
+// variable, a config file or a default.
+func GetConfigOrigin(key string) string {
+	envVar := GetConfigEnvVar(key)
+	if _, found := os.LookupEnv(envVar); found {
+		return "env:" + envVar
+	}
+	if file, found := configOrigins[strings.ToLower(key)]; found {
+		return file
+	}
+	return ConfigOriginDefault
+}
+
+// Loads and merges the system, user and project config files followed by
+// HC_ prefixed environment variables, later layers overriding earlier ones.
+// The user config file must exist when it is given explicitly.
+func LoadConfigLayers(userFile string, explicit bool) error {
+	viper.SetEnvPrefix(ConfigEnvPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.AutomaticEnv()
+	bindConfigEnv(&configSchema, "")
+
+	layers := []ConfigLayer{
+		{Name: ConfigLayerSystem, File: SystemConfigFile},
+		{Name: ConfigLayerUser, File: userFile},
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		project := FindProjectConfig(cwd)
+		if len(project) > 0 && !isSameFile(project, userFile) && !isSameFile(project, SystemConfigFile) {
+			layers = append(layers, ConfigLayer{Name: ConfigLayerProject, File: project})
+		}
+	}
+
+	configLayers = nil
+	ignoredProjectKeys = nil
+	for _, layer := range layers {
+		content, err := os.ReadFile(layer.File)
+		if errors.Is(err, os.ErrNotExist) && !(explicit && layer.Name == ConfigLayerUser) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		configLayers = append(configLayers, layer)
+
+		var values map[string]interface{}
+		if err := yaml.Unmarshal(content, &values); err != nil {
+			// Syntax errors are reported by the validation with line numbers
+			continue
+		}
+		// A project config comes with the directory it is in, e.g. a cloned
+		// repository, and must not reach the host or the OCM token
+		if layer.Name == ConfigLayerProject && !isProjectConfigTrusted() {
+			ignoredProjectKeys = removeTrustedOnlyKeys(&configSchema, values, "")
+		}
+		recordConfigOrigins(values, "", layer.File)
+		if err := viper.MergeConfigMap(values); err != nil {
+			return fmt.Errorf("failed to merge %s: %w", layer.File, err)
+		}
+	}
+
+	if len(configLayers) == 0 {
+		return fmt.Errorf("no config file found, expected one of %s, %s or %s in a parent directory", SystemConfigFile, userFile, ProjectConfigFileName)
+	}
+	return nil
+}
+
+func newConfigDocumentNode(schema *configKey, prefix string, withOrigin bool) (*yaml.Node, error) {
+	mapping := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, key := range schema.Keys {
+		name := joinKey(prefix, key.Name)
+		if !viper.IsSet(name) {
+			continue
+		}
+
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key.Name}
+		var value *yaml.Node
+		if key.Type == schemaObject {
+			var err error
+			value, err = newConfigDocumentNode(&key, name, withOrigin)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			value = &yaml.Node{}
+			if err := value.Encode(viper.Get(name)); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			canonicalizeConfigNode(&key, value)
+			// Comments of block sequences are only kept on their key
+			if withOrigin && value.Kind == yaml.ScalarNode {
+				value.LineComment = GetConfigOrigin(name)
+			} else if withOrigin {
+				keyNode.LineComment = GetConfigOrigin(name)
+			}
+		}
+		mapping.Content = append(mapping.Content, keyNode, value)
+	}
+	return mapping, nil
+}
+
+// Renames the keys of the mappings in a value to their schema names, in
+// schema order. Viper lowercases the keys of mappings nested in lists.
+func canonicalizeConfigNode(schema *configKey, node *yaml.Node) {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		if schema.Items == nil {
+			return
+		}
+		for _, item := range node.Content {
+			canonicalizeConfigNode(schema.Items, item)
+		}
+	case yaml.MappingNode:
+		content := []*yaml.Node{}
+		unknown := []*yaml.Node{}
+		for _, child := range schema.Keys {
+			for idx := 0; idx+1 < len(node.Content); idx += 2 {
+				if strings.EqualFold(node.Content[idx].Value, child.Name) {
+					node.Content[idx].Value = child.Name
+					canonicalizeConfigNode(&child, node.Content[idx+1])
+					content = append(content, node.Content[idx], node.Content[idx+1])
+				}
+			}
+		}
+		for idx := 0; idx+1 < len(node.Content); idx += 2 {
+			if schema.findKey(node.Content[idx].Value) == nil {
+				unknown = append(unknown, node.Content[idx], node.Content[idx+1])
+			}
+		}
+		node.Content = append(content, unknown...)
+	}
+}
+
+// Gets the effective value of a config key as a yaml node, with the keys of
+// its mappings named as in the schema.
+func GetConfigValueNode(keyPath string) (*yaml.Node, error) {
+	schema, err := lookupConfigKey(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	if schema.Type == schemaObject {
+		return newConfigDocumentNode(schema, keyPath, false)
+	}
+	value := &yaml.Node{}
+	if err := value.Encode(viper.Get(keyPath)); err != nil {
+		return nil, fmt.Errorf("%s: %w", keyPath, err)
+	}
+	canonicalizeConfigNode(schema, value)
+	return value, nil
+}
+
+// Gets the effective, merged config as a yaml document in schema order,
+// optionally with the origin of each value as a line comment.
+func GetEffectiveConfigDocument(withOrigin bool) (*yaml.Node, error) {
+	mapping, err := newConfigDocumentNode(&configSchema, "", withOrigin)
+	if err != nil {
+		return nil, err
+	}
+	return &yaml.Node{
+		Kind:    yaml.DocumentNode,
+		Content: []*yaml.Node{mapping},
+	}, nil
+}
@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// Changes to a directory for the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(cwd) })
+}
+
+// A project config, e.g. of a cloned repository, only sets the keys that do
+// not reach the host or the OCM token unless it is trusted.
+func TestLoadConfigLayersProject(t *testing.T) {
+	userFile := writeConfigFile(t, "hostUser: me\nimage:\n  repository: hc\n")
+	project := t.TempDir()
+	content := `shell: zsh
+ocmCLIAlias:
+  production: ./ocm.sh
+ocmLongLivedTokenPath: /tmp/token
+shellRcFile: ./rc
+image:
+  registry: quay.io/attacker
+customDirMaps:
+  - hostDir: /
+    containerDir: /host
+    fileAttrs: rw
+persistentState:
+  enabled: true
+  dir: /tmp/state
+sessionRecording:
+  dir: /tmp/sessions
+`
+	if err := os.WriteFile(filepath.Join(project, ProjectConfigFileName), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, project)
+	t.Cleanup(viper.Reset)
+
+	viper.Reset()
+	if err := LoadConfigLayers(userFile, true); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"customDirMaps",
+		"image",
+		"ocmCLIAlias",
+		"ocmLongLivedTokenPath",
+		"persistentState.dir",
+		"sessionRecording.dir",
+		"shellRcFile",
+	}
+	if keys := GetIgnoredProjectKeys(); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("ignored %v, expected %v", keys, expected)
+	}
+	for _, key := range expected {
+		if viper.IsSet(key) && key != "image" {
+			t.Errorf("%s of the project config was merged", key)
+		}
+	}
+	if registry := viper.GetString("image.registry"); len(registry) > 0 {
+		t.Errorf("image registry %s of the project config was merged", registry)
+	}
+	if viper.GetString("image.repository") != "hc" || viper.GetString("shell") != "zsh" || !viper.GetBool("persistentState.enabled") {
+		t.Errorf("other keys were not merged: %v", viper.AllSettings())
+	}
+
+	viper.Reset()
+	t.Setenv(TrustProjectConfigEnvVar, "true")
+	if err := LoadConfigLayers(userFile, true); err != nil {
+		t.Fatal(err)
+	}
+	if keys := GetIgnoredProjectKeys(); len(keys) > 0 {
+		t.Errorf("ignored %v of a trusted project config", keys)
+	}
+	if viper.GetString("ocmCLIAlias.production") != "./ocm.sh" || viper.GetString("image.registry") != "quay.io/attacker" {
+		t.Errorf("trusted project config was not merged: %v", viper.AllSettings())
+	}
+}
@@ -0,0 +1,121 @@
+package ocm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// A cluster of the clusters_mgmt API.
+type Cluster struct {
+	ID               string `json:"id"`
+	Name             string `json:"name"`
+	ExternalID       string `json:"external_id"`
+	State            string `json:"state"`
+	OpenshiftVersion string `json:"openshift_version"`
+	Version          struct {
+		RawID string `json:"raw_id"`
+	} `json:"version"`
+	Region struct {
+		ID string `json:"id"`
+	} `json:"region"`
+	Product struct {
+		ID string `json:"id"`
+	} `json:"product"`
+	CloudProvider struct {
+		ID string `json:"id"`
+	} `json:"cloud_provider"`
+	API struct {
+		URL string `json:"url"`
+	} `json:"api"`
+	Console struct {
+		URL string `json:"url"`
+	} `json:"console"`
+	Subscription struct {
+		ID string `json:"id"`
+	} `json:"subscription"`
+}
+
+// Gets the OpenShift version of a cluster.
+func (c *Cluster) GetVersion() string {
+	if len(c.OpenshiftVersion) > 0 {
+		return c.OpenshiftVersion
+	}
+	return c.Version.RawID
+}
+
+// An account of the accounts_mgmt API.
+type Account struct {
+	ID           string `json:"id"`
+	Username     string `json:"username"`
+	Email        string `json:"email"`
+	FirstName    string `json:"first_name"`
+	LastName     string `json:"last_name"`
+	Organization struct {
+		ID         string `json:"id"`
+		Name       string `json:"name"`
+		ExternalID string `json:"external_id"`
+	} `json:"organization"`
+}
+
+// An organization of the accounts_mgmt API.
+type Organization struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	ExternalID string `json:"external_id"`
+	EbsAccount string `json:"ebs_account_id"`
+}
+
+type clusterList struct {
+	Items []Cluster `json:"items"`
+	Total int       `json:"total"`
+}
+
+// Searches clusters with a search expression, e.g. "name like 'abc%'".
+func (c *Client) SearchClusters(ctx context.Context, search string, size int) ([]Cluster, error) {
+	query := url.Values{"search": {search}}
+	if size > 0 {
+		query.Set("size", strconv.Itoa(size))
+	}
+	var list clusterList
+	if err := c.request(ctx, http.MethodGet, "/api/clusters_mgmt/v1/clusters", query, nil, &list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+func (c *Client) GetCluster(ctx context.Context, id string) (*Cluster, error) {
+	var cluster Cluster
+	if err := c.request(ctx, http.MethodGet, "/api/clusters_mgmt/v1/clusters/"+url.PathEscape(id), nil, nil, &cluster); err != nil {
+		return nil, err
+	}
+	return &cluster, nil
+}
+
+// Gets the pull secret of the current account, as returned by the
+// access_token call.
+func (c *Client) GetAccessToken(ctx context.Context) (json.RawMessage, error) {
+	var pullSecret json.RawMessage
+	if err := c.request(ctx, http.MethodPost, "/api/accounts_mgmt/v1/access_token", nil, nil, &pullSecret); err != nil {
+		return nil, err
+	}
+	return pullSecret, nil
+}
+
+func (c *Client) GetCurrentAccount(ctx context.Context) (*Account, error) {
+	var account Account
+	if err := c.request(ctx, http.MethodGet, "/api/accounts_mgmt/v1/current_account", nil, nil, &account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (c *Client) GetOrganization(ctx context.Context, id string) (*Organization, error) {
+	var organization Organization
+	if err := c.request(ctx, http.MethodGet, "/api/accounts_mgmt/v1/organizations/"+url.PathEscape(id), nil, nil, &organization); err != nil {
+		return nil, err
+	}
+	return &organization, nil
+}
@@ -0,0 +1,368 @@
+package ocm
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ProductionURL  = "https://api.openshift.com"
+	StagingURL     = "https://api.stage.openshift.com"
+	IntegrationURL = "https://api.integration.openshift.com"
+
+	// SSO endpoint and client that the ocm CLI exchanges tokens with
+	DefaultTokenURL = "https://sso.redhat.com/auth/realms/redhat-external/protocol/openid-connect/token"
+	DefaultClientID = "cloud-services"
+
+	defaultTimeout   = 30 * time.Second
+	defaultRetries   = 3
+	defaultRetryWait = 500 * time.Millisecond
+
+	// Access tokens are refreshed once they expire within this period
+	tokenExpiryMargin = time.Minute
+)
+
+// Gets the API URL of an OCM environment.
+func GetEnvironmentURL(environment string) (string, error) {
+	switch environment {
+	case "production", "":
+		return ProductionURL, nil
+	case "staging":
+		return StagingURL, nil
+	case "integration":
+		return IntegrationURL, nil
+	}
+	return "", fmt.Errorf("unknown OCM environment: %s", environment)
+}
+
+// Options of an OCM client. Either an access token or a refresh token, e.g. an
+// offline token, is required.
+type Options struct {
+	// API URL, by default the production API
+	URL string
+	// SSO token endpoint, by default the Red Hat SSO
+	TokenURL     string
+	ClientID     string
+	AccessToken  string
+	RefreshToken string
+	// Timeout of each request, by default 30s
+	Timeout time.Duration
+	// Retries of requests that fail with a network error, 429 or 5xx
+	Retries   int
+	RetryWait time.Duration
+	// HTTP client used for requests, e.g. to reach an httptest server
+	HTTPClient *http.Client
+	// Called with the new tokens after the refresh token was exchanged, e.g.
+	// to save them
+	OnTokenRefresh func(accessToken string, refreshToken string)
+}
+
+// A client of the OCM REST API.
+type Client struct {
+	url          string
+	tokenURL     string
+	clientID     string
+	retries      int
+	retryWait    time.Duration
+	httpClient   *http.Client
+	onRefresh    func(accessToken string, refreshToken string)
+	mutex        sync.Mutex
+	accessToken  string
+	refreshToken string
+	tokenExpiry  time.Time
+}
+
+func NewClient(options Options) (*Client, error) {
+	if len(options.AccessToken) == 0 && len(options.RefreshToken) == 0 {
+		return nil, errors.New("an OCM access token or refresh token is required")
+	}
+
+	client := &Client{
+		url:          strings.TrimSuffix(options.URL, "/"),
+		tokenURL:     options.TokenURL,
+		clientID:     options.ClientID,
+		retries:      options.Retries,
+		retryWait:    options.RetryWait,
+		httpClient:   options.HTTPClient,
+		onRefresh:    options.OnTokenRefresh,
+		accessToken:  options.AccessToken,
+		refreshToken: options.RefreshToken,
+	}
+	if len(client.url) == 0 {
+		client.url = ProductionURL
+	}
+	if len(client.tokenURL) == 0 {
+		client.tokenURL = DefaultTokenURL
+	}
+	if len(client.clientID) == 0 {
+		client.clientID = DefaultClientID
+	}
+	if client.retries == 0 {
+		client.retries = defaultRetries
+	}
+	if client.retryWait == 0 {
+		client.retryWait = defaultRetryWait
+	}
+	if client.httpClient == nil {
+		timeout := options.Timeout
+		if timeout == 0 {
+			timeout = defaultTimeout
+		}
+		client.httpClient = &http.Client{Timeout: timeout}
+	}
+	if len(client.accessToken) > 0 {
+		client.tokenExpiry = GetTokenExpiry(client.accessToken)
+	}
+	return client, nil
+}
+
+func (c *Client) URL() string {
+	return c.url
+}
+
+// Gets the expiry of a JWT, zero if it cannot be decoded. The token is not
+// verified.
+func GetTokenExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.Exp, 0)
+}
+
+func (c *Client) isTokenValid() bool {
+	if len(c.accessToken) == 0 {
+		return false
+	}
+	// Tokens without a known expiry are used until they are rejected
+	return c.tokenExpiry.IsZero() || time.Until(c.tokenExpiry) > tokenExpiryMargin
+}
+
+// Gets a valid access token, exchanging the refresh token for a new one at the
+// SSO endpoint when needed.
+func (c *Client) Token(ctx context.Context) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.isTokenValid() {
+		return c.accessToken, nil
+	}
+	if len(c.refreshToken) == 0 {
+		return "", errors.New("the OCM access token expired and there is no refresh token")
+	}
+
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {c.clientID},
+		"refresh_token": {c.refreshToken},
+	}
+	var tokens struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+	}
+	err := c.do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tokenURL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
+	}, &tokens)
+	if err != nil {
+		return "", fmt.Errorf("failed to exchange the OCM refresh token: %w", err)
+	}
+	if len(tokens.AccessToken) == 0 {
+		return "", errors.New("failed to exchange the OCM refresh token: no access token returned")
+	}
+
+	c.accessToken = tokens.AccessToken
+	if len(tokens.RefreshToken) > 0 {
+		c.refreshToken = tokens.RefreshToken
+	}
+	c.tokenExpiry = GetTokenExpiry(c.accessToken)
+	if c.tokenExpiry.IsZero() && tokens.ExpiresIn > 0 {
+		c.tokenExpiry = time.Now().Add(time.Duration(tokens.ExpiresIn) * time.Second)
+	}
+	if c.onRefresh != nil {
+		c.onRefresh(c.accessToken, c.refreshToken)
+	}
+	return c.accessToken, nil
+}
+
+// Checks whether a failed request may succeed when retried.
+func isRetryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// Sends a request, retrying it on network errors, 429 and 5xx, and decodes
+// the JSON response into out unless it is nil. The request is built anew for
+// each attempt so that its body can be read again.
+func (c *Client) do(ctx context.Context, newRequest func() (*http.Request, error), out interface{}) error {
+	var err error
+	for attempt := 0; attempt <= c.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(c.retryWait * time.Duration(1<<(attempt-1))):
+			}
+		}
+
+		var req *http.Request
+		req, err = newRequest()
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "application/json")
+
+		var resp *http.Response
+		resp, err = c.httpClient.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			continue
+		}
+
+		var body []byte
+		body, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			continue
+		}
+		if resp.StatusCode >= http.StatusBadRequest {
+			err = newError(resp.StatusCode, body)
+			if isRetryable(resp.StatusCode) {
+				continue
+			}
+			return err
+		}
+		if out == nil || len(body) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(body, out); err != nil {
+			return fmt.Errorf("invalid response from %s: %w", req.URL.Path, err)
+		}
+		return nil
+	}
+	return err
+}
+
+// Sends an authenticated request to the API.
+func (c *Client) request(ctx context.Context, method string, path string, query url.Values, body interface{}, out interface{}) error {
+	var content []byte
+	if body != nil {
+		var err error
+		if content, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	endpoint := c.url + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	refreshed := false
+	for {
+		token, err := c.Token(ctx)
+		if err != nil {
+			return err
+		}
+		err = c.do(ctx, func() (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(content))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			if body != nil {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			return req, nil
+		}, out)
+
+		// A token rejected before its expiry is exchanged once
+		var apiErr *Error
+		if errors.As(err, &apiErr) && apiErr.Status == http.StatusUnauthorized && !refreshed && c.expireToken() {
+			refreshed = true
+			continue
+		}
+		return err
+	}
+}
+
+// Drops the access token so that the next request exchanges the refresh token.
+// Returns false if there is no refresh token to exchange.
+func (c *Client) expireToken() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(c.refreshToken) == 0 {
+		return false
+	}
+	c.accessToken = ""
+	return true
+}
+
+// An error returned by the OCM API.
+type Error struct {
+	Status      int    `json:"-"`
+	ID          string `json:"id"`
+	Code        string `json:"code"`
+	Reason      string `json:"reason"`
+	OperationID string `json:"operation_id"`
+	// Body of responses that are not OCM errors, e.g. SSO errors
+	Body string `json:"-"`
+}
+
+func newError(status int, body []byte) *Error {
+	apiErr := &Error{Status: status}
+	if err := json.Unmarshal(body, apiErr); err != nil || len(apiErr.Reason) == 0 {
+		apiErr.Body = strings.TrimSpace(string(body))
+	}
+	return apiErr
+}
+
+func (e *Error) Error() string {
+	var msg string
+	switch {
+	case len(e.Reason) > 0:
+		msg = e.Reason
+	case len(e.Body) > 0:
+		msg = e.Body
+	default:
+		msg = http.StatusText(e.Status)
+	}
+	if len(e.Code) > 0 {
+		msg = fmt.Sprintf("%s: %s", e.Code, msg)
+	}
+	if len(e.OperationID) > 0 {
+		msg += " (operation id " + e.OperationID + ")"
+	}
+	return "OCM API error " + strconv.Itoa(e.Status) + ": " + msg
+}
+
+func IsNotFound(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound
+}
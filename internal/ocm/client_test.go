@@ -0,0 +1,162 @@
+package ocm
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Writes an ocm CLI config logged in to a fake server, with a value that hc
+// does not know of.
+func writeFakeConfig(t *testing.T, fake *FakeServer) string {
+	t.Helper()
+	config := fake.GetConfig()
+	content, err := json.Marshal(map[string]interface{}{
+		"access_token":  config.AccessToken,
+		"refresh_token": config.RefreshToken,
+		"url":           config.URL,
+		"token_url":     config.TokenURL,
+		"client_id":     config.ClientID,
+		"pager":         "less",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "ocm.json")
+	if err := os.WriteFile(file, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestClientClusters(t *testing.T) {
+	fake := NewFakeServer(
+		NewFakeCluster("1a2b3c", "my-cluster", "us-east-1"),
+		NewFakeCluster("4d5e6f", "my-other-cluster", "eu-west-1"),
+		NewFakeCluster("7g8h9i", "staging-cluster", "us-east-1"),
+	)
+	defer fake.Close()
+	client, err := NewClientFromConfig(writeFakeConfig(t, fake), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	cluster, err := client.GetCluster(ctx, "4d5e6f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cluster.Name != "my-other-cluster" || cluster.Region.ID != "eu-west-1" || cluster.GetVersion() != "4.14.1" {
+		t.Errorf("unexpected cluster: %+v", cluster)
+	}
+	if _, err := client.GetCluster(ctx, "unknown"); !IsNotFound(err) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+
+	tests := []struct {
+		search   string
+		size     int
+		expected []string
+	}{
+		{search: "id = 'my-cluster' or name = 'my-cluster' or external_id = 'my-cluster'", expected: []string{"1a2b3c"}},
+		{search: "external_id = 'ext-7g8h9i'", expected: []string{"7g8h9i"}},
+		{search: "name like '%cluster%'", expected: []string{"1a2b3c", "4d5e6f", "7g8h9i"}},
+		{search: "name like '%cluster%'", size: 2, expected: []string{"1a2b3c", "4d5e6f"}},
+		{search: "region.id = 'us-east-1' and name like 'my-%'", expected: []string{"1a2b3c"}},
+		{search: "name = 'unknown'", expected: []string{}},
+	}
+	for _, test := range tests {
+		clusters, err := client.SearchClusters(ctx, test.search, test.size)
+		if err != nil {
+			t.Fatalf("%s: %v", test.search, err)
+		}
+		ids := []string{}
+		for _, cluster := range clusters {
+			ids = append(ids, cluster.ID)
+		}
+		if !reflect.DeepEqual(ids, test.expected) {
+			t.Errorf("%s: found %v, expected %v", test.search, ids, test.expected)
+		}
+	}
+}
+
+// A rejected access token is refreshed once, and the new tokens are saved to
+// the config file so that the next client uses them right away.
+func TestClientTokenRefresh(t *testing.T) {
+	fake := NewFakeServer(NewFakeCluster("1a2b3c", "my-cluster", "us-east-1"))
+	defer fake.Close()
+	file := writeFakeConfig(t, fake)
+	ctx := context.Background()
+
+	client, err := NewClientFromConfig(file, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetCluster(ctx, "1a2b3c"); err != nil {
+		t.Fatal(err)
+	}
+	if refreshes := fake.GetRefreshes(); refreshes != 1 {
+		t.Fatalf("refreshed %d times, expected once", refreshes)
+	}
+
+	accessToken, refreshToken := fake.GetTokens()
+	config, err := LoadConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.AccessToken != accessToken || config.RefreshToken != refreshToken {
+		t.Errorf("saved tokens %s and %s, expected %s and %s", config.AccessToken, config.RefreshToken, accessToken, refreshToken)
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal(content, &values); err != nil {
+		t.Fatal(err)
+	}
+	if values["pager"] != "less" || values["url"] != fake.URL {
+		t.Errorf("other values of the config were not kept: %s", content)
+	}
+	if info, err := os.Stat(file); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("config is not private: %v %v", info.Mode(), err)
+	}
+
+	client, err = NewClientFromConfig(file, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.SearchClusters(ctx, "name = 'my-cluster'", 1); err != nil {
+		t.Fatal(err)
+	}
+	if refreshes := fake.GetRefreshes(); refreshes != 1 {
+		t.Errorf("the saved token was refreshed again, %d refreshes", refreshes)
+	}
+}
+
+// The URL given to the client wins over the URL the ocm CLI logged in to.
+func TestClientFromConfigURL(t *testing.T) {
+	production := NewFakeServer(NewFakeCluster("1a2b3c", "my-cluster", "us-east-1"))
+	defer production.Close()
+	staging := NewFakeServer(NewFakeCluster("4d5e6f", "my-cluster", "us-east-1"))
+	defer staging.Close()
+
+	client, err := NewClientFromConfig(writeFakeConfig(t, production), staging.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.URL() != staging.URL {
+		t.Errorf("client of %s, expected %s", client.URL(), staging.URL)
+	}
+	// The token of the production SSO is rejected, only the URL matters
+	_, _ = client.SearchClusters(context.Background(), "name = 'my-cluster'", 1)
+	if requests := production.GetRequests(); len(requests) > 0 {
+		t.Errorf("requests sent to the API of the config: %v", requests)
+	}
+	if requests := staging.GetRequests(); len(requests) == 0 {
+		t.Error("no request sent to the given API")
+	}
+}
@@ -0,0 +1,107 @@
+package ocm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	logger "github.com/sirupsen/logrus"
+)
+
+// Config saved by ocm login.
+type Config struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	URL          string `json:"url"`
+	TokenURL     string `json:"token_url"`
+	ClientID     string `json:"client_id"`
+}
+
+// Gets the ocm CLI config file, $OCM_CONFIG or ~/.config/ocm/ocm.json.
+func GetConfigFile() string {
+	if env := os.Getenv("OCM_CONFIG"); len(env) > 0 {
+		return env
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "ocm", "ocm.json")
+}
+
+func LoadConfig(file string) (*Config, error) {
+	content, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errors.New("not logged in to OCM, run \"ocm login\"")
+	}
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("invalid OCM config %s: %w", file, err)
+	}
+	return &config, nil
+}
+
+// Saves the tokens of a refresh to the config file, as the ocm CLI does, so
+// that the next client does not exchange the refresh token again. The other
+// values of the file are kept.
+func SaveTokens(file string, accessToken string, refreshToken string) error {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	values := map[string]json.RawMessage{}
+	if err := json.Unmarshal(content, &values); err != nil {
+		return fmt.Errorf("invalid OCM config %s: %w", file, err)
+	}
+	for key, token := range map[string]string{"access_token": accessToken, "refresh_token": refreshToken} {
+		if values[key], err = json.Marshal(token); err != nil {
+			return err
+		}
+	}
+	if content, err = json.MarshalIndent(values, "", "  "); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(content, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// Creates a client logged in as the ocm CLI is, to the API at url, or at the
+// URL of the config if empty. Refreshed tokens are saved to the config file.
+func NewClientFromConfig(file string, url string) (*Client, error) {
+	config, err := LoadConfig(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(url) == 0 {
+		url = config.URL
+	}
+	return NewClient(Options{
+		URL:          url,
+		TokenURL:     config.TokenURL,
+		ClientID:     config.ClientID,
+		AccessToken:  config.AccessToken,
+		RefreshToken: config.RefreshToken,
+		OnTokenRefresh: func(accessToken string, refreshToken string) {
+			if err := SaveTokens(file, accessToken, refreshToken); err != nil {
+				logger.Warnf("Failed to save the refreshed OCM token to %s: %v", file, err)
+			}
+		},
+	})
+}
@@ -0,0 +1,198 @@
+package ocm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Terms of the search expressions understood by the fake server, e.g.
+// "name like 'abc%'"
+var fakeSearchTerm = regexp.MustCompile(`^\(?\s*([a-z_.]+)\s+(=|like)\s+'([^']*)'\s*\)?$`)
+
+// An OCM API and SSO token endpoint for tests. It serves the clusters it is
+// given to the holder of its access token, and exchanges its refresh token
+// for a new pair of tokens.
+type FakeServer struct {
+	*httptest.Server
+	mutex        sync.Mutex
+	clusters     []Cluster
+	accessToken  string
+	refreshToken string
+	refreshes    int
+	requests     []string
+}
+
+func NewFakeServer(clusters ...Cluster) *FakeServer {
+	fake := &FakeServer{
+		clusters:     clusters,
+		accessToken:  "access-0",
+		refreshToken: "refresh-0",
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", fake.serveToken)
+	mux.HandleFunc("/api/clusters_mgmt/v1/clusters", fake.authorized(fake.serveClusterSearch))
+	mux.HandleFunc("/api/clusters_mgmt/v1/clusters/", fake.authorized(fake.serveCluster))
+	mux.HandleFunc("/api/accounts_mgmt/v1/access_token", fake.authorized(func(w http.ResponseWriter, r *http.Request) {
+		fake.writeJSON(w, http.StatusOK, map[string]interface{}{"auths": map[string]interface{}{}})
+	}))
+	fake.Server = httptest.NewServer(mux)
+	return fake
+}
+
+// Gets a ready OSD cluster for tests, of external id "ext-" + id.
+func NewFakeCluster(id string, name string, region string) Cluster {
+	cluster := Cluster{ID: id, Name: name, ExternalID: "ext-" + id, State: "ready", OpenshiftVersion: "4.14.1"}
+	cluster.Region.ID = region
+	cluster.Product.ID = "osd"
+	return cluster
+}
+
+// Gets a config of the ocm CLI logged in to the server, with an access token
+// that the server rejects so that the client refreshes it first.
+func (f *FakeServer) GetConfig() *Config {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return &Config{
+		AccessToken:  "stale",
+		RefreshToken: f.refreshToken,
+		URL:          f.URL,
+		TokenURL:     f.URL + "/token",
+		ClientID:     DefaultClientID,
+	}
+}
+
+// Gets the tokens currently accepted by the server.
+func (f *FakeServer) GetTokens() (string, string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.accessToken, f.refreshToken
+}
+
+// Gets the number of times the refresh token was exchanged.
+func (f *FakeServer) GetRefreshes() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.refreshes
+}
+
+// Gets the API requests received, e.g. "GET /api/clusters_mgmt/v1/clusters".
+func (f *FakeServer) GetRequests() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]string{}, f.requests...)
+}
+
+func (f *FakeServer) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (f *FakeServer) writeError(w http.ResponseWriter, status int, reason string) {
+	f.writeJSON(w, status, &Error{ID: strconv.Itoa(status), Code: "FAKE-" + strconv.Itoa(status), Reason: reason})
+}
+
+func (f *FakeServer) serveToken(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if r.Method != http.MethodPost || r.FormValue("grant_type") != "refresh_token" || r.FormValue("refresh_token") != f.refreshToken {
+		f.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	f.refreshes++
+	f.accessToken = fmt.Sprintf("access-%d", f.refreshes)
+	f.refreshToken = fmt.Sprintf("refresh-%d", f.refreshes)
+	f.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token":  f.accessToken,
+		"refresh_token": f.refreshToken,
+		"expires_in":    900,
+	})
+}
+
+// Records a request and serves it if it holds the access token.
+func (f *FakeServer) authorized(serve http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f.mutex.Lock()
+		f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+		valid := r.Header.Get("Authorization") == "Bearer "+f.accessToken
+		f.mutex.Unlock()
+		if !valid {
+			f.writeError(w, http.StatusUnauthorized, "Invalid token")
+			return
+		}
+		serve(w, r)
+	}
+}
+
+func (f *FakeServer) serveCluster(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/clusters_mgmt/v1/clusters/")
+	for idx := range f.clusters {
+		if f.clusters[idx].ID == id {
+			f.writeJSON(w, http.StatusOK, &f.clusters[idx])
+			return
+		}
+	}
+	f.writeError(w, http.StatusNotFound, fmt.Sprintf("Cluster '%s' not found", id))
+}
+
+func (f *FakeServer) serveClusterSearch(w http.ResponseWriter, r *http.Request) {
+	size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+	list := clusterList{Items: []Cluster{}}
+	for idx := range f.clusters {
+		matches, err := matchFakeSearch(&f.clusters[idx], r.URL.Query().Get("search"))
+		if err != nil {
+			f.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if matches && (size == 0 || len(list.Items) < size) {
+			list.Items = append(list.Items, f.clusters[idx])
+		}
+	}
+	list.Total = len(list.Items)
+	f.writeJSON(w, http.StatusOK, &list)
+}
+
+// Matches a cluster against a search expression made of terms joined with
+// "or" and "and", without parentheses around several terms.
+func matchFakeSearch(cluster *Cluster, search string) (bool, error) {
+	if len(strings.TrimSpace(search)) == 0 {
+		return true, nil
+	}
+	fields := map[string]string{
+		"id":          cluster.ID,
+		"name":        cluster.Name,
+		"external_id": cluster.ExternalID,
+		"state":       cluster.State,
+		"region.id":   cluster.Region.ID,
+		"product.id":  cluster.Product.ID,
+	}
+	for _, alternative := range strings.Split(search, " or ") {
+		matches := true
+		for _, term := range strings.Split(alternative, " and ") {
+			parts := fakeSearchTerm.FindStringSubmatch(strings.TrimSpace(term))
+			if parts == nil {
+				return false, fmt.Errorf("unsupported search term: %s", term)
+			}
+			value, found := fields[parts[1]]
+			if !found {
+				return false, fmt.Errorf("unsupported search field: %s", parts[1])
+			}
+			if parts[2] == "=" {
+				matches = matches && value == parts[3]
+				continue
+			}
+			pattern := "^" + strings.ReplaceAll(regexp.QuoteMeta(parts[3]), "%", ".*") + "$"
+			matches = matches && regexp.MustCompile(pattern).MatchString(value)
+		}
+		if matches {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,294 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	pkgIntHelper "hc/internal/helpers"
+	pkgIntOcm "hc/internal/ocm"
+)
+
+// Cluster lookups are cached for this long, names and ids rarely change
+const ocmClusterCacheMaxAge = 24 * time.Hour
+
+// A cluster as known by OCM.
+type OcmCluster struct {
+	Name       string `json:"name"`
+	ID         string `json:"id"`
+	ExternalID string `json:"external_id"`
+	State      string `json:"state"`
+	Version    string `json:"version"`
+	Region     string `json:"region"`
+	Product    string `json:"product"`
+}
+
+func newOcmCluster(cluster *pkgIntOcm.Cluster) OcmCluster {
+	return OcmCluster{
+		Name:       cluster.Name,
+		ID:         cluster.ID,
+		ExternalID: cluster.ExternalID,
+		State:      cluster.State,
+		Version:    cluster.GetVersion(),
+		Region:     cluster.Region.ID,
+		Product:    cluster.Product.ID,
+	}
+}
+
+// Talks to OCM in an OCM environment, through the script that wraps the OCM
+// CLI of the environment if there is one, otherwise through the OCM API as
+// the ocm CLI is logged in.
+type OcmCli struct {
+	Environment string
+	// API URL of the environment, empty if the environment is unknown
+	URL string
+	// Script that wraps the OCM CLI of the environment, empty to run ocm
+	Alias string
+}
+
+func NewOcmCli(environment string, alias OcmCliAlias) *OcmCli {
+	cli := &OcmCli{Environment: environment}
+	cli.URL, _ = pkgIntOcm.GetEnvironmentURL(environment)
+	switch environment {
+	case "production":
+		cli.Alias = alias.OcmProduction
+	case "staging":
+		cli.Alias = alias.OcmStaging
+	}
+	return cli
+}
+
+// Runs the ocm CLI, or its alias.
+func (o *OcmCli) Output(args ...string) ([]byte, error) {
+	command := pkgIntHelper.NewCommand("ocm", args...)
+	if len(o.Alias) > 0 {
+		command = pkgIntHelper.NewCommand("sh", append([]string{o.Alias}, args...)...)
+	}
+	command.Timeout = ocmRequestTimeout
+	result, err := pkgIntHelper.Run(context.Background(), command)
+	if err != nil {
+		return nil, err
+	}
+	return result.Stdout, nil
+}
+
+// Timeout of OCM API calls, including retries
+const ocmRequestTimeout = time.Minute
+
+func (o *OcmCli) newClient() (*pkgIntOcm.Client, error) {
+	if len(o.URL) == 0 {
+		return nil, fmt.Errorf("unknown OCM environment: %s", o.Environment)
+	}
+	return pkgIntOcm.NewClientFromConfig(pkgIntOcm.GetConfigFile(), o.URL)
+}
+
+// Gets the pull secret of the current account.
+func (o *OcmCli) GetAccessToken() ([]byte, error) {
+	if len(o.Alias) > 0 {
+		return o.Output("post", "/api/accounts_mgmt/v1/access_token")
+	}
+
+	client, err := o.newClient()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ocmRequestTimeout)
+	defer cancel()
+	return client.GetAccessToken(ctx)
+}
+
+func (o *OcmCli) searchClusters(search string, size int) ([]pkgIntOcm.Cluster, error) {
+	if len(o.Alias) == 0 {
+		client, err := o.newClient()
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), ocmRequestTimeout)
+		defer cancel()
+		return client.SearchClusters(ctx, search, size)
+	}
+
+	out, err := o.Output(
+		"get",
+		"/api/clusters_mgmt/v1/clusters",
+		"--parameter", "search="+search,
+		"--parameter", "size="+strconv.Itoa(size),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var list struct {
+		Items []pkgIntOcm.Cluster `json:"items"`
+	}
+	if err := json.Unmarshal(out, &list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// Gets the name that the cached clusters of the environment are grouped
+// under, the host of its API URL, so that environments that share a name
+// but not an API do not share clusters.
+func (o *OcmCli) getCacheScope() string {
+	if apiURL, err := url.Parse(o.URL); err == nil && len(apiURL.Host) > 0 {
+		return o.Environment + "@" + apiURL.Host
+	}
+	return o.Environment
+}
+
+func (o *OcmCli) getClusterCacheName(key string) string {
+	return fmt.Sprintf("ocm-clusters/%s/%s", o.getCacheScope(), key)
+}
+
+func (o *OcmCli) getClusterIndexCacheName() string {
+	return fmt.Sprintf("ocm-clusters/%s-index", o.getCacheScope())
+}
+
+// Gets the cached OCM lookup of a cluster, regardless of its age.
+func (o *OcmCli) GetCachedCluster(key string) (*OcmCluster, bool) {
+	var cluster OcmCluster
+	if !ReadCache(o.getClusterCacheName(key), 0, &cluster) {
+		return nil, false
+	}
+	return &cluster, true
+}
+
+// Gets the clusters found by earlier searches in the OCM environment, sorted
+// by name.
+func (o *OcmCli) GetClusterIndex() []OcmCluster {
+	clusters := []OcmCluster{}
+	ReadCache(o.getClusterIndexCacheName(), 0, &clusters)
+	return clusters
+}
+
+// Adds clusters to the cluster index of the OCM environment.
+func (o *OcmCli) updateClusterIndex(found []OcmCluster) {
+	clusters := o.GetClusterIndex()
+	for _, cluster := range found {
+		updated := false
+		for idx := range clusters {
+			if clusters[idx].ID == cluster.ID {
+				clusters[idx] = cluster
+				updated = true
+			}
+		}
+		if !updated {
+			clusters = append(clusters, cluster)
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].Name < clusters[j].Name
+	})
+	WriteCache(o.getClusterIndexCacheName(), clusters)
+}
+
+// Searches clusters with an OCM search expression, e.g. "name like 'abc%'".
+// Found clusters are added to the cluster index.
+func (o *OcmCli) SearchClusters(search string, size int) ([]OcmCluster, error) {
+	items, err := o.searchClusters(search, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search clusters: %w", err)
+	}
+	clusters := []OcmCluster{}
+	for idx := range items {
+		clusters = append(clusters, newOcmCluster(&items[idx]))
+	}
+	o.updateClusterIndex(clusters)
+	return clusters, nil
+}
+
+// Checks that a value can be quoted in an OCM search expression.
+func checkOcmSearchValue(value string) error {
+	if len(value) == 0 || strings.ContainsAny(value, "'%\\") {
+		return fmt.Errorf("invalid cluster: %q", value)
+	}
+	return nil
+}
+
+// Looks up a cluster in OCM by its name, id or external id. Lookups are
+// cached.
+func (o *OcmCli) GetCluster(key string) (*OcmCluster, error) {
+	var cluster OcmCluster
+	if ReadCache(o.getClusterCacheName(key), ocmClusterCacheMaxAge, &cluster) {
+		return &cluster, nil
+	}
+	return o.LookupCluster(key)
+}
+
+// Looks up a cluster in OCM by its name, id or external id, bypassing the
+// cache, and caches it.
+func (o *OcmCli) LookupCluster(key string) (*OcmCluster, error) {
+	if err := checkOcmSearchValue(key); err != nil {
+		return nil, err
+	}
+
+	clusters, err := o.SearchClusters(
+		fmt.Sprintf("id = '%[1]s' or name = '%[1]s' or external_id = '%[1]s'", key),
+		2,
+	)
+	if err != nil {
+		return nil, err
+	}
+	switch len(clusters) {
+	case 0:
+		return nil, fmt.Errorf("cluster %s not found", key)
+	case 1:
+	default:
+		return nil, fmt.Errorf("cluster %s matches several clusters", key)
+	}
+
+	cluster := clusters[0]
+	for _, name := range []string{key, cluster.ID} {
+		WriteCache(o.getClusterCacheName(name), &cluster)
+	}
+	return &cluster, nil
+}
+
+// Searches clusters whose name contains a query.
+func (o *OcmCli) SearchClustersByName(query string, size int) ([]OcmCluster, error) {
+	if err := checkOcmSearchValue(query); err != nil {
+		return nil, err
+	}
+	return o.SearchClusters(fmt.Sprintf("name like '%%%s%%'", query), size)
+}
+
+// Gets the cluster of the current kubeconfig context. The cluster is resolved
+// from the backplane URL of the context, or from the cluster that hc login
+// logged in to. If OCM cannot be reached, the cluster is returned with the
+// values known locally along with the error.
+func GetCurrentOcmCluster() (*OcmCluster, error) {
+	var key string
+	local := &OcmCluster{}
+	if kubeconfig, err := pkgIntHelper.LoadKubeconfig(); err == nil {
+		if cluster, err := kubeconfig.GetCurrentCluster(); err == nil {
+			key = pkgIntHelper.GetBackplaneClusterID(cluster.Cluster.Server)
+			local.ID = key
+			local.Name = pkgIntHelper.GetWorkspaceClusterName(key)
+		}
+	}
+	if len(key) == 0 {
+		key = strings.TrimSpace(os.Getenv("OCM_CLUSTER"))
+		local.Name = key
+	}
+	if len(key) == 0 {
+		return nil, errors.New("not logged in to a cluster")
+	}
+
+	cluster, err := GetContainerOcmCli().GetCluster(key)
+	if err != nil {
+		return local, err
+	}
+	return cluster, nil
+}
+
+// Gets the OCM CLI of the workspace container, logged in to its environment.
+func GetContainerOcmCli() *OcmCli {
+	return NewOcmCli(strings.TrimSpace(os.Getenv("OCM_ENVIRONMENT")), OcmCliAlias{})
+}
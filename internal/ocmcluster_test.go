@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pkgIntOcm "hc/internal/ocm"
+)
+
+// Logs the ocm CLI in to the SSO of a fake server, with the API URL of
+// another server.
+func loginFakeOcm(t *testing.T, fake *pkgIntOcm.FakeServer, apiURL string) {
+	t.Helper()
+	config := fake.GetConfig()
+	config.URL = apiURL
+	content, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "ocm.json")
+	if err := os.WriteFile(file, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OCM_CONFIG", file)
+}
+
+func TestOcmCliEnvironment(t *testing.T) {
+	for environment, url := range map[string]string{
+		"":            pkgIntOcm.ProductionURL,
+		"production":  pkgIntOcm.ProductionURL,
+		"staging":     pkgIntOcm.StagingURL,
+		"integration": pkgIntOcm.IntegrationURL,
+		"unknown":     "",
+	} {
+		if cli := NewOcmCli(environment, OcmCliAlias{}); cli.URL != url {
+			t.Errorf("environment %q has the URL %q, expected %q", environment, cli.URL, url)
+		}
+	}
+
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	if _, err := NewOcmCli("unknown", OcmCliAlias{}).GetCluster("my-cluster"); err == nil {
+		t.Error("found a cluster in an unknown environment")
+	}
+}
+
+// Clusters are looked up in the API of the environment, whatever the API the
+// ocm CLI logged in to, and cached per API.
+func TestOcmCliGetCluster(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	first := pkgIntOcm.NewFakeServer(pkgIntOcm.NewFakeCluster("1a2b3c", "my-cluster", "us-east-1"))
+	defer first.Close()
+	second := pkgIntOcm.NewFakeServer(pkgIntOcm.NewFakeCluster("4d5e6f", "my-cluster", "us-east-1"))
+	defer second.Close()
+	firstCli := &OcmCli{Environment: "staging", URL: first.URL}
+	secondCli := &OcmCli{Environment: "staging", URL: second.URL}
+
+	loginFakeOcm(t, first, second.URL)
+	cluster, err := firstCli.GetCluster("my-cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cluster.ID != "1a2b3c" {
+		t.Errorf("found the cluster %s, expected 1a2b3c", cluster.ID)
+	}
+	if requests := second.GetRequests(); len(requests) > 0 {
+		t.Errorf("requests sent to the API of the ocm CLI: %v", requests)
+	}
+
+	loginFakeOcm(t, second, first.URL)
+	if cluster, err = secondCli.GetCluster("my-cluster"); err != nil {
+		t.Fatal(err)
+	}
+	if cluster.ID != "4d5e6f" {
+		t.Errorf("found the cluster %s of another API, expected 4d5e6f", cluster.ID)
+	}
+
+	// Both lookups are cached, each for its own API
+	requests := len(first.GetRequests()) + len(second.GetRequests())
+	for cli, id := range map[*OcmCli]string{firstCli: "1a2b3c", secondCli: "4d5e6f"} {
+		if cluster, err := cli.GetCluster("my-cluster"); err != nil || cluster.ID != id {
+			t.Errorf("cached cluster %v (%v), expected %s", cluster, err, id)
+		}
+		if cached, found := cli.GetCachedCluster(id); !found || cached.Name != "my-cluster" {
+			t.Errorf("cluster %s is not cached by id", id)
+		}
+		if index := cli.GetClusterIndex(); len(index) != 1 || index[0].ID != id {
+			t.Errorf("cluster index %v, expected the cluster %s only", index, id)
+		}
+	}
+	if len(first.GetRequests())+len(second.GetRequests()) != requests {
+		t.Error("cached clusters were looked up again")
+	}
+}
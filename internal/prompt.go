@@ -0,0 +1,279 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	pkgIntHelper "hc/internal/helpers"
+	pkgIntOcm "hc/internal/ocm"
+)
+
+// Template of the workspace prompt, rendered with PromptInfo.
+const DefaultPromptTemplate = `[{{.User}} {{color "cyan" .OcmEnvironment}} {{color "green" .Cluster}} {{color "yellow" .Namespace}}` +
+	`{{if .Elevated}} {{color "red" "elevated"}}{{with .ElevationExpiresIn}} {{color "red" .}}{{end}}{{end}}` +
+	`{{if .TokenExpiring}} {{color "red" (printf "token %s" .TokenExpiresIn)}}{{end}}]`
+
+// The token is reported as expiring once it expires within this period
+const promptTokenExpiryWarning = 10 * time.Minute
+
+// Values rendered into the prompt template.
+type PromptInfo struct {
+	User           string
+	OcmEnvironment string
+	Cluster        string
+	Context        string
+	Namespace      string
+	// API server of the current context
+	Server string
+	// Whether the current context goes through the backplane proxy
+	Backplane bool
+	// Whether the current user impersonates another user, e.g. backplane-cluster-admin
+	Elevated bool
+	// Expiry of the elevation with hc elevate, zero if unknown
+	ElevationExpiry time.Time
+	// Expiry of the OCM access token, zero if unknown
+	TokenExpiry time.Time
+}
+
+// Formats the time until an expiry, rounded to minutes.
+func formatExpiresIn(expiry time.Time) string {
+	if expiry.IsZero() {
+		return ""
+	}
+	remaining := time.Until(expiry)
+	if remaining <= 0 {
+		return "expired"
+	}
+	if remaining < time.Minute {
+		return "<1m"
+	}
+	return strings.TrimSuffix(remaining.Round(time.Minute).String(), "0s")
+}
+
+// Gets the time until the OCM access token expires, rounded to minutes.
+func (p *PromptInfo) TokenExpiresIn() string {
+	return formatExpiresIn(p.TokenExpiry)
+}
+
+// Gets the time until the elevation is dropped, rounded to minutes.
+func (p *PromptInfo) ElevationExpiresIn() string {
+	if !p.Elevated {
+		return ""
+	}
+	return formatExpiresIn(p.ElevationExpiry)
+}
+
+func (p *PromptInfo) TokenExpiring() bool {
+	return !p.TokenExpiry.IsZero() && time.Until(p.TokenExpiry) < promptTokenExpiryWarning
+}
+
+// Identifies a version of a file.
+type fileStamp struct {
+	Path    string    `json:"path"`
+	ModTime time.Time `json:"modTime"`
+	Size    int64     `json:"size"`
+}
+
+func getFileStamps(paths []string) []fileStamp {
+	stamps := []fileStamp{}
+	for _, path := range paths {
+		stamp := fileStamp{Path: path}
+		if info, err := os.Stat(path); err == nil {
+			stamp.ModTime = info.ModTime()
+			stamp.Size = info.Size()
+		}
+		stamps = append(stamps, stamp)
+	}
+	return stamps
+}
+
+func isSameFileStamps(a []fileStamp, b []fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Path != b[i].Path || !a[i].ModTime.Equal(b[i].ModTime) || a[i].Size != b[i].Size {
+			return false
+		}
+	}
+	return true
+}
+
+// Prompt values parsed from files, kept until the files change.
+type promptCache struct {
+	Kubeconfig     []fileStamp `json:"kubeconfig"`
+	Context        string      `json:"context"`
+	Namespace      string      `json:"namespace"`
+	Server         string      `json:"server"`
+	Elevated       bool        `json:"elevated"`
+	OcmConfig      []fileStamp `json:"ocmConfig"`
+	OcmTokenExpiry time.Time   `json:"ocmTokenExpiry"`
+	Clusters       []fileStamp `json:"clusters"`
+	Cluster        string      `json:"cluster"`
+}
+
+// Reads the current context from the kubeconfig files.
+func (c *promptCache) readKubeconfig(files []string) {
+	c.Context, c.Namespace, c.Server, c.Elevated = "", "", "", false
+
+	kubeconfig, err := pkgIntHelper.LoadKubeconfig(files...)
+	if err != nil {
+		return
+	}
+	c.Context = kubeconfig.CurrentContext
+	c.Namespace, _ = kubeconfig.GetCurrentNamespace()
+	if cluster, err := kubeconfig.GetCurrentCluster(); err == nil {
+		c.Server = cluster.Cluster.Server
+	}
+	if user, err := kubeconfig.GetCurrentUser(); err == nil {
+		c.Elevated = len(user.User.As) > 0
+	}
+}
+
+// Reads the expiry of the OCM access token. The token is not verified.
+func (c *promptCache) readOcmConfig(file string) {
+	c.OcmTokenExpiry = time.Time{}
+	if config, err := pkgIntOcm.LoadConfig(file); err == nil {
+		c.OcmTokenExpiry = pkgIntOcm.GetTokenExpiry(config.AccessToken)
+	}
+}
+
+// Resolves the backplane cluster of the current context to its OCM name if it
+// was looked up before, or to the name it was added to the workspace with.
+func (c *promptCache) readWorkspaceCluster() {
+	c.Cluster = ""
+	id := pkgIntHelper.GetBackplaneClusterID(c.Server)
+	if len(id) == 0 {
+		return
+	}
+	if cluster, found := GetContainerOcmCli().GetCachedCluster(id); found {
+		c.Cluster = cluster.Name
+		return
+	}
+	c.Cluster = pkgIntHelper.GetWorkspaceClusterName(id)
+}
+
+func getPromptCacheFile() (string, error) {
+	dir, err := GetCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "prompt.json"), nil
+}
+
+// Gets the prompt values. Values parsed from the kubeconfig and OCM config
+// are cached until the files change, since the prompt is rendered often.
+func GetPromptInfo() *PromptInfo {
+	var cache promptCache
+	cacheFile, err := getPromptCacheFile()
+	if err == nil {
+		if content, err := os.ReadFile(cacheFile); err == nil {
+			json.Unmarshal(content, &cache)
+		}
+	}
+
+	changed := false
+	kubeconfigStamps := getFileStamps(pkgIntHelper.GetKubeconfigFiles())
+	if !isSameFileStamps(cache.Kubeconfig, kubeconfigStamps) {
+		cache.Kubeconfig = kubeconfigStamps
+		cache.readKubeconfig(pkgIntHelper.GetKubeconfigFiles())
+		changed = true
+	}
+	clustersStamps := getFileStamps([]string{pkgIntHelper.GetWorkspaceClustersFile()})
+	if changed || !isSameFileStamps(cache.Clusters, clustersStamps) {
+		cache.Clusters = clustersStamps
+		cache.readWorkspaceCluster()
+		changed = true
+	}
+	ocmConfigStamps := getFileStamps([]string{pkgIntOcm.GetConfigFile()})
+	if !isSameFileStamps(cache.OcmConfig, ocmConfigStamps) {
+		cache.OcmConfig = ocmConfigStamps
+		cache.readOcmConfig(pkgIntOcm.GetConfigFile())
+		changed = true
+	}
+
+	if changed && len(cacheFile) > 0 {
+		if content, err := json.Marshal(&cache); err == nil {
+			if err := os.MkdirAll(filepath.Dir(cacheFile), 0700); err == nil {
+				pkgIntHelper.WriteFileAtomic(cacheFile, content, 0600)
+			}
+		}
+	}
+
+	cluster := cache.Cluster
+	if len(cluster) == 0 {
+		cluster = strings.TrimSpace(os.Getenv("OCM_CLUSTER"))
+	}
+
+	info := &PromptInfo{
+		User:           strings.TrimSpace(os.Getenv("HOST_USER")),
+		OcmEnvironment: strings.TrimSpace(os.Getenv("OCM_ENVIRONMENT")),
+		Cluster:        cluster,
+		Context:        cache.Context,
+		Namespace:      cache.Namespace,
+		Server:         cache.Server,
+		Backplane:      strings.Contains(cache.Server, "/backplane/"),
+		Elevated:       cache.Elevated,
+		TokenExpiry:    cache.OcmTokenExpiry,
+	}
+	if elevation, found := GetElevation(); found && info.Elevated && elevation.Context == info.Context {
+		info.ElevationExpiry = elevation.ExpiresAt
+	}
+	return info
+}
+
+var promptColors = map[string]string{
+	"black":   "30",
+	"red":     "31",
+	"green":   "32",
+	"yellow":  "33",
+	"blue":    "34",
+	"magenta": "35",
+	"cyan":    "36",
+	"white":   "37",
+	"bold":    "1",
+}
+
+// Escape sequences are wrapped so that the shell does not count them in the
+// prompt width.
+var promptNonPrintingWrappers = map[string][2]string{
+	"bash": {"\001", "\002"},
+	"zsh":  {"%{", "%}"},
+}
+
+// Renders the prompt template for a shell, with colors if enabled.
+func RenderPrompt(tmpl string, info *PromptInfo, shell string, colors bool) (string, error) {
+	if len(tmpl) == 0 {
+		tmpl = DefaultPromptTemplate
+	}
+	wrapper := promptNonPrintingWrappers[shell]
+
+	funcs := template.FuncMap{
+		"color": func(name string, text string) (string, error) {
+			code, found := promptColors[name]
+			if !found {
+				return "", fmt.Errorf("unknown color: %s", name)
+			}
+			if !colors || len(text) == 0 {
+				return text, nil
+			}
+			return fmt.Sprintf("%s\033[%sm%s%s%s\033[0m%s", wrapper[0], code, wrapper[1], text, wrapper[0], wrapper[1]), nil
+		},
+	}
+
+	t, err := template.New("prompt").Funcs(funcs).Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := t.Execute(&out, info); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(out.String(), "\n"), nil
+}
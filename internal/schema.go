@@ -0,0 +1,537 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	schemaString  = "string"
+	schemaBoolean = "boolean"
+	schemaArray   = "array"
+	schemaObject  = "object"
+	// A string or an integer, e.g. a port
+	schemaStringOrInteger = "stringOrInteger"
+
+	// The value must be an existing path on the host
+	checkHostPath = "hostPath"
+	// The value must be an existing file under $userHome/.config/backplane
+	checkBackplaneConfig = "backplaneConfig"
+
+	// The value must be a Go duration, e.g. 20m
+	formatDuration = "duration"
+)
+
+// Pattern of Go durations in the JSON Schema
+const durationPattern = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+
+// Describes a config key, its type and constraints.
+type configKey struct {
+	Name        string
+	Type        string
+	Description string
+	Required    bool
+	Enum        []string
+	Check       string
+	Format      string
+	// Whether a project config may only set the key when it is trusted, the
+	// key giving access to the host or to the OCM token
+	TrustedOnly bool
+	// Element schema of an array
+	Items *configKey
+	// Keys of an object
+	Keys []configKey
+}
+
+var configSchema = configKey{
+	Type: schemaObject,
+	Keys: []configKey{
+		{Name: "hostUser", Type: schemaString, Required: true, Description: "Host user name, also used as the workspace user."},
+		{Name: "ocUser", Type: schemaString, Required: true, Description: "User that runs oc commands in the workspace."},
+		{Name: "userHome", Type: schemaString, Required: true, Description: "Home directory of the host user."},
+		{Name: "backplaneConfigProd", Type: schemaString, Required: true, Check: checkBackplaneConfig, Description: "Production backplane config file name under ~/.config/backplane."},
+		{Name: "backplaneConfigStage", Type: schemaString, Required: true, Check: checkBackplaneConfig, Description: "Staging backplane config file name under ~/.config/backplane."},
+		{Name: "baseImageVersion", Type: schemaString, Description: "Fedora base image version."},
+		{Name: "ocmCLIVersion", Type: schemaString, Required: true, Description: "OCM CLI version installed in the hc image."},
+		{Name: "backplaneCLIVersion", Type: schemaString, Required: true, Description: "Backplane CLI version installed in the hc image."},
+		{Name: "hardened", Type: schemaBoolean, Description: "Run workspaces unprivileged with a read-only root filesystem."},
+		{Name: "ocmLongLivedTokenPath", Type: schemaString, TrustedOnly: true, Check: checkHostPath, Description: "Path to a long-lived OCM token file."},
+		{Name: "shell", Type: schemaString, Enum: []string{"bash", "zsh", "fish"}, Description: "Shell run in the workspace."},
+		{Name: "shellRcFile", Type: schemaString, TrustedOnly: true, Check: checkHostPath, Description: "Host file sourced by the workspace shell after the hc setup."},
+		{
+			Name:        "prompt",
+			Type:        schemaObject,
+			Description: "Workspace prompt rendered by hc prompt.",
+			Keys: []configKey{
+				{Name: "template", Type: schemaString, Description: "Go template of the prompt, see hc prompt --help."},
+				{Name: "colors", Type: schemaBoolean, Description: "Color the prompt."},
+			},
+		},
+		{
+			Name:        "customDirMaps",
+			Type:        schemaArray,
+			TrustedOnly: true,
+			Description: "Host directories mounted in the workspace.",
+			Items: &configKey{
+				Type: schemaObject,
+				Keys: []configKey{
+					{Name: "hostDir", Type: schemaString, Required: true, Check: checkHostPath, Description: "Host directory."},
+					{Name: "containerDir", Type: schemaString, Required: true, Description: "Mount point in the workspace."},
+					{Name: "fileAttrs", Type: schemaString, Required: true, Enum: []string{"ro", "rw", "z"}, Description: "Mount options."},
+				},
+			},
+		},
+		{
+			Name:        "customPortMaps",
+			Type:        schemaArray,
+			Description: "Host ports mapped to workspace ports.",
+			Items: &configKey{
+				Type: schemaObject,
+				Keys: []configKey{
+					{Name: "hostPort", Type: schemaStringOrInteger, Required: true, Description: "Host port."},
+					{Name: "containerPort", Type: schemaStringOrInteger, Required: true, Description: "Workspace port."},
+				},
+			},
+		},
+		{Name: "addToPATHEnv", Type: schemaArray, Items: &configKey{Type: schemaString}, Description: "Paths appended to PATH in the workspace."},
+		{Name: "exportEnvVars", Type: schemaArray, Items: &configKey{Type: schemaString}, Description: "NAME=value environment variables exported in the workspace."},
+		{
+			Name:        "ocmCLIAlias",
+			Type:        schemaObject,
+			TrustedOnly: true,
+			Description: "Scripts used in place of the ocm CLI per OCM environment.",
+			Keys: []configKey{
+				{Name: "production", Type: schemaString, Description: "Script used for production."},
+				{Name: "staging", Type: schemaString, Description: "Script used for staging."},
+			},
+		},
+		{
+			Name:        "image",
+			Type:        schemaObject,
+			TrustedOnly: true,
+			Description: "Registry that hosts prebuilt hc images.",
+			Keys: []configKey{
+				{Name: "registry", Type: schemaString, Description: "Registry host and optional namespace, e.g. quay.io/my-team."},
+				{Name: "repository", Type: schemaString, Description: "Image repository name."},
+				{Name: "tlsVerify", Type: schemaBoolean, Description: "Verify registry TLS certificates."},
+			},
+		},
+		{
+			Name:        "persistentState",
+			Type:        schemaObject,
+			Description: "OCM config, backplane sessions and kubeconfig kept across workspaces, per OCM environment.",
+			Keys: []configKey{
+				{Name: "enabled", Type: schemaBoolean, Description: "Keep the workspace state across workspaces."},
+				{Name: "dir", Type: schemaString, TrustedOnly: true, Description: "Host directory of the state, by default ~/.local/share/hc/state."},
+			},
+		},
+		{
+			Name:        "elevation",
+			Type:        schemaObject,
+			Description: "Backplane elevation with hc elevate.",
+			Keys: []configKey{
+				{Name: "ttl", Type: schemaString, Format: formatDuration, Description: "Time after which the elevation is dropped, by default 20m."},
+			},
+		},
+		{
+			Name:        "sessionRecording",
+			Type:        schemaObject,
+			Description: "Recording of workspace terminal sessions and the commands run in them.",
+			Keys: []configKey{
+				{Name: "enabled", Type: schemaBoolean, Description: "Record workspace sessions."},
+				{Name: "dir", Type: schemaString, TrustedOnly: true, Description: "Host directory where sessions are recorded, by default ~/.local/share/hc/sessions."},
+			},
+		},
+	},
+}
+
+// A config error located in the config file.
+type ConfigError struct {
+	File   string
+	Line   int
+	Column int
+	Key    string
+	Msg    string
+}
+
+func (e ConfigError) Error() string {
+	msg := e.Msg
+	if len(e.Key) > 0 {
+		msg = fmt.Sprintf("%s: %s", e.Key, e.Msg)
+	}
+	if len(e.File) == 0 {
+		return msg
+	}
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, msg)
+	}
+	return fmt.Sprintf("%s: %s", e.File, msg)
+}
+
+// All errors found while validating a config file.
+type ConfigErrors []ConfigError
+
+func (e ConfigErrors) Error() string {
+	msgs := []string{fmt.Sprintf("%d config error(s):", len(e))}
+	for _, err := range e {
+		msgs = append(msgs, "  "+err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+type configValidator struct {
+	file       string
+	checkPaths bool
+	// Home directory that backplane config files are resolved against
+	userHome string
+	errs     ConfigErrors
+}
+
+func (v *configValidator) addError(node *yaml.Node, key string, format string, args ...interface{}) {
+	err := ConfigError{
+		File: v.file,
+		Key:  key,
+		Msg:  fmt.Sprintf(format, args...),
+	}
+	if node != nil {
+		err.Line = node.Line
+		err.Column = node.Column
+	}
+	v.errs = append(v.errs, err)
+}
+
+func joinKey(parent string, name string) string {
+	if len(parent) == 0 {
+		return name
+	}
+	return parent + "." + name
+}
+
+// Finds the schema key matching a config key. Keys are matched
+// case-insensitively, as viper does.
+func (k *configKey) findKey(name string) *configKey {
+	for idx := range k.Keys {
+		if strings.EqualFold(k.Keys[idx].Name, name) {
+			return &k.Keys[idx]
+		}
+	}
+	return nil
+}
+
+// Suggests the closest known key for a misspelled key.
+func (k *configKey) suggestKey(name string) string {
+	best := ""
+	bestDist := 4
+	for _, key := range k.Keys {
+		dist := levenshtein(strings.ToLower(name), strings.ToLower(key.Name))
+		if dist < bestDist {
+			best = key.Name
+			bestDist = dist
+		}
+	}
+	return best
+}
+
+func levenshtein(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+func (v *configValidator) validate(schema *configKey, node *yaml.Node, key string) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	// Keys without a value are treated as unset
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return
+	}
+
+	switch schema.Type {
+	case schemaString:
+		if node.Kind != yaml.ScalarNode {
+			v.addError(node, key, "expected a string")
+			return
+		}
+		v.validateEnum(schema, node, key)
+		v.validateFormat(schema, node, key)
+		v.validateCheck(schema, node, key)
+	case schemaStringOrInteger:
+		if node.Kind != yaml.ScalarNode {
+			v.addError(node, key, "expected a string or an integer")
+		}
+	case schemaBoolean:
+		if node.Kind != yaml.ScalarNode {
+			v.addError(node, key, "expected a boolean")
+			return
+		}
+		if _, err := strconv.ParseBool(node.Value); err != nil {
+			v.addError(node, key, "expected a boolean, got %q", node.Value)
+		}
+	case schemaArray:
+		if node.Kind != yaml.SequenceNode {
+			v.addError(node, key, "expected a list")
+			return
+		}
+		for idx, item := range node.Content {
+			v.validate(schema.Items, item, fmt.Sprintf("%s[%d]", key, idx))
+		}
+	case schemaObject:
+		if node.Kind != yaml.MappingNode {
+			v.addError(node, key, "expected a mapping")
+			return
+		}
+		v.validateObject(schema, node, key)
+	}
+}
+
+func (v *configValidator) validateObject(schema *configKey, node *yaml.Node, key string) {
+	seen := map[string]bool{}
+	for idx := 0; idx+1 < len(node.Content); idx += 2 {
+		keyNode := node.Content[idx]
+		valueNode := node.Content[idx+1]
+		childKey := joinKey(key, keyNode.Value)
+
+		child := schema.findKey(keyNode.Value)
+		if child == nil {
+			if suggestion := schema.suggestKey(keyNode.Value); len(suggestion) > 0 {
+				v.addError(keyNode, childKey, "unknown key, did you mean %q?", suggestion)
+			} else {
+				v.addError(keyNode, childKey, "unknown key")
+			}
+			continue
+		}
+		if seen[child.Name] {
+			v.addError(keyNode, childKey, "duplicate of key %q", child.Name)
+		}
+		seen[child.Name] = true
+		v.validate(child, valueNode, childKey)
+	}
+
+	// Required top-level keys may also come from the environment and are
+	// checked against the merged config instead.
+	if len(key) == 0 {
+		return
+	}
+	for _, child := range schema.Keys {
+		if child.Required && !seen[child.Name] {
+			v.addError(node, joinKey(key, child.Name), "missing required config")
+		}
+	}
+}
+
+func (v *configValidator) validateEnum(schema *configKey, node *yaml.Node, key string) {
+	if len(schema.Enum) == 0 {
+		return
+	}
+	for _, value := range schema.Enum {
+		if node.Value == value {
+			return
+		}
+	}
+	v.addError(node, key, "invalid value %q, must be one of: %s", node.Value, strings.Join(schema.Enum, ", "))
+}
+
+func (v *configValidator) validateFormat(schema *configKey, node *yaml.Node, key string) {
+	switch schema.Format {
+	case formatDuration:
+		if _, err := time.ParseDuration(node.Value); err != nil {
+			v.addError(node, key, "invalid duration %q, e.g. 20m or 1h30m", node.Value)
+		}
+	}
+}
+
+func (v *configValidator) validateCheck(schema *configKey, node *yaml.Node, key string) {
+	if !v.checkPaths || len(schema.Check) == 0 || len(node.Value) == 0 {
+		return
+	}
+
+	var path string
+	switch schema.Check {
+	case checkHostPath:
+		path = node.Value
+	case checkBackplaneConfig:
+		if len(v.userHome) == 0 {
+			return
+		}
+		path = filepath.Join(v.userHome, ".config", "backplane", node.Value)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		if schema.Check == checkBackplaneConfig {
+			v.addError(node, key, "%s does not exist, create it with \"hc backplane config init\"", path)
+			return
+		}
+		v.addError(node, key, "%s does not exist", path)
+	}
+}
+
+var yamlErrLineRegex = regexp.MustCompile(`line (\d+): (.*)`)
+
+// Converts a yaml syntax error to config errors with line numbers.
+func (v *configValidator) addYamlError(err error) {
+	var typeErr *yaml.TypeError
+	msgs := []string{strings.TrimPrefix(err.Error(), "yaml: ")}
+	if errors.As(err, &typeErr) {
+		msgs = typeErr.Errors
+	}
+
+	for _, msg := range msgs {
+		configErr := ConfigError{File: v.file, Msg: msg}
+		if match := yamlErrLineRegex.FindStringSubmatch(msg); match != nil {
+			configErr.Line, _ = strconv.Atoi(match[1])
+			configErr.Column = 1
+			configErr.Msg = match[2]
+		}
+		v.errs = append(v.errs, configErr)
+	}
+}
+
+// Validates a config file against the config schema. Path checks are only
+// meaningful on the host and can be disabled.
+func ValidateConfigFile(file string, checkPaths bool) ConfigErrors {
+	errs, _ := validateConfigFile(file, checkPaths)
+	return errs
+}
+
+func validateConfigFile(file string, checkPaths bool) (ConfigErrors, bool) {
+	v := &configValidator{
+		file:       file,
+		checkPaths: checkPaths,
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		v.addError(nil, "", "%v", err)
+		return v.errs, false
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		v.addYamlError(err)
+		return v.errs, false
+	}
+	if len(doc.Content) > 0 {
+		v.userHome = viper.GetString("userHome")
+		if node := findConfigNode(doc.Content[0], "userHome"); node != nil && node.Kind == yaml.ScalarNode {
+			v.userHome = node.Value
+		}
+		v.validate(&configSchema, doc.Content[0], "")
+	}
+	return v.errs, true
+}
+
+// Validates the loaded config layers. All errors are reported at once.
+func ValidateConfig(checkPaths bool) error {
+	var errs ConfigErrors
+	parsedAll := true
+	for _, file := range GetConfigFiles() {
+		fileErrs, parsed := validateConfigFile(file, checkPaths)
+		sort.SliceStable(fileErrs, func(i, j int) bool {
+			return fileErrs[i].Line < fileErrs[j].Line
+		})
+		errs = append(errs, fileErrs...)
+		parsedAll = parsedAll && parsed
+	}
+
+	// Missing keys are noise when a file could not be parsed
+	if parsedAll {
+		for _, key := range configSchema.Keys {
+			if key.Required && len(strings.TrimSpace(viper.GetString(key.Name))) == 0 {
+				errs = append(errs, ConfigError{
+					Key: key.Name,
+					Msg: fmt.Sprintf("missing required config, set it in a config file or with %s", GetConfigEnvVar(key.Name)),
+				})
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (k *configKey) jsonSchema() map[string]interface{} {
+	schema := map[string]interface{}{
+		"type": k.Type,
+	}
+	if k.Type == schemaStringOrInteger {
+		schema["type"] = []string{schemaString, "integer"}
+	}
+	if len(k.Description) > 0 {
+		schema["description"] = k.Description
+	}
+	if len(k.Enum) > 0 {
+		schema["enum"] = k.Enum
+	}
+	if k.Format == formatDuration {
+		schema["pattern"] = durationPattern
+	}
+	if k.Items != nil {
+		schema["items"] = k.Items.jsonSchema()
+	}
+	if k.Type == schemaObject {
+		properties := map[string]interface{}{}
+		required := []string{}
+		for _, key := range k.Keys {
+			properties[key.Name] = key.jsonSchema()
+			if key.Required {
+				required = append(required, key.Name)
+			}
+		}
+		schema["properties"] = properties
+		schema["additionalProperties"] = false
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+	}
+	return schema
+}
+
+// Gets the JSON Schema of the hc config for editor integration.
+func GetConfigJSONSchema() ([]byte, error) {
+	schema := configSchema.jsonSchema()
+	// The schema applies to every config layer, and a project or system
+	// config only sets some keys. Required top-level keys are checked on
+	// the merged config by hc config validate.
+	delete(schema, "required")
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "hc config"
+	return json.MarshalIndent(schema, "", "  ")
+}
@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// A project config that only sets some keys matches the JSON Schema, so only
+// nested keys are required there.
+func TestGetConfigJSONSchema(t *testing.T) {
+	content, err := GetConfigJSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Required   []string `json:"required"`
+		Properties map[string]struct {
+			Items struct {
+				Required   []string `json:"required"`
+				Properties map[string]struct {
+					Type interface{} `json:"type"`
+				} `json:"properties"`
+			} `json:"items"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(content, &schema); err != nil {
+		t.Fatal(err)
+	}
+	if len(schema.Required) > 0 {
+		t.Errorf("top-level keys %v are required by the schema", schema.Required)
+	}
+	ports := schema.Properties["customPortMaps"].Items
+	if !reflect.DeepEqual(ports.Required, []string{"hostPort", "containerPort"}) {
+		t.Errorf("port map keys %v are required, expected hostPort and containerPort", ports.Required)
+	}
+	for _, name := range []string{"hostPort", "containerPort"} {
+		if portType := ports.Properties[name].Type; !reflect.DeepEqual(portType, []interface{}{"string", "integer"}) {
+			t.Errorf("%s has the type %v, expected a string or an integer", name, portType)
+		}
+	}
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), ".hc.yaml")
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestValidateConfigFilePorts(t *testing.T) {
+	file := writeConfigFile(t, "customPortMaps:\n  - hostPort: 8080\n    containerPort: \"80\"\n  - hostPort: [8081]\n    containerPort: 81\n")
+	errs := ValidateConfigFile(file, false)
+	if len(errs) != 1 || errs[0].Key != "customPortMaps[1].hostPort" || errs[0].Line != 4 {
+		t.Errorf("errors %v, expected customPortMaps[1].hostPort only", errs)
+	}
+}
+
+// Keys of mappings in lists are shown and decoded with their schema names,
+// although viper lowercases them.
+func TestGetEffectiveConfigDocument(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	// No project config is found in a temporary directory
+	chdir(t, t.TempDir())
+	file := writeConfigFile(t, "hostUser: me\ncustomDirMaps:\n  - fileAttrs: ro\n    hostDir: /tmp\n    containerDir: /mnt/tmp\ncustomPortMaps:\n  - hostPort: 8080\n    containerPort: 80\n")
+	if err := LoadConfigLayers(file, true); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := GetEffectiveConfigDocument(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := EncodeConfigDocument(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "hostUser: me\ncustomDirMaps:\n  - hostDir: /tmp\n    containerDir: /mnt/tmp\n    fileAttrs: ro\ncustomPortMaps:\n  - hostPort: 8080\n    containerPort: 80\n"
+	if strings.TrimSpace(string(out)) != strings.TrimSpace(expected) {
+		t.Errorf("effective config:\n%s\nexpected:\n%s", out, expected)
+	}
+
+	config, err := GetHcConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ports := config.CustomPortMaps; len(ports) != 1 || ports[0].HostPort != "8080" || ports[0].ContainerPort != "80" {
+		t.Errorf("port maps %+v, expected 8080 to 80", ports)
+	}
+}
@@ -0,0 +1,348 @@
+package session
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/creack/pty"
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// Header of an asciicast v2 file.
+// https://docs.asciinema.org/manual/asciicast/v2/
+type castHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Writes asciicast v2 events, timed from its creation.
+type castWriter struct {
+	mu      sync.Mutex
+	out     *bufio.Writer
+	start   time.Time
+	pending []byte
+}
+
+func newCastWriter(w io.Writer, header *castHeader) (*castWriter, error) {
+	cw := &castWriter{
+		out:   bufio.NewWriter(w),
+		start: time.Now(),
+	}
+	header.Version = 2
+	header.Timestamp = cw.start.Unix()
+	content, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := cw.out.Write(append(content, '\n')); err != nil {
+		return nil, err
+	}
+	return cw, cw.out.Flush()
+}
+
+func (cw *castWriter) event(kind string, data string) error {
+	content, err := json.Marshal([]interface{}{
+		time.Since(cw.start).Seconds(),
+		kind,
+		data,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := cw.out.Write(append(content, '\n')); err != nil {
+		return err
+	}
+	return cw.out.Flush()
+}
+
+// Records terminal output. A multi-byte character split across writes is held
+// back until it is complete since events must be valid UTF-8.
+func (cw *castWriter) Write(p []byte) (int, error) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	data := append(cw.pending, p...)
+	n := len(data)
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				n = i
+			}
+			break
+		}
+	}
+	cw.pending = append([]byte{}, data[n:]...)
+
+	if n == 0 {
+		return len(p), nil
+	}
+	return len(p), cw.event("o", string(data[:n]))
+}
+
+// Records the bytes held back at the end of the output, an incomplete
+// character being replaced with U+FFFD.
+func (cw *castWriter) flush() error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	if len(cw.pending) == 0 {
+		return nil
+	}
+	data := strings.ToValidUTF8(string(cw.pending), "\uFFFD")
+	cw.pending = nil
+	return cw.event("o", data)
+}
+
+func (cw *castWriter) resize(width int, height int) error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	return cw.event("r", fmt.Sprintf("%dx%d", width, height))
+}
+
+// Time processes left in the background have to release the pseudo terminal
+// after the recorded command was hung up
+const recordStopDelay = 5 * time.Second
+
+// Runs a command in a pseudo terminal attached to the current terminal and
+// records its output to an asciicast v2 file. The command is hung up when the
+// context is cancelled. Returns the command's exit code.
+func Record(ctx context.Context, castFile string, title string, name string, args ...string) (int, error) {
+	file, err := os.OpenFile(castFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return -1, err
+	}
+	defer file.Close()
+
+	width, height := 80, 24
+	stdinFd := int(os.Stdin.Fd())
+	isTerminal := term.IsTerminal(stdinFd)
+	if isTerminal {
+		if w, h, err := term.GetSize(stdinFd); err == nil && w > 0 && h > 0 {
+			width, height = w, h
+		}
+	}
+
+	cw, err := newCastWriter(file, &castHeader{
+		Width:  width,
+		Height: height,
+		Title:  title,
+		Env: map[string]string{
+			"SHELL": name,
+			"TERM":  os.Getenv("TERM"),
+		},
+	})
+	if err != nil {
+		return -1, err
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGHUP)
+	}
+	ptmx, err := pty.StartWithSize(cmd, &pty.Winsize{Rows: uint16(height), Cols: uint16(width)})
+	if err != nil {
+		return -1, err
+	}
+	defer ptmx.Close()
+
+	if isTerminal {
+		resizeCh := make(chan os.Signal, 1)
+		signal.Notify(resizeCh, syscall.SIGWINCH)
+		defer func() {
+			signal.Stop(resizeCh)
+			close(resizeCh)
+		}()
+		go func() {
+			for range resizeCh {
+				if err := pty.InheritSize(os.Stdin, ptmx); err != nil {
+					continue
+				}
+				if w, h, err := term.GetSize(stdinFd); err == nil {
+					cw.resize(w, h)
+				}
+			}
+		}()
+
+		// Keystrokes are handled by the terminal in the pty
+		oldState, err := term.MakeRaw(stdinFd)
+		if err != nil {
+			return -1, err
+		}
+		defer term.Restore(stdinFd, oldState)
+	}
+
+	// Input is no longer read once the recording ends, the next read of hc
+	// or of the shell it returns to gets it
+	stdin, err := newStoppableReader(os.Stdin)
+	if err != nil {
+		return -1, err
+	}
+
+	copied := make(chan struct{})
+	go func() {
+		select {
+		case <-copied:
+		case <-ctx.Done():
+			select {
+			case <-copied:
+			case <-time.After(recordStopDelay):
+				ptmx.Close()
+			}
+		}
+	}()
+
+	input := make(chan struct{})
+	go func() {
+		defer close(input)
+		io.Copy(ptmx, stdin)
+	}()
+	// Reading fails with EIO once the command exits
+	io.Copy(io.MultiWriter(os.Stdout, cw), ptmx)
+	close(copied)
+	stdin.Stop()
+	ptmx.Close()
+	<-input
+	stdin.Close()
+	flushErr := cw.flush()
+
+	err = cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), flushErr
+	}
+	if err != nil {
+		return -1, err
+	}
+	return 0, flushErr
+}
+
+// Reads a file until it is stopped, without reading anything after.
+type stoppableReader struct {
+	fd int
+	// Closing the writer wakes up the reads waiting for input
+	stop       *os.File
+	stopWriter *os.File
+}
+
+func newStoppableReader(file *os.File) (*stoppableReader, error) {
+	stop, stopWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	return &stoppableReader{fd: int(file.Fd()), stop: stop, stopWriter: stopWriter}, nil
+}
+
+func (r *stoppableReader) Read(p []byte) (int, error) {
+	fds := []unix.PollFd{
+		{Fd: int32(r.fd), Events: unix.POLLIN},
+		{Fd: int32(r.stop.Fd()), Events: unix.POLLIN},
+	}
+	for {
+		_, err := unix.Poll(fds, -1)
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		// Stopping wins over pending input
+		if fds[1].Revents != 0 {
+			return 0, io.EOF
+		}
+		if fds[0].Revents != 0 {
+			break
+		}
+	}
+	n, err := unix.Read(r.fd, p)
+	switch {
+	case err != nil:
+		return 0, err
+	case n == 0:
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// Stops the reads, the pending and the next ones return io.EOF.
+func (r *stoppableReader) Stop() {
+	r.stopWriter.Close()
+}
+
+// Releases the reader once it is no longer read, the file is left open.
+func (r *stoppableReader) Close() error {
+	r.Stop()
+	return r.stop.Close()
+}
+
+// Replays the output of an asciicast v2 file. Delays are divided by speed and
+// capped to idleLimit when it is positive.
+func Replay(castFile string, w io.Writer, speed float64, idleLimit time.Duration) error {
+	if speed <= 0 {
+		return fmt.Errorf("invalid replay speed %v", speed)
+	}
+
+	file, err := os.Open(castFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	if !scanner.Scan() {
+		return fmt.Errorf("%s: missing header", castFile)
+	}
+	var header castHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return fmt.Errorf("%s: invalid header: %w", castFile, err)
+	}
+	if header.Version != 2 {
+		return fmt.Errorf("%s: unsupported asciicast version %d", castFile, header.Version)
+	}
+
+	var last float64
+	for line := 2; scanner.Scan(); line++ {
+		var event []interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || len(event) != 3 {
+			return fmt.Errorf("%s:%d: invalid event", castFile, line)
+		}
+		elapsed, okElapsed := event[0].(float64)
+		kind, okKind := event[1].(string)
+		data, okData := event[2].(string)
+		if !okElapsed || !okKind || !okData {
+			return fmt.Errorf("%s:%d: invalid event", castFile, line)
+		}
+		if kind != "o" {
+			continue
+		}
+
+		delay := time.Duration((elapsed - last) / speed * float64(time.Second))
+		if idleLimit > 0 && delay > idleLimit {
+			delay = idleLimit
+		}
+		last = elapsed
+		time.Sleep(delay)
+
+		if _, err := io.WriteString(w, data); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
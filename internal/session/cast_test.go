@@ -0,0 +1,147 @@
+package session
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Reads the output recorded in an asciicast file.
+func readCastOutput(t *testing.T, castFile string) []string {
+	t.Helper()
+	file, err := os.Open(castFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	output := []string{}
+	scanner := bufio.NewScanner(file)
+	// Skips the header
+	scanner.Scan()
+	for scanner.Scan() {
+		var event []interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("invalid event %s: %v", scanner.Bytes(), err)
+		}
+		if len(event) == 3 && event[1] == "o" {
+			output = append(output, event[2].(string))
+		}
+	}
+	return output
+}
+
+// A character split across writes is recorded once complete, and an
+// incomplete one is recorded when the output ends.
+func TestCastWriterSplitCharacters(t *testing.T) {
+	castFile := filepath.Join(t.TempDir(), "session.cast")
+	file, err := os.Create(castFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	cw, err := newCastWriter(file, &castHeader{Width: 80, Height: 24})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, data := range []string{"caf\xc3", "\xa9 \xe2", "\x82"} {
+		if _, err := cw.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cw.flush(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"caf", "é ", "�"}
+	if output := readCastOutput(t, castFile); strings.Join(output, "|") != strings.Join(expected, "|") {
+		t.Errorf("recorded %q, expected %q", output, expected)
+	}
+}
+
+func TestRecordFlushesOutput(t *testing.T) {
+	castFile := filepath.Join(t.TempDir(), "session.cast")
+	exitCode, err := Record(context.Background(), castFile, "test", "sh", "-c", `printf 'caf\303\251 \342'; exit 3`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exitCode != 3 {
+		t.Errorf("exit code %d, expected 3", exitCode)
+	}
+	if output := strings.Join(readCastOutput(t, castFile), ""); output != "café �" {
+		t.Errorf("recorded %q, expected %q", output, "café �")
+	}
+}
+
+func TestRecordCancelled(t *testing.T) {
+	castFile := filepath.Join(t.TempDir(), "session.cast")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	exitCode, err := Record(ctx, castFile, "test", "sh", "-c", "echo started; sleep 30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(started); elapsed > recordStopDelay+time.Second {
+		t.Errorf("stopped after %s", elapsed)
+	}
+	if exitCode == 0 {
+		t.Error("the hung up command exited successfully")
+	}
+	if output := strings.Join(readCastOutput(t, castFile), ""); !strings.Contains(output, "started") {
+		t.Errorf("recorded %q, expected the output before the hang up", output)
+	}
+}
+
+// Input left after the recording is not read, a keystroke is not swallowed.
+func TestStoppableReader(t *testing.T) {
+	pipeReader, pipeWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pipeReader.Close()
+	defer pipeWriter.Close()
+	stdin, err := newStoppableReader(pipeReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+
+	pipeWriter.Write([]byte("a"))
+	buf := make([]byte, 8)
+	if n, err := stdin.Read(buf); err != nil || string(buf[:n]) != "a" {
+		t.Fatalf("read %q (%v), expected %q", buf[:n], err, "a")
+	}
+
+	// A pending read returns once the reader is stopped
+	read := make(chan error)
+	go func() {
+		_, err := stdin.Read(buf)
+		read <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	stdin.Stop()
+	select {
+	case err := <-read:
+		if err != io.EOF {
+			t.Errorf("pending read failed with %v, expected EOF", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("pending read not stopped")
+	}
+
+	pipeWriter.Write([]byte("b"))
+	if n, err := stdin.Read(buf); err != io.EOF {
+		t.Errorf("read %q (%v) after the stop, expected EOF", buf[:n], err)
+	}
+	if n, err := pipeReader.Read(buf); err != nil || string(buf[:n]) != "b" {
+		t.Errorf("input %q (%v) after the stop, expected %q", buf[:n], err, "b")
+	}
+}
@@ -0,0 +1,219 @@
+package session
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	pkgIntHelper "hc/internal/helpers"
+)
+
+const (
+	// Directory where the session recording directory is mounted in the container
+	ContainerDir = "/hc-sessions"
+
+	metaFileName       = "session.json"
+	castFileName       = "session.cast"
+	commandLogFileName = "commands.jsonl"
+)
+
+// Describes a recorded workspace session.
+type Meta struct {
+	ID             string     `json:"id"`
+	User           string     `json:"user"`
+	Cluster        string     `json:"cluster"`
+	OcmEnvironment string     `json:"ocmEnvironment"`
+	StartedAt      time.Time  `json:"startedAt"`
+	EndedAt        *time.Time `json:"endedAt,omitempty"`
+	ExitCode       *int       `json:"exitCode,omitempty"`
+}
+
+// A command run in a workspace session, or an event such as an elevation.
+type CommandEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Session   string    `json:"session"`
+	User      string    `json:"user"`
+	Cluster   string    `json:"cluster"`
+	Namespace string    `json:"namespace"`
+	Command   string    `json:"command"`
+	ExitCode  int       `json:"exitCode"`
+	// Event logged by hc, empty for commands run in the shell
+	Event  string `json:"event,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+const (
+	EventElevate       = "elevate"
+	EventDropElevation = "drop-elevation"
+)
+
+func GetSessionDir(dir string, id string) string {
+	return filepath.Join(dir, id)
+}
+
+func GetCastFile(dir string, id string) string {
+	return filepath.Join(dir, id, castFileName)
+}
+
+func GetCommandLogFile(dir string, id string) string {
+	return filepath.Join(dir, id, commandLogFileName)
+}
+
+// Creates the directory of a session and writes its metadata.
+func Create(dir string, meta *Meta) error {
+	if err := os.MkdirAll(GetSessionDir(dir, meta.ID), 0700); err != nil {
+		return err
+	}
+	return WriteMeta(dir, meta)
+}
+
+func WriteMeta(dir string, meta *Meta) error {
+	content, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return pkgIntHelper.WriteFileAtomic(
+		filepath.Join(GetSessionDir(dir, meta.ID), metaFileName),
+		append(content, '\n'),
+		0600,
+	)
+}
+
+func ReadMeta(dir string, id string) (*Meta, error) {
+	content, err := os.ReadFile(filepath.Join(GetSessionDir(dir, id), metaFileName))
+	if err != nil {
+		return nil, err
+	}
+	var meta Meta
+	if err := json.Unmarshal(content, &meta); err != nil {
+		return nil, fmt.Errorf("invalid session %s: %w", id, err)
+	}
+	return &meta, nil
+}
+
+// Lists the sessions recorded in a directory, most recent first.
+func List(dir string) ([]Meta, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []Meta{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		meta, err := ReadMeta(dir, entry.Name())
+		if err != nil {
+			// Not a session directory
+			continue
+		}
+		sessions = append(sessions, *meta)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].StartedAt.After(sessions[j].StartedAt)
+	})
+	return sessions, nil
+}
+
+// Appends a command to the command log of a session. Each entry is written
+// with a single write so that concurrent shells do not interleave entries.
+func AppendCommand(dir string, entry *CommandEntry) error {
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(
+		GetCommandLogFile(dir, entry.Session),
+		os.O_APPEND|os.O_CREATE|os.O_WRONLY,
+		0600,
+	)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(content, '\n'))
+	return err
+}
+
+func ReadCommands(dir string, id string) ([]CommandEntry, error) {
+	file, err := os.Open(GetCommandLogFile(dir, id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	commands := []CommandEntry{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry CommandEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid entry in %s: %w", GetCommandLogFile(dir, id), err)
+		}
+		commands = append(commands, entry)
+	}
+	return commands, scanner.Err()
+}
+
+// Writes a session directory as a gzipped tarball.
+func Export(dir string, id string, w io.Writer) error {
+	sessionDir := GetSessionDir(dir, id)
+	if _, err := os.Stat(sessionDir); err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	err := filepath.Walk(sessionDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tw, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// A shell supported in the workspace.
+type Shell struct {
+	Name string
+	// Executable run as the workspace shell
+	Exec string
+	// Rc file of the shell, relative to the user home
+	RcFile string
+	// Base rc file in the terminal directory of the image
+	BaseRcFile string
+	// Template rendered after the base rc file, in the terminal directory of the image
+	RcTemplate string
+}
+
+var shells = map[string]Shell{
+	"bash": {
+		Name:       "bash",
+		Exec:       "bash",
+		RcFile:     ".bashrc",
+		BaseRcFile: "bashrc",
+		RcTemplate: "bash.tmpl",
+	},
+	"zsh": {
+		Name:       "zsh",
+		Exec:       "zsh",
+		RcFile:     ".zshrc",
+		BaseRcFile: "zshrc",
+		RcTemplate: "zsh.tmpl",
+	},
+	"fish": {
+		Name:       "fish",
+		Exec:       "fish",
+		RcFile:     ".config/fish/config.fish",
+		BaseRcFile: "config.fish",
+		RcTemplate: "fish.tmpl",
+	},
+}
+
+func GetShell(name string) (*Shell, error) {
+	if len(name) == 0 {
+		name = defaultShell
+	}
+	shell, found := shells[name]
+	if !found {
+		return nil, fmt.Errorf("unsupported shell: %s", name)
+	}
+	return &shell, nil
+}
+
+// An environment variable exported in the workspace shell.
+type ShellEnvVar struct {
+	Name  string
+	Value string
+	// Whether a value is set, otherwise the variable is only exported
+	HasValue bool
+}
+
+// Values rendered into the shell rc templates.
+type ShellRcData struct {
+	HostUser       string
+	OcmEnvironment string
+	AddToPATHEnv   []string
+	ExportEnvVars  []ShellEnvVar
+	// Hook that logs commands to the recorded session, empty if not recorded
+	AuditHookFile string
+	// User rc snippet sourced last, empty if there is none
+	UserRcFile string
+}
+
+// Parses NAME=value environment variables from the config.
+func ParseShellEnvVars(envVars []string) []ShellEnvVar {
+	parsed := []ShellEnvVar{}
+	for _, envVar := range envVars {
+		name, value, hasValue := strings.Cut(envVar, "=")
+		parsed = append(parsed, ShellEnvVar{
+			Name:     strings.TrimSpace(name),
+			Value:    value,
+			HasValue: hasValue,
+		})
+	}
+	return parsed
+}
+
+// Gets the hook that logs commands to the recorded session.
+func (s *Shell) GetAuditHookFile(terminalDir string) string {
+	return filepath.Join(terminalDir, "audit."+s.Name)
+}
+
+// Renders the rc file of the shell from its base rc file and template.
+func (s *Shell) RenderRc(terminalDir string, data *ShellRcData) ([]byte, error) {
+	base, err := os.ReadFile(filepath.Join(terminalDir, s.BaseRcFile))
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.ParseFiles(filepath.Join(terminalDir, s.RcTemplate))
+	if err != nil {
+		return nil, err
+	}
+
+	out := bytes.NewBuffer(base)
+	out.WriteString("\n")
+	if err := tmpl.Execute(out, data); err != nil {
+		return nil, fmt.Errorf("failed to render %s: %w", s.RcTemplate, err)
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+const (
+	// Directory where the persistent state is mounted in the container
+	StateContainerDir = "/hc-state"
+
+	stateLockFileName = ".lock"
+)
+
+// A directory of the persistent state and where it is linked in the user home.
+type StateDir struct {
+	Name string
+	// Relative to the user home
+	HomePath string
+}
+
+// Directories of the persistent state: OCM config, backplane sessions,
+// kubeconfig, workspace clusters and hc caches.
+var StateDirs = []StateDir{
+	{Name: "ocm", HomePath: ".config/ocm"},
+	{Name: "backplane", HomePath: "backplane"},
+	{Name: "kube", HomePath: ".kube"},
+	{Name: "hc-config", HomePath: ".config/hc"},
+	{Name: "hc-cache", HomePath: ".cache/hc"},
+}
+
+// Returned when the persistent state is used by another workspace.
+type StateLockedError struct {
+	Dir string
+	// Workspace that holds the lock, as written by its hc login
+	Holder string
+}
+
+func (e *StateLockedError) Error() string {
+	if len(e.Holder) == 0 {
+		return fmt.Sprintf("%s is used by another workspace", e.Dir)
+	}
+	return fmt.Sprintf("%s is used by workspace %s", e.Dir, e.Holder)
+}
+
+// An exclusive lock of a persistent state directory, held until it is
+// released or the process exits.
+type StateLock struct {
+	file *os.File
+}
+
+// Locks a persistent state directory, creating it if needed. The holder is
+// recorded so that other workspaces can report who uses the state.
+func LockState(dir string, holder string) (*StateLock, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, stateLockFileName), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		defer file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			content, _ := os.ReadFile(file.Name())
+			return nil, &StateLockedError{Dir: dir, Holder: strings.TrimSpace(string(content))}
+		}
+		return nil, err
+	}
+
+	if err := file.Truncate(0); err == nil {
+		file.WriteAt([]byte(holder+"\n"), 0)
+	}
+	return &StateLock{file: file}, nil
+}
+
+func (l *StateLock) Release() error {
+	l.file.Truncate(0)
+	return l.file.Close()
+}
+
+// Removes a persistent state directory. Fails if a workspace uses it.
+func WipeState(dir string) error {
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	lock, err := LockState(dir, "hc logout")
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Name() == stateLockFileName {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
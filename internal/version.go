@@ -0,0 +1,29 @@
+package internal
+
+import "runtime/debug"
+
+// Version of hc, set on release builds with
+// -ldflags "-X hc/internal.Version=<version>"
+var Version = "dev"
+
+// Gets the git commit hc was built from, with a "-dirty" suffix if the tree
+// had uncommitted changes. Empty if hc was not built from a git checkout.
+func GetBuildSHA() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	var revision, modified string
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value
+		}
+	}
+	if len(revision) > 0 && modified == "true" {
+		revision += "-dirty"
+	}
+	return revision
+}
@@ -0,0 +1,25 @@
+# Appends every command run in the workspace shell to the session's command
+# log. Sourced by the workspace bashrc when session recording is enabled.
+
+# Commands must not be skipped by the history
+unset HISTCONTROL HISTIGNORE
+
+__hc_audit_history_number() {
+    HISTTIMEFORMAT= history 1 | sed -n '1s/^ *\([0-9]*\).*/\1/p'
+}
+
+__hc_audit_last=$(__hc_audit_history_number)
+
+__hc_audit() {
+    local exit_code=$? number command
+    number=$(__hc_audit_history_number)
+    # Pressing enter on an empty line does not add a history entry
+    if [ -n "$number" ] && [ "$number" != "$__hc_audit_last" ]; then
+        __hc_audit_last=$number
+        command=$(HISTTIMEFORMAT= history 1 | sed '1s/^ *[0-9]*\*\{0,1\} *//')
+        /usr/bin/hc sessions log-command --exit-code "$exit_code" -- "$command"
+    fi
+    return $exit_code
+}
+
+PROMPT_COMMAND="__hc_audit${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
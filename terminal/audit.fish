@@ -0,0 +1,10 @@
+# Appends every command run in the workspace shell to the session's command
+# log. Sourced by the workspace config.fish when session recording is enabled.
+
+function __hc_audit --on-event fish_postexec
+    set -l exit_code $status
+    set -l command (string trim -- "$argv[1]" | string collect)
+    if test -n "$command"
+        /usr/bin/hc sessions log-command --exit-code $exit_code -- "$command"
+    end
+end
@@ -0,0 +1,7 @@
+# config.fish
+
+set -g fish_greeting
+
+# User specific environment
+fish_add_path -gP $HOME/.local/bin $HOME/bin
+set -gx PATH $PATH /ocm-workspace/shared/scripts